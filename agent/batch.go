@@ -0,0 +1,231 @@
+// 该模块定义了批量调用查询代理的方法。
+// 支持批量查询的爬虫可以在一次调用中查询同一运输商、同一语言的多个运单。
+// 批量调用的格式：运单号和查询流水号分别用英文逗号连接，放在和单独调用相同的参数中，
+// 即Go爬虫的`nums`查询参数和Python爬虫请求体的`trackingNo`字段；
+// 爬虫返回批量跟踪结果对象，其中`items`的每个元素通过`trackingNo`对应到批量中的一个运单。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_cache "com.cne/ai-tracking-search/cache"
+	_db "com.cne/ai-tracking-search/db"
+	_queue "com.cne/ai-tracking-search/queue"
+	_types "com.cne/ai-tracking-search/types"
+	_utils "com.cne/ai-tracking-search/utils"
+)
+
+// 表示批量中的一个查询对象。
+type batchItem struct {
+	m          *_queue.Message // 出队的消息，批量处理完毕后确认。
+	key        string          // 查询对象在缓存中的键。
+	seqNo      string          // 查询流水号。
+	trackingNo string          // 运单号。
+}
+
+// 表示正在收集的批量。
+type pendingBatch struct {
+	crawlerInfo *_db.CrawlerInfoPo
+	carrierCode string
+	language    _types.LangId
+	items       []*batchItem
+	flushed     bool // 是否已经结束收集，由 batchLock 保护。
+	aborted     bool // 是否因为退出而中止了调用，中止的查询对象由 Shutdown 放回队列。
+}
+
+var (
+	batchLock sync.Mutex               // 保护 batches 的同步锁。
+	batches   map[string]*pendingBatch // 正在收集的批量，键是爬虫ID和语言。
+)
+
+func init() {
+	batches = make(map[string]*pendingBatch)
+}
+
+// 判断查询对象是否可以和其它查询对象合并为批量。
+// 附带了邮编、目的地或者发件日期的查询对象只能单独查询，因为这些参数是针对单个运单的。
+func isBatchable(crawlerInfo *_db.CrawlerInfoPo, postcode, dest, date string) bool {
+	return crawlerInfo.BatchSize > 1 && (crawlerInfo.Type == ctPython || crawlerInfo.Type == ctGo) && postcode == "" && dest == "" && date == ""
+}
+
+// 将查询对象加入批量。
+// 如果批量已满那么立刻调用查询代理，否则等待时间窗口结束后调用。
+// 每个查询对象的结果写入缓存之后才确认，如果批量调用过程中发生panic，那么未确认的查询对象会在可见性超时之后被重新投递。
+func submitBatch(m *_queue.Message, key string, crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode string, language _types.LangId, trackingNo string) {
+	_cache.Update(agentCtx, key, map[string]interface{}{"status": 0})
+
+	bk := strconv.FormatInt(crawlerInfo.Id, 10) + "$" + language.String()
+	item := &batchItem{m: m, key: key, seqNo: seqNo, trackingNo: trackingNo}
+
+	batchLock.Lock()
+	defer batchLock.Unlock()
+
+	b, ok := batches[bk]
+	if !ok {
+		b = &pendingBatch{crawlerInfo: crawlerInfo, carrierCode: carrierCode, language: language, items: make([]*batchItem, 0, crawlerInfo.BatchSize)}
+		batches[bk] = b
+		flushes.Add(1)
+
+		time.AfterFunc(time.Duration(atomic.LoadInt64(&batchWindow)), func() { flushBatch(bk, b) })
+	}
+
+	b.items = append(b.items, item)
+	if len(b.items) >= crawlerInfo.BatchSize {
+		// 批量已满，立刻移出收集中的批量，之后的查询对象加入新的批量，批量的大小不会超过上限。
+		delete(batches, bk)
+		go flushBatch(bk, b)
+	}
+}
+
+// 结束批量的收集，并调用查询代理。
+// 时间窗口结束和批量已满都会触发此方法，只有第一次调用有效。
+func flushBatch(bk string, b *pendingBatch) {
+	batchLock.Lock()
+	if b.flushed {
+		batchLock.Unlock()
+		return
+	}
+	b.flushed = true
+	if batches[bk] == b {
+		delete(batches, bk)
+	}
+	batchLock.Unlock()
+
+	defer flushes.Done()
+	defer func() {
+		if b.aborted {
+			return
+		}
+		for _, item := range b.items {
+			inflight.Done(item.m)
+		}
+	}()
+	defer _utils.RecoverPanic()
+
+	callCrawlerBatch(b)
+}
+
+// 立刻结束所有正在收集的批量，不再等待时间窗口结束。
+func flushAllBatches() {
+	batchLock.Lock()
+	pending := make(map[string]*pendingBatch, len(batches))
+	for bk, b := range batches {
+		pending[bk] = b
+	}
+	batchLock.Unlock()
+
+	for bk, b := range pending {
+		go flushBatch(bk, b)
+	}
+}
+
+// 批量调用查询代理，并将结果分发给每个查询对象。
+// 结果无法解析或者缺少某个运单时，对应的查询对象写入错误信息，不会把整个批量的响应当作单个运单的结果。
+func callCrawlerBatch(b *pendingBatch) {
+	crawlerInfo := b.crawlerInfo
+
+	seqNos := make([]string, 0, len(b.items))
+	trackingNos := make([]string, 0, len(b.items))
+	for _, item := range b.items {
+		seqNos = append(seqNos, item.seqNo)
+		trackingNos = append(trackingNos, item.trackingNo)
+	}
+	seqNo := strings.Join(seqNos, ",")
+	trackingNo := strings.Join(trackingNos, ",")
+
+	// 运输商或者爬虫达到限制时，将批量中的所有查询对象放回队列。
+	release, ok := acquireLimits(carrierLimit(b.carrierCode), crawlerLimit(crawlerInfo))
+	if !ok {
+		for _, item := range b.items {
+			requeue(item.m, item.key, b.carrierCode)
+		}
+		return
+	}
+	defer release()
+
+	var aResult *agentResult
+	var cErr error
+	if crawlerInfo.Type == ctPython {
+		aResult, cErr = callCrawlerByPython(crawlerInfo, seqNo, b.carrierCode, b.language, trackingNo, "", "", "")
+	} else {
+		aResult, cErr = callCrawlerByGolang(crawlerInfo, seqNo, b.carrierCode, b.language, trackingNo, "", "", "")
+	}
+
+	if cErr != nil && workCtx.Err() != nil {
+		// 退出时中止了调用，查询对象不写入结果也不确认。
+		b.aborted = true
+		return
+	} else if cErr != nil {
+		log.Printf("[WARN]: Cannot call crawler in batch. cause=%s\n", cErr)
+		for _, item := range b.items {
+			updateCache(item.key, _types.SrcCrawler, crawlerInfo.Name, fmt.Sprintf("$批量调用爬虫失败(carrier-code=%s,crawler-name=%s)$", b.carrierCode, crawlerInfo.Name), &agentResult{})
+			// 和单独调用一样，失败已经写入缓存，不再重新投递。
+			ack(item.m)
+		}
+		return
+	}
+
+	// 批量查询的结果必须是包含多个运单的批量跟踪结果对象。
+	crawlerRsp := ResponseWrapper{}
+	if err := json.Unmarshal([]byte(aResult.Result), &crawlerRsp); err != nil {
+		log.Printf("[WARN] Cannot parse batch crawler result json: %v. cause=%s\n", _utils.AbbrText(aResult.Result, 255), err)
+		for _, item := range b.items {
+			updateCache(item.key, _types.SrcCrawler, crawlerInfo.Name, fmt.Sprintf("$批量爬取结果无法解析(carrier-code=%s,crawler-name=%s)$", b.carrierCode, crawlerInfo.Name), &agentResult{StartTime: aResult.StartTime, EndTime: aResult.EndTime})
+			ack(item.m)
+		}
+		return
+	}
+
+	results := make(map[string]TrackingResult, len(crawlerRsp.Items))
+	for _, tr := range crawlerRsp.Items {
+		results[strings.ToUpper(strings.TrimSpace(tr.TrackingNo))] = tr
+	}
+
+	succeeded := ""
+	for _, item := range b.items {
+		tr, ok := results[strings.ToUpper(item.trackingNo)]
+		if !ok {
+			updateCache(item.key, _types.SrcCrawler, crawlerInfo.Name, fmt.Sprintf("$批量爬取结果缺少运单(carrier-code=%s,crawler-name=%s)$", b.carrierCode, crawlerInfo.Name), &agentResult{StartTime: aResult.StartTime, EndTime: aResult.EndTime})
+			ack(item.m)
+			continue
+		}
+
+		if tr.Code == 0 {
+			// 运单记录中没有返回码，那么采用批量的返回码。
+			tr.Code = AgCode(_utils.AsInt(crawlerRsp.Code, int(AcParseFailed)))
+		}
+		if tr.CMess == "" {
+			tr.CMess = crawlerRsp.Message
+		}
+
+		if v, err := json.Marshal(tr); err != nil {
+			panic(fmt.Errorf("cannot convert tracking result to json, cause=%w", err))
+		} else {
+			updateCache(item.key, _types.SrcCrawler, crawlerInfo.Name, "", &agentResult{StartTime: aResult.StartTime, EndTime: aResult.EndTime, Result: string(v)})
+			ack(item.m)
+		}
+
+		if succeeded == "" && IsSuccess(tr.Code) {
+			succeeded = item.trackingNo
+		}
+	}
+
+	if succeeded != "" {
+		go func() {
+			defer _utils.RecoverPanic()
+
+			if _db.UpgradeHeartBeatNo(agentCtx, crawlerInfo.Id, succeeded) > 0 {
+				log.Printf("[INFO] Update heart-beat-no to %s for %s", succeeded, b.carrierCode)
+			}
+		}()
+	}
+}
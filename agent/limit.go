@@ -0,0 +1,83 @@
+// 该模块定义了调用查询代理时的并发数和每秒请求数限制。
+// 限制来自运输商和查询代理的配置，对所有的查询代理工作进程都有效。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	_cache "com.cne/ai-tracking-search/cache"
+	_db "com.cne/ai-tracking-search/db"
+	_limiter "com.cne/ai-tracking-search/limiter"
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_queue "com.cne/ai-tracking-search/queue"
+	_utils "com.cne/ai-tracking-search/utils"
+)
+
+const (
+	agentTimeout   time.Duration = 60 * time.Second       // 调用查询代理的超时时间，包括读取响应的时间。
+	agentLease     time.Duration = 90 * time.Second       // 调用查询代理占用并发数的租期，大于查询代理的超时时间，调用返回之前不会被自动释放。
+	requeueBackoff time.Duration = 100 * time.Millisecond // 查询对象因为达到限制被放回队列后，工作协程等待的时间。
+)
+
+var (
+	limitRequeued *_metrics.CounterVec // 因为达到限制被放回队列的查询对象数。
+)
+
+func init() {
+	limitRequeued = _metrics.NewCounterVec("tracking_limit_requeued_total", "Number of tracking searches requeued because the carrier or agent was saturated.", "carrier")
+}
+
+func carrierLimit(carrierCode string) _limiter.Limit {
+	cl := _db.QueryCarrierLimitByCarrierCode(agentCtx, carrierCode)
+	return _limiter.Limit{Key: "carrier$" + carrierCode, MaxConcurrency: cl.MaxConcurrency, MaxRps: cl.MaxRps}
+}
+
+func apiLimit(apiInfo *_db.ApiInfoPo) _limiter.Limit {
+	return _limiter.Limit{Key: "api$" + strconv.FormatInt(apiInfo.Id, 10), MaxConcurrency: apiInfo.MaxConcurrency, MaxRps: apiInfo.MaxRps}
+}
+
+func crawlerLimit(crawlerInfo *_db.CrawlerInfoPo) _limiter.Limit {
+	return _limiter.Limit{Key: "crawler$" + strconv.FormatInt(crawlerInfo.Id, 10), MaxConcurrency: crawlerInfo.MaxConcurrency, MaxRps: crawlerInfo.MaxRps}
+}
+
+// 尝试获取调用查询代理的限制。
+// limits 需要同时获取的限制。
+// 返回释放限制的方法，以及是否获取成功。如果限制本身不可用，那么不限制，避免因为Redis故障导致所有查询都无法执行。
+func acquireLimits(limits ..._limiter.Limit) (func(), bool) {
+	token, err := _utils.NewSeqNo()
+	if err != nil {
+		log.Printf("[WARN] Cannot create token of limits. cause=%s\n", err)
+		return func() {}, true
+	}
+
+	if ok, err := _limiter.Acquire(agentCtx, token, agentLease, limits...); err != nil {
+		log.Printf("[WARN] Cannot acquire limits %v. cause=%s\n", limits, err)
+		return func() {}, true
+	} else if !ok {
+		return nil, false
+	} else {
+		return func() {
+			if err := _limiter.Release(agentCtx, token, limits...); err != nil {
+				log.Printf("[WARN] Cannot release limits %v. cause=%s\n", limits, err)
+			}
+		}, true
+	}
+}
+
+// 将查询对象放回队列的末尾，等待其它工作协程再次处理。
+// 放回队列不增加投递次数。如果放回失败，查询对象仍然处于未确认状态，会在可见性超时之后被重新投递。
+// m 出队的消息。
+// key 查询对象在缓存中的键。
+// carrierCode 运输商编号。
+func requeue(m *_queue.Message, key, carrierCode string) {
+	limitRequeued.With(carrierCode).Inc()
+
+	_cache.Update(agentCtx, key, map[string]interface{}{"status": -1})
+	if err := _queue.Nack(agentCtx, m); err != nil {
+		log.Printf("[ERROR] Cannot requeue tracking-search(key=%s). cause=%s\n", key, err)
+	}
+}
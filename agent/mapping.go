@@ -0,0 +1,559 @@
+// 该模块定义了将API响应映射为查询代理事件列表的方法。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_db "com.cne/ai-tracking-search/db"
+)
+
+const (
+	mfJson string = "JSON"
+	mfXml  string = "XML"
+
+	mappedDateFormat string = "2006-01-02 15:04:05" // 映射后的事件日期格式，和查询代理返回的日期格式保持一致。
+)
+
+var (
+	// 未指定日期格式时依次尝试的格式。
+	defaultDateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006/01/02 15:04:05", "2006-01-02 15:04", "2006/01/02 15:04", "2006-01-02"}
+
+	// 将Java风格的日期格式转换为Go风格。
+	javaDateFormatReplacer = strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02", "HH", "15", "mm", "04", "ss", "05", "SSS", "000")
+)
+
+// 按照映射规则将API的响应内容转换为跟踪结果。
+// mapping 映射规则。
+// trackingNo 对应的运单号。
+// body API的响应内容。
+// 返回转换后的跟踪结果。如果转换失败，那么返回码是`AcParseFailed`，并且返回消息包含失败的原因。
+func mapApiResponse(mapping *_db.ApiMappingPo, trackingNo string, body []byte) TrackingResult {
+	result := TrackingResult{TrackingNo: trackingNo, TrackingEventList: []TrackingEvent{}}
+
+	var doc mappingDoc
+	var err error
+	switch strings.ToUpper(strings.TrimSpace(mapping.Format)) {
+	case mfJson, "":
+		doc, err = parseJsonDoc(body)
+	case mfXml:
+		doc, err = parseXmlDoc(body)
+	default:
+		err = fmt.Errorf("unsupported response format: %s", mapping.Format)
+	}
+	if err != nil {
+		return mappingFailed(result, err)
+	}
+
+	loc := time.Local
+	if tz := strings.TrimSpace(mapping.TimeZone); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return mappingFailed(result, fmt.Errorf("illegal time zone %q: %w", tz, err))
+		}
+	}
+
+	// 首先检查响应状态码。
+	if mapping.StatusPath != "" {
+		status, err := doc.value(doc.root(), mapping.StatusPath)
+		if err != nil {
+			return mappingFailed(result, fmt.Errorf("status: %w", err))
+		}
+		if containsStatus(mapping.NoTrackingStatus, status) {
+			result.Code = AcNoTracking
+			result.CMess = status
+			return result
+		}
+		if mapping.SuccessStatus != "" && !containsStatus(mapping.SuccessStatus, status) {
+			result.Code = AcOther
+			result.CMess = fmt.Sprintf("unexpected status: %s", status)
+			return result
+		}
+	}
+
+	events, err := doc.nodes(doc.root(), mapping.EventsPath)
+	if err != nil {
+		return mappingFailed(result, fmt.Errorf("events: %w", err))
+	}
+
+	for i, evt := range events {
+		var dateText, place, details string
+		if dateText, err = doc.value(evt, mapping.DatePath); err != nil {
+			return mappingFailed(result, fmt.Errorf("event #%d date: %w", i, err))
+		}
+		if mapping.PlacePath != "" {
+			// 地点是可选的。
+			place, _ = doc.value(evt, mapping.PlacePath)
+		}
+		if details, err = doc.value(evt, mapping.DetailsPath); err != nil {
+			return mappingFailed(result, fmt.Errorf("event #%d details: %w", i, err))
+		}
+
+		date, err := parseMappedDate(dateText, mapping.DateFormat, loc)
+		if err != nil {
+			return mappingFailed(result, fmt.Errorf("event #%d date: %w", i, err))
+		}
+
+		result.TrackingEventList = append(result.TrackingEventList, TrackingEvent{Date: date.In(time.Local).Format(mappedDateFormat), Place: place, Details: details})
+	}
+
+	if len(result.TrackingEventList) == 0 {
+		result.Code = AcNoTracking
+	} else {
+		result.Code = AcSuccess
+	}
+
+	return result
+}
+
+func mappingFailed(result TrackingResult, err error) TrackingResult {
+	result.Code = AcParseFailed
+	result.CMess = err.Error()
+	result.TrackingEventList = []TrackingEvent{}
+	return result
+}
+
+// 判断逗号分隔的状态码列表中是否包含指定的状态码。
+func containsStatus(list, status string) bool {
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" && s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 按照指定的格式和时区解析事件日期。
+// s 待解析的日期文本。
+// format 日期格式，可以是Go风格、Java风格，或者`unix`、`unixms`。为空时依次尝试默认格式。
+// loc 日期文本所在的时区。
+func parseMappedDate(s, format string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	format = strings.TrimSpace(format)
+	switch strings.ToLower(format) {
+	case "unix":
+		if v, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("illegal unix timestamp %q", s)
+		} else {
+			return time.Unix(v, 0), nil
+		}
+	case "unixms":
+		if v, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("illegal unix timestamp %q", s)
+		} else {
+			return time.UnixMilli(v), nil
+		}
+	case "":
+		for _, f := range defaultDateFormats {
+			if t, err := time.ParseInLocation(f, s, loc); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q with default formats", s)
+	default:
+		if strings.Contains(format, "yyyy") {
+			format = javaDateFormatReplacer.Replace(format)
+		}
+		if t, err := time.ParseInLocation(format, s, loc); err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q with format %q", s, format)
+		} else {
+			return t, nil
+		}
+	}
+}
+
+// 表示可以通过选择器访问的响应文档。
+type mappingDoc interface {
+	// 返回文档的根节点。
+	root() interface{}
+
+	// 返回选择器匹配的所有节点。
+	nodes(cur interface{}, path string) ([]interface{}, error)
+
+	// 返回选择器匹配的第一个节点的文本。如果没有匹配的节点则返回错误。
+	value(cur interface{}, path string) (string, error)
+}
+
+// 表示JSON文档，使用JSONPath风格的选择器。
+// 支持的选择器语法：`$`表示根节点，`.name`或者`['name']`表示字段，`[n]`表示下标，`[*]`或者`.*`表示所有元素（对象按照字段名排序）。
+// 不以`$`开头的选择器相对于当前节点。
+type jsonDoc struct {
+	r interface{}
+}
+
+type jsonStep struct {
+	name     string // 字段名。
+	index    int    // 下标。
+	wildcard bool   // 是否匹配所有元素。
+	isIndex  bool   // 是否是下标。
+}
+
+func parseJsonDoc(body []byte) (*jsonDoc, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var r interface{}
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("cannot parse json: %w", err)
+	}
+
+	return &jsonDoc{r: r}, nil
+}
+
+func (d *jsonDoc) root() interface{} {
+	return d.r
+}
+
+func (d *jsonDoc) nodes(cur interface{}, path string) ([]interface{}, error) {
+	steps, fromRoot, err := parseJsonPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromRoot {
+		cur = d.r
+	}
+
+	current := []interface{}{cur}
+	for _, step := range steps {
+		next := make([]interface{}, 0, len(current))
+		for _, n := range current {
+			switch v := n.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					// 按照字段名的顺序遍历，保证提取的事件顺序是确定的。
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if !step.isIndex {
+					if c, ok := v[step.name]; ok {
+						next = append(next, c)
+					}
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex {
+					i := step.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+
+	// 如果选择器的结果是一个数组，那么展开数组，这样`$.events`和`$.events[*]`是等价的。
+	if len(current) == 1 {
+		if arr, ok := current[0].([]interface{}); ok {
+			return arr, nil
+		}
+	}
+
+	return current, nil
+}
+
+func (d *jsonDoc) value(cur interface{}, path string) (string, error) {
+	nn, err := d.nodes(cur, path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, n := range nn {
+		switch v := n.(type) {
+		case nil:
+			continue
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		default:
+			return "", fmt.Errorf("path %q matched a non-scalar value", path)
+		}
+	}
+
+	return "", fmt.Errorf("path %q matched nothing", path)
+}
+
+// 解析JSONPath风格的选择器。
+// 返回解析后的步骤，以及选择器是否从根节点开始。
+func parseJsonPath(path string) ([]jsonStep, bool, error) {
+	p := strings.TrimSpace(path)
+	if p == "" {
+		return nil, false, fmt.Errorf("empty path")
+	}
+
+	fromRoot := false
+	if strings.HasPrefix(p, "$") {
+		fromRoot = true
+		p = p[1:]
+	}
+
+	steps := make([]jsonStep, 0)
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			if name == "" {
+				return nil, false, fmt.Errorf("illegal path %q: empty field name", path)
+			}
+			if name == "*" {
+				steps = append(steps, jsonStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonStep{name: name})
+			}
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, false, fmt.Errorf("illegal path %q: unclosed bracket", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			if inner == "*" {
+				steps = append(steps, jsonStep{wildcard: true})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonStep{name: inner[1 : len(inner)-1]})
+			} else if i, err := strconv.Atoi(inner); err != nil {
+				return nil, false, fmt.Errorf("illegal path %q: illegal index %q", path, inner)
+			} else {
+				steps = append(steps, jsonStep{index: i, isIndex: true})
+			}
+			p = p[end+1:]
+		default:
+			// 相对路径的第一个字段名可以省略前导的`.`。
+			if len(steps) != 0 || fromRoot {
+				return nil, false, fmt.Errorf("illegal path %q: unexpected %q", path, p[0])
+			}
+			p = "." + p
+		}
+	}
+
+	return steps, fromRoot, nil
+}
+
+// 表示XML文档的节点。
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// 表示XML文档，使用XPath风格的选择器。
+// 支持的选择器语法：`/`开头表示从根节点开始，`//name`表示任意层级的后代，`name[n]`表示第n个（从1开始）匹配的元素，`*`表示所有子元素，`.`表示当前节点，`..`表示父节点，
+// 末尾的`@attr`表示属性，`text()`表示文本。
+type xmlDoc struct {
+	r       *xmlNode
+	parents map[*xmlNode]*xmlNode
+}
+
+func parseXmlDoc(body []byte) (*xmlDoc, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+
+	doc := &xmlDoc{r: &xmlNode{name: "", attrs: map[string]string{}}, parents: map[*xmlNode]*xmlNode{}}
+	stack := []*xmlNode{doc.r}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot parse xml: %w", err)
+		}
+
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			top.children = append(top.children, n)
+			doc.parents[n] = top
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.text.Write(t)
+		}
+	}
+
+	if len(doc.r.children) == 0 {
+		return nil, fmt.Errorf("cannot parse xml: no root element")
+	}
+
+	return doc, nil
+}
+
+func (d *xmlDoc) root() interface{} {
+	return d.r
+}
+
+func (d *xmlDoc) nodes(cur interface{}, path string) ([]interface{}, error) {
+	nn, attr, text, err := d.selectNodes(cur.(*xmlNode), path)
+	if err != nil {
+		return nil, err
+	} else if attr != "" || text {
+		return nil, fmt.Errorf("path %q should select elements", path)
+	}
+
+	result := make([]interface{}, 0, len(nn))
+	for _, n := range nn {
+		result = append(result, n)
+	}
+
+	return result, nil
+}
+
+func (d *xmlDoc) value(cur interface{}, path string) (string, error) {
+	nn, attr, _, err := d.selectNodes(cur.(*xmlNode), path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, n := range nn {
+		if attr != "" {
+			if v, ok := n.attrs[attr]; ok {
+				return v, nil
+			}
+		} else {
+			return strings.TrimSpace(n.text.String()), nil
+		}
+	}
+
+	return "", fmt.Errorf("path %q matched nothing", path)
+}
+
+// 执行XPath风格的选择器。
+// 返回匹配的元素，以及末尾的属性名和是否选择文本。
+func (d *xmlDoc) selectNodes(cur *xmlNode, path string) ([]*xmlNode, string, bool, error) {
+	p := strings.TrimSpace(path)
+	if p == "" {
+		return nil, "", false, fmt.Errorf("empty path")
+	}
+
+	current := []*xmlNode{cur}
+	if strings.HasPrefix(p, "/") {
+		current = []*xmlNode{d.r}
+		if !strings.HasPrefix(p, "//") {
+			p = p[1:]
+		}
+	}
+
+	attr := ""
+	text := false
+	descendant := false
+	for _, part := range strings.Split(p, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			// 连续的`//`表示任意层级的后代。
+			descendant = true
+			continue
+		}
+		if attr != "" || text {
+			return nil, "", false, fmt.Errorf("illegal path %q: attribute or text() should be the last step", path)
+		}
+
+		if part == "." {
+			continue
+		} else if part == ".." {
+			next := make([]*xmlNode, 0, len(current))
+			for _, n := range current {
+				if pn, ok := d.parents[n]; ok {
+					next = append(next, pn)
+				}
+			}
+			current = next
+			continue
+		} else if strings.HasPrefix(part, "@") {
+			attr = part[1:]
+			continue
+		} else if part == "text()" {
+			text = true
+			continue
+		}
+
+		name := part
+		index := 0
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, "", false, fmt.Errorf("illegal path %q: unclosed bracket", path)
+			}
+			v, err := strconv.Atoi(strings.TrimSpace(part[i+1 : len(part)-1]))
+			if err != nil || v < 1 {
+				return nil, "", false, fmt.Errorf("illegal path %q: illegal index %q", path, part[i+1:len(part)-1])
+			}
+			name = part[:i]
+			index = v
+		}
+
+		next := make([]*xmlNode, 0)
+		for _, n := range current {
+			var candidates []*xmlNode
+			if descendant {
+				candidates = xmlDescendants(n)
+			} else {
+				candidates = n.children
+			}
+
+			matched := make([]*xmlNode, 0)
+			for _, c := range candidates {
+				if name == "*" || c.name == name {
+					matched = append(matched, c)
+				}
+			}
+			if index > 0 {
+				if index <= len(matched) {
+					next = append(next, matched[index-1])
+				}
+			} else {
+				next = append(next, matched...)
+			}
+		}
+		current = next
+		descendant = false
+	}
+
+	return current, attr, text, nil
+}
+
+func xmlDescendants(n *xmlNode) []*xmlNode {
+	result := make([]*xmlNode, 0)
+	for _, c := range n.children {
+		result = append(result, c)
+		result = append(result, xmlDescendants(c)...)
+	}
+
+	return result
+}
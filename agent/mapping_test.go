@@ -0,0 +1,395 @@
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	_db "com.cne/ai-tracking-search/db"
+)
+
+func TestJsonDocNodes(t *testing.T) {
+	doc, err := parseJsonDoc([]byte(`{
+		"data": {
+			"events": [{"time": "a"}, {"time": "b"}, {"time": "c"}],
+			"byId": {"z": {"time": "z"}, "a": {"time": "a"}, "m": {"time": "m"}},
+			"it's": 1,
+			"empty": [],
+			"none": null
+		},
+		"code": 200,
+		"ok": true
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path string
+		want []string // 每个匹配的节点的`time`字段，或者标量的文本。
+	}{
+		{"$.data.events", []string{"a", "b", "c"}},
+		{"$.data.events[*]", []string{"a", "b", "c"}},
+		{"$.data.events[1]", []string{"b"}},
+		{"$.data.events[-1]", []string{"c"}},
+		{"$.data.events[5]", []string{}},
+		{"$['data'].events[0]", []string{"a"}},
+		{"$.data.byId.*", []string{"a", "m", "z"}},
+		{"$.data.byId[*]", []string{"a", "m", "z"}},
+		{"$.data.events.*.time", []string{"a", "b", "c"}},
+		{`$.data["it's"]`, []string{"1"}},
+		{"$.data.missing", []string{}},
+		{"$.data.missing.deeper", []string{}},
+		{"$.data.events.time", []string{}},
+		{"$.data.byId[0]", []string{}},
+		{"$.data.empty", []string{}},
+		{"$.code", []string{"200"}},
+		{"$.ok", []string{"true"}},
+	}
+
+	for _, c := range cases {
+		nn, err := doc.nodes(doc.root(), c.path)
+		if err != nil {
+			t.Errorf("nodes(%q) failed: %s", c.path, err)
+			continue
+		}
+
+		got := make([]string, 0, len(nn))
+		for _, n := range nn {
+			if m, ok := n.(map[string]interface{}); ok {
+				got = append(got, m["time"].(string))
+			} else {
+				got = append(got, fmt.Sprint(n))
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("nodes(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+func TestJsonDocValue(t *testing.T) {
+	doc, err := parseJsonDoc([]byte(`{"a": {"b": "x", "n": 1.50, "f": false, "nil": null, "obj": {}, "arr": [null, "y"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]string{
+		"$.a.b":      "x",
+		"a.b":        "x",
+		"$.a.n":      "1.50",
+		"$.a.f":      "false",
+		"$.a.arr":    "y",
+		"$.a.arr[1]": "y",
+	}
+	for path, want := range values {
+		if got, err := doc.value(doc.root(), path); err != nil || got != want {
+			t.Errorf("value(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+
+	// 相对路径从当前节点开始。
+	a, _ := doc.nodes(doc.root(), "$.a")
+	if got, err := doc.value(a[0], "b"); err != nil || got != "x" {
+		t.Errorf("relative value(b) = %q, %v", got, err)
+	}
+
+	errors := map[string]string{
+		"$.a.nil":     "matched nothing",
+		"$.a.missing": "matched nothing",
+		"$.a.obj":     "non-scalar",
+		"":            "empty path",
+		"$.a[":        "unclosed bracket",
+		"$.a[x]":      "illegal index",
+		"$.a..b":      "empty field name",
+		"$a":          "unexpected",
+	}
+	for path, want := range errors {
+		if _, err := doc.value(doc.root(), path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("value(%q) error = %v, want %q", path, err, want)
+		}
+	}
+}
+
+func TestXmlDocSelectors(t *testing.T) {
+	doc, err := parseXmlDoc([]byte(`<?xml version="1.0"?>
+		<rsp code="0">
+			<track no="A1">
+				<event><time>t1</time><info> i1 </info></event>
+				<event><time>t2</time><info>i2</info></event>
+			</track>
+			<other><event><time>t3</time></event></other>
+		</rsp>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{
+		"/rsp/track/event":    2,
+		"//event":             3,
+		"/rsp/*":              2,
+		"/rsp/track/event[2]": 1,
+		"/rsp/track/event[3]": 0,
+		"/rsp/missing/event":  0,
+	}
+	for path, want := range counts {
+		if nn, err := doc.nodes(doc.root(), path); err != nil || len(nn) != want {
+			t.Errorf("nodes(%q) = %d, %v, want %d", path, len(nn), err, want)
+		}
+	}
+
+	values := map[string]string{
+		"/rsp/@code":                   "0",
+		"/rsp/track/@no":               "A1",
+		"/rsp/track/event/info":        "i1",
+		"/rsp/track/event[2]/info":     "i2",
+		"/rsp/track/event[2]/text()":   "",
+		"//event[1]/time":              "t1",
+		"/rsp/track/event/../@no":      "A1",
+		"/rsp/track/./event[2]/time":   "t2",
+		"/rsp/other/event/time/text()": "t3",
+	}
+	for path, want := range values {
+		if got, err := doc.value(doc.root(), path); err != nil || got != want {
+			t.Errorf("value(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+
+	// 相对路径从当前节点开始。
+	events, _ := doc.nodes(doc.root(), "//track/event")
+	if got, err := doc.value(events[1], "time"); err != nil || got != "t2" {
+		t.Errorf("relative value(time) = %q, %v", got, err)
+	}
+	if got, err := doc.value(events[1], "../@no"); err != nil || got != "A1" {
+		t.Errorf("relative value(../@no) = %q, %v", got, err)
+	}
+
+	errors := map[string]string{
+		"/rsp/@missing":       "matched nothing",
+		"/rsp/missing":        "matched nothing",
+		"/rsp/@code/track":    "should be the last step",
+		"/rsp/track/event[0]": "illegal index",
+		"/rsp/track/event[1":  "unclosed bracket",
+	}
+	for path, want := range errors {
+		if _, err := doc.value(doc.root(), path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("value(%q) error = %v, want %q", path, err, want)
+		}
+	}
+	if _, err := doc.nodes(doc.root(), "/rsp/@code"); err == nil {
+		t.Errorf("nodes(/rsp/@code) should fail")
+	}
+
+	if _, err := parseXmlDoc([]byte("   ")); err == nil {
+		t.Errorf("parseXmlDoc of empty document should fail")
+	}
+}
+
+func TestParseMappedDate(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	want := time.Date(2021, 10, 27, 8, 30, 0, 0, shanghai)
+
+	cases := []struct {
+		text, format string
+	}{
+		{"2021-10-27 08:30:00", ""},
+		{"2021-10-27T08:30:00", ""},
+		{"2021/10/27 08:30", ""},
+		{"2021-10-27T00:30:00Z", ""},
+		{"27.10.2021 08:30", "02.01.2006 15:04"},
+		{"27/10/2021 08:30:00", "dd/MM/yyyy HH:mm:ss"},
+		{"1635294600", "unix"},
+		{"1635294600000", "UnixMs"},
+	}
+	for _, c := range cases {
+		if got, err := parseMappedDate(c.text, c.format, shanghai); err != nil || !got.Equal(want) {
+			t.Errorf("parseMappedDate(%q, %q) = %s, %v, want %s", c.text, c.format, got, err, want)
+		}
+	}
+
+	for _, c := range []struct{ text, format string }{{"", ""}, {"yesterday", ""}, {"x", "unix"}, {"2021-10-27", "dd/MM/yyyy"}} {
+		if _, err := parseMappedDate(c.text, c.format, shanghai); err == nil {
+			t.Errorf("parseMappedDate(%q, %q) should fail", c.text, c.format)
+		}
+	}
+}
+
+func TestMapApiResponse(t *testing.T) {
+	// 映射后的日期使用本地时区。
+	local := func(s string) string {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.In(time.Local).Format(mappedDateFormat)
+	}
+
+	jsonMapping := &_db.ApiMappingPo{
+		Format:           "json",
+		EventsPath:       "$.data[0].trackinfo",
+		DatePath:         "checkpoint_date",
+		PlacePath:        "location",
+		DetailsPath:      "tracking_detail",
+		StatusPath:       "$.meta.code",
+		SuccessStatus:    "200",
+		NoTrackingStatus: "4031,4032",
+		DateFormat:       "yyyy-MM-dd HH:mm:ss",
+		TimeZone:         "Asia/Shanghai",
+	}
+	xmlMapping := &_db.ApiMappingPo{
+		Format:      "XML",
+		EventsPath:  "//TrackDetail",
+		DatePath:    "@time",
+		DetailsPath: "Event",
+		StatusPath:  "/TrackResponse/@status",
+		TimeZone:    "UTC",
+	}
+
+	cases := []struct {
+		name    string
+		mapping *_db.ApiMappingPo
+		body    string
+		code    AgCode
+		events  []TrackingEvent
+		message string
+	}{
+		{
+			name:    "json events",
+			mapping: jsonMapping,
+			body: `{"meta": {"code": 200, "message": "Success"}, "data": [{"tracking_number": "LX123", "trackinfo": [
+				{"checkpoint_date": "2021-10-27 08:30:00", "location": "Shanghai", "tracking_detail": "Arrived"},
+				{"checkpoint_date": "2021-10-26 20:00:00", "tracking_detail": "Accepted", "location": null}
+			]}]}`,
+			code: AcSuccess,
+			events: []TrackingEvent{
+				{Date: local("2021-10-27T08:30:00+08:00"), Place: "Shanghai", Details: "Arrived"},
+				{Date: local("2021-10-26T20:00:00+08:00"), Place: "", Details: "Accepted"},
+			},
+		},
+		{
+			name:    "json no tracking status",
+			mapping: jsonMapping,
+			body:    `{"meta": {"code": 4031}, "data": []}`,
+			code:    AcNoTracking,
+			events:  []TrackingEvent{},
+			message: "4031",
+		},
+		{
+			name:    "json unexpected status",
+			mapping: jsonMapping,
+			body:    `{"meta": {"code": 500}}`,
+			code:    AcOther,
+			events:  []TrackingEvent{},
+			message: "unexpected status: 500",
+		},
+		{
+			name:    "json empty events",
+			mapping: jsonMapping,
+			body:    `{"meta": {"code": 200}, "data": [{"trackinfo": []}]}`,
+			code:    AcNoTracking,
+			events:  []TrackingEvent{},
+		},
+		{
+			name:    "json missing status",
+			mapping: jsonMapping,
+			body:    `{"data": []}`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "status: path \"$.meta.code\" matched nothing",
+		},
+		{
+			name:    "json missing details",
+			mapping: jsonMapping,
+			body:    `{"meta": {"code": 200}, "data": [{"trackinfo": [{"checkpoint_date": "2021-10-27 08:30:00"}]}]}`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "event #0 details",
+		},
+		{
+			name:    "json illegal date",
+			mapping: jsonMapping,
+			body:    `{"meta": {"code": 200}, "data": [{"trackinfo": [{"checkpoint_date": "27/10/2021", "tracking_detail": "x"}]}]}`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "event #0 date",
+		},
+		{
+			name:    "json malformed",
+			mapping: jsonMapping,
+			body:    `<html>502 Bad Gateway</html>`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "cannot parse json",
+		},
+		{
+			name:    "json object of events",
+			mapping: &_db.ApiMappingPo{EventsPath: "$.events.*", DatePath: "t", DetailsPath: "d", DateFormat: "unix", TimeZone: "UTC"},
+			body:    `{"events": {"e3": {"t": 1635294600, "d": "third"}, "e1": {"t": 1635294600, "d": "first"}, "e2": {"t": 1635294600, "d": "second"}}}`,
+			code:    AcSuccess,
+			events: []TrackingEvent{
+				{Date: local("2021-10-27T00:30:00Z"), Details: "first"},
+				{Date: local("2021-10-27T00:30:00Z"), Details: "second"},
+				{Date: local("2021-10-27T00:30:00Z"), Details: "third"},
+			},
+		},
+		{
+			name:    "xml events",
+			mapping: xmlMapping,
+			body: `<TrackResponse status="OK"><Package id="LX123">
+				<TrackDetail time="2021-10-27T00:30:00Z"><Event>Delivered</Event></TrackDetail>
+				<TrackDetail time="2021-10-26 12:00:00"><Event>Out for delivery</Event></TrackDetail>
+			</Package></TrackResponse>`,
+			code: AcSuccess,
+			events: []TrackingEvent{
+				{Date: local("2021-10-27T00:30:00Z"), Details: "Delivered"},
+				{Date: local("2021-10-26T12:00:00Z"), Details: "Out for delivery"},
+			},
+		},
+		{
+			name:    "xml malformed",
+			mapping: xmlMapping,
+			body:    `{"status": "OK"}`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "no root element",
+		},
+		{
+			name:    "unsupported format",
+			mapping: &_db.ApiMappingPo{Format: "csv"},
+			body:    `a,b,c`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "unsupported response format",
+		},
+		{
+			name:    "illegal time zone",
+			mapping: &_db.ApiMappingPo{EventsPath: "$", TimeZone: "Mars/Olympus"},
+			body:    `[]`,
+			code:    AcParseFailed,
+			events:  []TrackingEvent{},
+			message: "illegal time zone",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := mapApiResponse(c.mapping, "LX123", []byte(c.body))
+			if got.TrackingNo != "LX123" {
+				t.Errorf("tracking no = %q", got.TrackingNo)
+			}
+			if got.Code != c.code {
+				t.Errorf("code = %d, want %d (message %q)", got.Code, c.code, got.CMess)
+			}
+			if !strings.Contains(got.CMess, c.message) {
+				t.Errorf("message = %q, want %q", got.CMess, c.message)
+			}
+			if !reflect.DeepEqual(got.TrackingEventList, c.events) {
+				t.Errorf("events = %v, want %v", got.TrackingEventList, c.events)
+			}
+		})
+	}
+}
@@ -1,29 +1,32 @@
 // 该模块定义了轮询查询代理的方法。
 // @Author: Haart
 // @Created: 2021-10-27
-// TODO: 该模块可以被提取为单独的应用。
+// 该模块运行在查询代理工作进程中，参见`cmd/tracking-agent`。
 package agent
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"net/http"
 	_url "net/url"
 
 	_cache "com.cne/ai-tracking-search/cache"
+	_config "com.cne/ai-tracking-search/config"
 	_db "com.cne/ai-tracking-search/db"
+	_metrics "com.cne/ai-tracking-search/metrics"
 	_queue "com.cne/ai-tracking-search/queue"
 	_types "com.cne/ai-tracking-search/types"
 	_utils "com.cne/ai-tracking-search/utils"
-	"github.com/go-redis/redis/v8"
 )
 
 type agentResult struct {
@@ -33,8 +36,9 @@ type agentResult struct {
 }
 
 const (
-	trackingSearchKeyPrefix string = "TRACKING_SEARCH" // 缓存中的查询记录的Key的前缀。
-	trackingQueueKey        string = "TRACKING_QUEUE"  // 查询记录队列Key。
+	trackingSearchKeyPrefix string = "TRACKING_SEARCH"               // 缓存中的查询记录的Key的前缀。
+	trackingQueueKey        string = "TRACKING_QUEUE"                // 查询记录队列Key。
+	trackingCompletedTopic  string = trackingQueueKey + "$Completed" // 完成队列的主题，查询接口服务的持久化协程从此队列获取结果并保存到数据库。
 
 	ctPython string = "PYTHON"
 	ctJava   string = "JAVA"
@@ -42,129 +46,355 @@ const (
 )
 
 var (
+	agentCtx context.Context // 查询代理工作进程访问缓存、队列和数据库时使用的上下文。
+
 	allPriorities []_types.Priority // 所有消息队列的主题。
 
-	pollingBatchSize int // 轮询时每次处理的批量。
+	workerConcurrency map[_types.Priority]int // 每个优先级的工作协程数。
+
+	workersTotal         *_metrics.GaugeVec   // 每个优先级的工作协程数。
+	workersBusy          *_metrics.GaugeVec   // 每个优先级正在处理查询对象的工作协程数。
+	workersBusySeconds   *_metrics.CounterVec // 每个优先级的工作协程处理查询对象的累计时间。
+	workersPolledSearchs *_metrics.CounterVec // 每个优先级的工作协程从各个队列中获取的查询对象数。
+	searchsAbandoned     *_metrics.Counter    // 因为查询接口服务不再等待而跳过的查询对象数。
+
+	agentCalls       *_metrics.CounterVec   // 每个运输商和查询代理的调用次数，按照返回码分类。
+	resultsDropped   *_metrics.Counter      // 因为查询对象已经过期而丢弃的查询代理结果数。
+	agentCallSeconds *_metrics.HistogramVec // 每个运输商和查询代理的调用延迟。
+)
+
+var (
+	// 调用查询代理使用的HTTP客户端。超时时间小于限制的租期和可见性超时，查询代理无响应时不会在调用返回之前重复执行同一个查询。
+	agentClient = &http.Client{Timeout: agentTimeout}
+)
+
+// 使用GET方式调用查询代理，退出时中止调用。
+// url 查询代理的地址。
+func agentGet(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(workCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return agentClient.Do(req)
+}
+
+// 使用POST方式调用查询代理，请求体是JSON，退出时中止调用。
+// url 查询代理的地址。
+// dataJson 请求体。
+func agentPost(url, dataJson string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(workCtx, http.MethodPost, url, strings.NewReader(dataJson))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return agentClient.Do(req)
+}
+
+const (
+	pollTimeout      time.Duration = 5 * time.Second // 阻塞出队的超时时间。超时后重新出队，这样可以及时发现连接问题。
+	pollErrorBackoff time.Duration = 1 * time.Second // 队列不可用时重试的间隔。
+)
+
+var (
+	// 以下参数可以在运行时修改，使用原子操作访问，单位是纳秒。
+	batchWindow      int64 = int64(time.Duration(_config.DefaultWorkerBatchWindow) * time.Millisecond) // 批量调用查询代理时收集查询对象的时间窗口。
+	replyExpiration  int64 = int64(time.Duration(_config.DefaultWorkerReplyExpiration) * time.Second)  // 完成通知列表的过期时间。查询接口服务不再等待时，列表自动过期。
+	resultExpiration int64 = int64(time.Duration(_config.DefaultWorkerResultExpiration) * time.Second) // 查询结果在缓存中的过期时间。
 )
 
 func init() {
+	agentCtx = context.Background()
+
 	allPriorities = []_types.Priority{_types.PriorityHighest, _types.PriorityHigh, _types.PriorityLow}
+
+	workersTotal = _metrics.NewGaugeVec("tracking_worker_total", "Number of polling workers.", "priority")
+	workersBusy = _metrics.NewGaugeVec("tracking_worker_busy", "Number of polling workers processing a tracking search.", "priority")
+	workersBusySeconds = _metrics.NewCounterVec("tracking_worker_busy_seconds_total", "Total seconds polling workers spent processing tracking searches.", "priority")
+	workersPolledSearchs = _metrics.NewCounterVec("tracking_worker_polled_total", "Number of tracking searches polled by workers.", "priority", "queue")
+	searchsAbandoned = _metrics.NewCounter("tracking_search_abandoned_skipped_total", "Number of abandoned tracking searches skipped by workers.")
+
+	agentCalls = _metrics.NewCounterVec("tracking_agent_calls_total", "Number of agent calls by carrier, agent, agent code and outcome.", "carrier", "agent", "ag_code", "outcome")
+	resultsDropped = _metrics.NewCounter("tracking_agent_result_dropped_total", "Number of agent results dropped because the tracking search expired from the cache.")
+	agentCallSeconds = _metrics.NewHistogramVec("tracking_agent_call_seconds", "Seconds spent calling agents by carrier and agent.", []float64{.1, .25, .5, 1, 2.5, 5, 10, 15, 30, 60}, "carrier", "agent")
+}
+
+// 初始化轮询参数。
+// concurrency 每个优先级的工作协程数。每个优先级的工作协程优先处理此优先级的查询对象，空闲时按照调度器的顺序处理其它优先级的查询对象。
+// weights 每个优先级的调度权重。
+// agingSeconds 查询对象等待超过此时间（秒）后，所在的队列会被提升到最前。0表示不提升。
+// batchWindow_ 批量调用查询代理时收集查询对象的时间窗口（毫秒）。
+// visibilityTimeout_ 已出队的查询对象超过此时间（秒）仍未确认，会被重新投递。
+// maxDeliveries_ 查询对象的最大投递次数，超过此次数的查询对象被转移到死信队列。
+func InitAgent(concurrency map[_types.Priority]int, weights map[_types.Priority]int, agingSeconds int, batchWindow_ int, visibilityTimeout_ int, maxDeliveries_ int) error {
+	total := 0
+	for _, p := range allPriorities {
+		c := concurrency[p]
+		if c < 0 {
+			return fmt.Errorf("concurrency of priority %s should not be negative, but %d", p.String(), c)
+		}
+		if weights[p] < 0 {
+			return fmt.Errorf("weight of priority %s should not be negative, but %d", p.String(), weights[p])
+		}
+		total += c
+	}
+	if total <= 4 {
+		return fmt.Errorf("total concurrency should larger than 4, but %d", total)
+	}
+	if total > 5000 {
+		return fmt.Errorf("total concurrency should not larger than 5000, but %d", total)
+	}
+	if agingSeconds < 0 {
+		return fmt.Errorf("aging seconds should not be negative, but %d", agingSeconds)
+	}
+	if batchWindow_ < 0 || batchWindow_ > 5000 {
+		return fmt.Errorf("batch window should between 0 and 5000 milliseconds, but %d", batchWindow_)
+	}
+	if time.Duration(visibilityTimeout_)*time.Second <= agentLease {
+		return fmt.Errorf("visibility timeout should larger than %d seconds, but %d", int(agentLease.Seconds()), visibilityTimeout_)
+	}
+	if maxDeliveries_ <= 0 {
+		return fmt.Errorf("max deliveries should be positive, but %d", maxDeliveries_)
+	}
+
+	workerConcurrency = concurrency
+	sched = newScheduler(weights, time.Duration(agingSeconds)*time.Second)
+	atomic.StoreInt64(&batchWindow, int64(time.Duration(batchWindow_)*time.Millisecond))
+	visibilityTimeout = time.Duration(visibilityTimeout_) * time.Second
+	maxDeliveries = maxDeliveries_
+
+	return nil
 }
 
-func InitAgent(pollingBatchSize_ int) error {
-	if pollingBatchSize_ <= 4 {
-		return fmt.Errorf("polling batch size should larger than 4, but %d", pollingBatchSize_)
+// 按照工作进程配置初始化轮询参数。
+// worker 工作进程配置。
+func Configure(worker *_config.WorkerConfiguration) error {
+	concurrency := map[_types.Priority]int{
+		_types.PriorityHighest: worker.Concurrency.Highest,
+		_types.PriorityHigh:    worker.Concurrency.High,
+		_types.PriorityLow:     worker.Concurrency.Low,
 	}
-	if pollingBatchSize_ > 5000 {
-		return fmt.Errorf("polling batch size should not larger than 5000, but %d", pollingBatchSize_)
+	weights := map[_types.Priority]int{
+		_types.PriorityHighest: worker.Weights.Highest,
+		_types.PriorityHigh:    worker.Weights.High,
+		_types.PriorityLow:     worker.Weights.Low,
+	}
+	if err := InitAgent(concurrency, weights, worker.AgingSeconds, worker.BatchWindow, worker.VisibilityTimeout, worker.MaxDeliveries); err != nil {
+		return err
 	}
 
-	pollingBatchSize = pollingBatchSize_
+	return Reconfigure(worker)
+}
+
+// 按照工作进程配置修改可以在运行时修改的参数，只影响之后收集的批量和写入的缓存。
+// worker 工作进程配置。
+func Reconfigure(worker *_config.WorkerConfiguration) error {
+	if worker.BatchWindow < 0 || worker.BatchWindow > 5000 {
+		return fmt.Errorf("batch window should between 0 and 5000 milliseconds, but %d", worker.BatchWindow)
+	}
+	if worker.ReplyExpiration <= 0 {
+		return fmt.Errorf("reply expiration should be positive, but %d", worker.ReplyExpiration)
+	}
+	if worker.ResultExpiration <= 0 {
+		return fmt.Errorf("result expiration should be positive, but %d", worker.ResultExpiration)
+	}
+
+	atomic.StoreInt64(&batchWindow, int64(time.Duration(worker.BatchWindow)*time.Millisecond))
+	atomic.StoreInt64(&replyExpiration, int64(time.Duration(worker.ReplyExpiration)*time.Second))
+	atomic.StoreInt64(&resultExpiration, int64(time.Duration(worker.ResultExpiration)*time.Second))
 
 	return nil
 }
 
 // 启动轮询。
+// 为每个优先级启动固定数量的工作协程，每个工作协程阻塞地从队列中获取查询对象，没有查询对象时不占用CPU。
+// 同时启动重新投递超时查询对象的协程。
 func PollForEver() {
-	chs := make([]chan int, pollingBatchSize)
-	cases := make([]reflect.SelectCase, pollingBatchSize)
-	for i := 0; i < pollingBatchSize; i++ {
-		ch := make(chan int)
-		chs[i] = ch
-		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
-		go func() { ch <- 1 }() // 将协程标记为就绪。
+	topics := make([]string, 0, len(allPriorities))
+	for _, p := range allPriorities {
+		topics = append(topics, trackingQueueKey+"$"+p.String())
+	}
+	if err := _queue.Declare(agentCtx, topics...); err != nil {
+		log.Printf("[WARN] Cannot declare queues %v. cause=%s\n", topics, err)
 	}
 
-	doPollOne := func(i int) {
-		go func() {
-			defer func() {
-				_utils.RecoverPanic()
+	go reclaimForEver(topics)
 
-				chs[i] <- 1 // 将协程标记为就绪。
-			}()
-			pollOne()
-			time.Sleep(400 * time.Millisecond) // 喘口气 ^_^
-		}()
-	}
+	for _, p := range allPriorities {
+		workersTotal.With(p.String()).Set(float64(workerConcurrency[p]))
 
-	// 轮询，任何一个协程就绪，就启动。
-	for {
-		i, _, _ := reflect.Select(cases)
-		doPollOne(i)
+		for i := 0; i < workerConcurrency[p]; i++ {
+			workers.Add(1)
+			go pollWorker(p)
+		}
 	}
+
+	// 直到开始退出。
+	<-pollCtx.Done()
 }
 
-func pollOne() {
-	// 依次从不同的优先级队列中获取任务。
-	p, key := nextKey()
+func pollWorker(priority _types.Priority) {
+	defer workers.Done()
 
-	if p != -1 {
-		seqNo := key[len(trackingSearchKeyPrefix)+1:]
+	for pollCtx.Err() == nil {
+		func() {
+			defer _utils.RecoverPanic()
 
-		if os, err := _cache.Get(key, "reqTime", "carrierCode", "language", "trackingNo", "postcode", "dest", "date"); err != nil {
-			if errors.Is(err, redis.Nil) {
-				// 缓存中的查询请求已消失。
-				log.Printf("[ERROR] Cannot get tracking-search(key=%s) from cache\n", key)
-				updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$缓存丢失查询对象(seq-no=%s)$", seqNo), &agentResult{})
+			if m, err := _queue.BPop(pollCtx, pollTimeout, sched.topics(priority)...); err != nil {
+				if pollCtx.Err() != nil {
+					// 正在退出。
+					return
+				}
+				if !errors.Is(err, _queue.Nil) {
+					// 队列本身不可用。
+					log.Printf("[ERROR] Cannot poll tracking-search from queue. cause=%s\n", err)
+					time.Sleep(pollErrorBackoff)
+				}
+			} else if pollCtx.Err() != nil {
+				// 开始退出时阻塞出队刚好返回了查询对象，放回队列由其它进程处理。
+				if err := _queue.Nack(agentCtx, m); err != nil {
+					log.Printf("[WARN] Cannot push back tracking-search(key=%s). cause=%s\n", m.Value, err)
+				}
 			} else {
-				// 缓存本身不可用。
-				log.Printf("[ERROR] Cannot get tracking-search(key=%s) from cache. cause=%s\n", key, err)
-				updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$缓存不可用(seq-no=%s)$", seqNo), &agentResult{})
+				queueName := m.Topic[len(trackingQueueKey)+1:]
+				workersPolledSearchs.With(priority.String(), queueName).Inc()
+
+				busy := workersBusy.With(priority.String())
+				busy.Inc()
+				startTime := time.Now()
+				defer func() {
+					busy.Dec()
+					workersBusySeconds.With(priority.String()).Add(time.Since(startTime).Seconds())
+				}()
+
+				// 如果处理过程中发生panic，那么报告失败，查询对象稍后会被重新投递。
+				// 批量处理的查询对象在批量处理完毕后才结束处理。
+				inflight.Add(m)
+				outcome := pollFailed
+				defer func() {
+					if outcome == pollFailed && workCtx.Err() != nil {
+						// 退出时中止了处理，查询对象由 Shutdown 放回队列。
+						return
+					}
+					if outcome == pollFailed {
+						fail(m, "worker panicked")
+					}
+					if outcome != pollDeferred {
+						inflight.Done(m)
+					}
+				}()
+
+				outcome = pollOne(queueName, m)
+				switch outcome {
+				case pollDone:
+					ack(m)
+				case pollRequeued:
+					// 查询对象被放回队列，稍等片刻，避免反复获取同一个查询对象。
+					time.Sleep(requeueBackoff)
+				}
 			}
+		}()
+	}
+}
+
+// 处理一个查询对象。
+// queueName 查询对象所在队列的优先级名。
+// m 出队的消息，值是查询对象在缓存中的键。
+// 返回处理的结果。
+func pollOne(queueName string, m *_queue.Message) pollOutcome {
+	key := m.Value
+	seqNo := key[len(trackingSearchKeyPrefix)+1:]
+
+	if os, err := _cache.Get(agentCtx, key, "reqTime", "carrierCode", "language", "trackingNo", "postcode", "dest", "date", "abandoned", "flight"); err != nil {
+		if errors.Is(err, _cache.Nil) {
+			// 缓存中的查询请求已消失。
+			log.Printf("[ERROR] Cannot get tracking-search(key=%s) from cache\n", key)
+			updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$缓存丢失查询对象(seq-no=%s)$", seqNo), &agentResult{})
 		} else {
-			reqTime := _utils.AsTime(os[0])
-			carrierCode := _utils.AsString(os[1])
+			// 缓存本身不可用。
+			log.Printf("[ERROR] Cannot get tracking-search(key=%s) from cache. cause=%s\n", key, err)
+			updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$缓存不可用(seq-no=%s)$", seqNo), &agentResult{})
+		}
+	} else {
+		reqTime := _utils.AsTime(os[0])
+		carrierCode := _utils.AsString(os[1])
 
-			var language _types.LangId
-			if v, err := _types.ParseLangId(_utils.AsString(os[2])); err != nil {
-				log.Printf("[WARN] Illegal language: %v\n", os[2])
-			} else {
-				language = v
-			}
-			trackingNo := _utils.AsString(os[3])
+		if !_utils.IsZeroTime(reqTime) {
+			queueWaitSeconds.With(queueName).Observe(time.Since(reqTime).Seconds())
+		}
 
-			postcode := _utils.AsString((os[4]))
-			dest := _utils.AsString(os[5])
-			date := _utils.AsString(os[6])
+		// 查询接口服务已经不再等待，并且没有仍在等待的跟随者，那么不再调用查询代理。
+		if _utils.AsString(os[7]) == "1" && !hasWaitingFollowers(key, _utils.AsString(os[8])) {
+			searchsAbandoned.Inc()
+			return pollDone
+		}
 
-			// 尝试找API，如果找不到API，那么找爬虫。
-			apiInfo := _db.QueryApiInfoByCarrierCode(carrierCode, reqTime)
-			if apiInfo != nil {
-				apiParams := _db.QueryApiParamsByApiId(apiInfo.Id)
-				callApi(key, apiInfo, apiParams, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
-			} else {
-				// 查询对应的查询代理和参数。
-				crawlerInfo := _db.QueryCrawlerInfoByCarrierCode(carrierCode, reqTime)
-
-				if crawlerInfo != nil {
-					callCrawler(key, crawlerInfo, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
-				} else {
-					log.Printf("[WARN] Cannot find suitable agent for carrier[%s] at %s\n", carrierCode, reqTime)
-					updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$没有匹配到查询代理(carrier-code=%s)$", carrierCode), &agentResult{})
-				}
-			}
+		var language _types.LangId
+		if v, err := _types.ParseLangId(_utils.AsString(os[2])); err != nil {
+			log.Printf("[WARN] Illegal language: %v\n", os[2])
+		} else {
+			language = v
 		}
-	}
-}
+		trackingNo := _utils.AsString(os[3])
+
+		postcode := _utils.AsString((os[4]))
+		dest := _utils.AsString(os[5])
+		date := _utils.AsString(os[6])
+
+		// 尝试找API，如果找不到API，那么找爬虫。
+		apiInfo := _db.QueryApiInfoByCarrierCode(agentCtx, carrierCode, reqTime)
+		if apiInfo != nil {
+			// 运输商或者API达到限制时，放回队列，处理其它查询对象。
+			release, ok := acquireLimits(carrierLimit(carrierCode), apiLimit(apiInfo))
+			if !ok {
+				requeue(m, key, carrierCode)
+				return pollRequeued
+			}
+			defer release()
 
-func nextKey() (_types.Priority, string) {
-	for _, p := range allPriorities {
-		if result, err := _queue.Pop(trackingQueueKey + "$" + p.String()); err != nil {
-			if errors.Is(err, redis.Nil) {
-				continue
+			apiParams := _db.QueryApiParamsByApiId(agentCtx, apiInfo.Id)
+			callApi(key, apiInfo, apiParams, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
+		} else {
+			// 查询对应的查询代理和参数。
+			crawlerInfo := _db.QueryCrawlerInfoByCarrierCode(agentCtx, carrierCode, reqTime)
+
+			if crawlerInfo != nil && isBatchable(crawlerInfo, postcode, dest, date) {
+				// 批量调用查询代理时才获取限制。
+				submitBatch(m, key, crawlerInfo, seqNo, carrierCode, language, trackingNo)
+				return pollDeferred
+			} else if crawlerInfo != nil {
+				// 运输商或者爬虫达到限制时，放回队列，处理其它查询对象。
+				release, ok := acquireLimits(carrierLimit(carrierCode), crawlerLimit(crawlerInfo))
+				if !ok {
+					requeue(m, key, carrierCode)
+					return pollRequeued
+				}
+				defer release()
+
+				callCrawler(key, crawlerInfo, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
 			} else {
-				panic(err)
+				log.Printf("[WARN] Cannot find suitable agent for carrier[%s] at %s\n", carrierCode, reqTime)
+				updateCache(key, _types.SrcUnknown, "", fmt.Sprintf("$没有匹配到查询代理(carrier-code=%s)$", carrierCode), &agentResult{})
 			}
-		} else {
-			return p, result
 		}
 	}
 
-	return -1, ""
+	return pollDone
 }
 
 func callApi(key string, apiInfo *_db.ApiInfoPo, apiParams []*_db.ApiParamPo, seqNo, carrierCode string, language _types.LangId, trackingNo, postcode, dest, date string) {
-	_cache.Update(key, map[string]interface{}{"status": 0})
+	_cache.Update(agentCtx, key, map[string]interface{}{"status": 0})
+
+	// 如果存在响应映射规则，那么直接调用API。
+	if apiMapping := _db.QueryApiMappingByApiId(agentCtx, apiInfo.Id); apiMapping != nil {
+		if aResult, err := callApiDirectly(apiInfo, apiParams, apiMapping, seqNo, carrierCode, language, trackingNo); err != nil {
+			checkAborted(err)
+			log.Printf("[WARN]: Cannot call api directly. cause=%s\n", err)
+			updateCache(key, _types.SrcAPI, apiInfo.Name, fmt.Sprintf("$调用API失败(carrier-code=%s,api-name=%s)$", carrierCode, apiInfo.Name), &agentResult{})
+		} else {
+			updateCache(key, _types.SrcAPI, apiInfo.Name, "", aResult)
+		}
+		return
+	}
 
 	url := apiInfo.Url + "/fetchTrackInfoList"
 
@@ -209,19 +439,21 @@ func callApi(key string, apiInfo *_db.ApiInfoPo, apiParams []*_db.ApiParamPo, se
 	// 固定使用POST方式调用Python查询代理。
 	aResult := &agentResult{StartTime: time.Now()}
 
-	if rsp, err := http.Post(url, "application/json", strings.NewReader(dataJson)); err != nil {
+	if rsp, err := agentPost(url, dataJson); err != nil {
 		// 查询代理不可用。
+		checkAborted(err)
 		log.Printf("[WARN]: Cannot call api {api-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}. cause=%s",
 			apiInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
 		updateCache(key, _types.SrcCrawler, apiInfo.Name, fmt.Sprintf("$调用API失败(carrier-code=%s,api-name=%s)$", carrierCode, apiInfo.Name), &agentResult{})
 	} else {
 		buf := strings.Builder{}
 		if _, err := io.Copy(&buf, rsp.Body); err != nil {
+			checkAborted(err)
 			log.Printf("[WARN]: Cannot read response from api {api-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}",
 				apiInfo.Name, carrierCode, language.String(), trackingNo, seqNo)
 		} else {
 			aResult.EndTime = time.Now()
-			aResult.Result = strings.ReplaceAll(strings.ReplaceAll(buf.String(), "'", "\""), "None", "\"\"") // Python查询代理返回的json格式字符串不合规，需要兼容。
+			aResult.Result = pythonResultToJson(buf.String()) // Python查询代理返回的json格式字符串不合规，需要兼容。
 
 			updateCache(key, _types.SrcAPI, apiInfo.Name, "", aResult)
 		}
@@ -229,7 +461,7 @@ func callApi(key string, apiInfo *_db.ApiInfoPo, apiParams []*_db.ApiParamPo, se
 }
 
 func callCrawler(key string, crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode string, language _types.LangId, trackingNo, postcode, dest, date string) {
-	_cache.Update(key, map[string]interface{}{"status": 0})
+	_cache.Update(agentCtx, key, map[string]interface{}{"status": 0})
 
 	var aResult *agentResult
 	var cErr error
@@ -237,6 +469,7 @@ func callCrawler(key string, crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode
 		// 调用python查询代理。
 		aResult, cErr = callCrawlerByPython(crawlerInfo, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
 		if cErr != nil {
+			checkAborted(cErr)
 			log.Printf("[WARN]: Cannot call python crawler. cause=%s\n", cErr)
 			updateCache(key, _types.SrcCrawler, crawlerInfo.Name, fmt.Sprintf("$调用Python爬虫失败(carrier-code=%s,crawler-name=%s)$", carrierCode, crawlerInfo.Name), &agentResult{})
 		}
@@ -244,6 +477,7 @@ func callCrawler(key string, crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode
 		// 调用Go查询代理。
 		aResult, cErr = callCrawlerByGolang(crawlerInfo, seqNo, carrierCode, language, trackingNo, postcode, dest, date)
 		if cErr != nil {
+			checkAborted(cErr)
 			log.Printf("[WARN]: Cannot call golang crawler. cause=%s\n", cErr)
 			updateCache(key, _types.SrcCrawler, crawlerInfo.Name, fmt.Sprintf("$调用GO爬虫失败(carrier-code=%s,crawler-name=%s)$", carrierCode, crawlerInfo.Name), &agentResult{})
 		}
@@ -256,13 +490,120 @@ func callCrawler(key string, crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode
 		updateCache(key, _types.SrcCrawler, crawlerInfo.Name, "", aResult)
 
 		go func() {
-			if _db.UpgradeHeartBeatNo(crawlerInfo.Id, trackingNo) > 0 {
+			defer _utils.RecoverPanic()
+
+			if _db.UpgradeHeartBeatNo(agentCtx, crawlerInfo.Id, trackingNo) > 0 {
 				log.Printf("[INFO] Update heart-beat-no to %s for %s", trackingNo, carrierCode)
 			}
 		}()
 	}
 }
 
+// 直接调用API，并按照映射规则转换API的响应。
+// 参数值中的`{trackingNo}`和`{lan}`会被替换为运单号和语言；请求头参数作为HTTP头部，请求体参数作为JSON请求体，其它参数作为查询字符串。
+// 返回的查询代理结果是序列化为JSON的跟踪结果，和查询代理返回的格式一致。
+func callApiDirectly(apiInfo *_db.ApiInfoPo, apiParams []*_db.ApiParamPo, apiMapping *_db.ApiMappingPo, seqNo, carrierCode string, language _types.LangId, trackingNo string) (*agentResult, error) {
+	url := apiInfo.Url
+	timeout := 25
+	headers := map[string]string{}
+	body := map[string]interface{}{}
+	query := _url.Values{}
+
+	expand := func(v string) string {
+		v = strings.ReplaceAll(v, "{trackingNo}", trackingNo)
+		v = strings.ReplaceAll(v, "{lan}", strings.ToLower(language.String()))
+		return v
+	}
+
+	for _, ap := range apiParams {
+		if ap.NeedCrypt {
+			return nil, fmt.Errorf("encrypted api param is not supported {api-name=%s, field-name=%s}", apiInfo.Name, ap.FieldName)
+		}
+
+		if ap.FieldName == "reqUrl" {
+			url = expand(ap.FieldValue)
+		} else if ap.FieldName == "reqTimeout" {
+			timeout = _utils.AsInt(ap.FieldValue, timeout)
+		} else if ap.FieldName == "siteAnalyzedName" || ap.FieldName == "siteCrawlingName" || ap.FieldName == "reqProxy" {
+			// 这些参数只用于Python查询代理。
+			continue
+		} else if ap.IsHead {
+			headers[ap.FieldName] = expand(ap.FieldValue)
+		} else if ap.IsBody {
+			body[ap.FieldName] = expand(ap.FieldValue)
+		} else {
+			query.Set(ap.FieldName, expand(ap.FieldValue))
+		}
+	}
+
+	if len(query) != 0 {
+		if strings.Contains(url, "?") {
+			url = url + "&" + query.Encode()
+		} else {
+			url = url + "?" + query.Encode()
+		}
+	}
+
+	var req *http.Request
+	var err error
+	if apiInfo.ReqHttpType == 2 {
+		var bodyJson []byte
+		if bodyJson, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("cannot convert api params to json, cause=%w", err)
+		}
+		if req, err = http.NewRequestWithContext(workCtx, http.MethodPost, url, bytes.NewReader(bodyJson)); err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(workCtx, http.MethodGet, url, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create api request {api-name=%s, url=%s}. cause=%w", apiInfo.Name, url, err)
+	}
+	for hk, hv := range headers {
+		req.Header.Set(hk, hv)
+	}
+
+	log.Printf("[DEBUG] API processing {seq-no: %s, carrier-code: %s, tracking-no: %s} from %s\n", seqNo, carrierCode, trackingNo, url)
+
+	result := agentResult{StartTime: time.Now()}
+	// API的超时时间来自配置，但是不能超过调用查询代理的超时时间。
+	client := http.Client{Timeout: time.Duration(timeout) * time.Second}
+	if client.Timeout <= 0 || client.Timeout > agentTimeout {
+		client.Timeout = agentTimeout
+	}
+	if rsp, err := client.Do(req); err != nil {
+		return &result, fmt.Errorf("cannot call api {api-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}. cause=%w",
+			apiInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
+	} else {
+		defer rsp.Body.Close()
+
+		if rspBody, err := io.ReadAll(rsp.Body); err != nil {
+			return &result, fmt.Errorf("cannot read response from api {api-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}. cause=%w",
+				apiInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
+		} else {
+			result.EndTime = time.Now()
+
+			var trackingResult TrackingResult
+			if rsp.StatusCode != http.StatusOK {
+				trackingResult = TrackingResult{Code: AcOther, CMess: fmt.Sprintf("unexpected http status: %d", rsp.StatusCode), TrackingNo: trackingNo}
+			} else {
+				trackingResult = mapApiResponse(apiMapping, trackingNo, rspBody)
+			}
+			if trackingResult.Code == AcParseFailed {
+				log.Printf("[WARN] Cannot map api response {api-name=%s, tracking-no=%s}: %s. body=%s\n", apiInfo.Name, trackingNo, trackingResult.CMess, _utils.AbbrText(string(rspBody), 255))
+			}
+
+			if v, err := json.Marshal(trackingResult); err != nil {
+				return &result, fmt.Errorf("cannot convert tracking result to json, cause=%w", err)
+			} else {
+				result.Result = string(v)
+				return &result, nil
+			}
+		}
+	}
+}
+
 // 调用Go查询代理。
 func callCrawlerByGolang(crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode string, language _types.LangId, trackingNo, postcode, dest, date string) (*agentResult, error) {
 	carrierCode = strings.ToLower(carrierCode)
@@ -282,7 +623,7 @@ func callCrawlerByGolang(crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode stri
 
 	// 固定使用GET方式调用Go查询代理。
 	result := agentResult{StartTime: time.Now()}
-	if rsp, err := http.Get(url); err != nil {
+	if rsp, err := agentGet(url); err != nil {
 		// 查询代理不可用。
 		return &result, fmt.Errorf("cannot call crawler by golang {crawler-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}. cause=%w",
 			crawlerInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
@@ -336,7 +677,7 @@ func callCrawlerByPython(crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode stri
 	// 固定使用POST方式调用Python查询代理。
 	result := agentResult{StartTime: time.Now()}
 
-	if rsp, err := http.Post(url, "application/json", strings.NewReader(dataJson)); err != nil {
+	if rsp, err := agentPost(url, dataJson); err != nil {
 		// 查询代理不可用。
 		return &result, fmt.Errorf("cannot call crawler by python {crawler-name=%s, carrier-code=%s, language=%s, tracking-no=%s seq-no=%s}. cause=%w",
 			crawlerInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
@@ -347,14 +688,183 @@ func callCrawlerByPython(crawlerInfo *_db.CrawlerInfoPo, seqNo, carrierCode stri
 				crawlerInfo.Name, carrierCode, language.String(), trackingNo, seqNo, err)
 		} else {
 			result.EndTime = time.Now()
-			result.Result = strings.ReplaceAll(strings.ReplaceAll(buf.String(), "'", "\""), "None", "\"\"") // Python查询代理返回的json格式字符串不合规，需要兼容。
+			result.Result = pythonResultToJson(buf.String()) // Python查询代理返回的json格式字符串不合规，需要兼容。
 			return &result, nil
 		}
 	}
 }
 
+// 将Python查询代理返回的内容转换为合规的JSON。
+// 如果无法转换，那么返回原始内容，由调用方报告解析失败。
+func pythonResultToJson(s string) string {
+	if r, err := _utils.PyLiteralToJson(s); err != nil {
+		log.Printf("[WARN] Cannot convert python result to json: %v. cause=%s\n", _utils.AbbrText(s, 255), err)
+		return s
+	} else {
+		return r
+	}
+}
+
+// 结束已被放弃的查询对象领导的查询，并判断是否还有跟随者在等待结果。
+// 结束之后相同的查询不会再合并到此查询对象。仍在等待的跟随者被记录到查询对象中，查询代理返回时将结果复制给它们。
+// key 已被放弃的查询对象的键。
+// flightKey 查询对象所在的查询的键，可以为空。
+// 返回是否还有跟随者在等待结果。无法确定时返回true，仍然调用查询代理。
+func hasWaitingFollowers(key, flightKey string) bool {
+	if flightKey == "" {
+		return false
+	}
+
+	followers, err := _cache.LandFlight(agentCtx, flightKey, key)
+	if err != nil {
+		log.Printf("[WARN] Cannot land in-flight search(flight-key=%s). cause=%s\n", flightKey, err)
+		return true
+	}
+
+	waiting := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		if os, err := _cache.Get(agentCtx, follower, "status", "abandoned"); errors.Is(err, _cache.Nil) {
+			// 跟随者已经过期。
+			continue
+		} else if err == nil && _utils.AsString(os[1]) == "1" {
+			// 跟随者也已被放弃。
+			continue
+		}
+		waiting = append(waiting, follower)
+	}
+	if len(waiting) == 0 {
+		return false
+	}
+
+	if err := _cache.Update(agentCtx, key, map[string]interface{}{"followers": strings.Join(waiting, ",")}); err != nil {
+		log.Printf("[WARN] Cannot save followers of tracking-search(key=%s). cause=%s\n", key, err)
+	}
+	return true
+}
+
+// 将查询代理的结果写入缓存，并通知等待结果的查询接口服务。
+// 如果此查询对象领导了合并的查询，那么无论查询代理是否成功都结束合并的查询，并将结果复制给跟随者。
+// 缓存不可用时发生panic，查询对象稍后会被重新投递，重新处理时再结束合并的查询。
 func updateCache(key string, agentSrc _types.TrackingResultSrc, agentName, agentErr string, result *agentResult) {
-	if err := _cache.SetAndExpire(key, map[string]interface{}{"status": 1, "agentSrc": int(agentSrc), "agentName": agentName, "agentErr": agentErr, "agentStartTime": _utils.AsString(result.StartTime), "agentEndTime": _utils.AsString(result.EndTime), "agentResult": result.Result}, 10*time.Second); err != nil {
+	// 首先读取请求参数，写入结果之后查询接口服务可能立刻拉取并删除查询对象。
+	os, err := _cache.Get(agentCtx, key, "replyTo", "flight", "reqTime", "clientId", "carrierCode", "language", "trackingNo", "clientAddr", "followers")
+	if errors.Is(err, _cache.Nil) {
+		// 查询对象已经过期，不再写入缓存，否则会生成一个缺少请求参数的查询对象。
+		// 合并的查询紧接着查询对象创建，并且过期时间相同，所以也已经过期，之后相同的查询不会再等待它。
+		log.Printf("[WARN] Tracking-search(key=%s) expired before agent returned, result dropped\n", key)
+		observeAgentCall("", agentName, result)
+		resultsDropped.Inc()
+		return
+	} else if err != nil {
+		panic(fmt.Errorf("cannot get tracking-search(key=%s) from cache. cause=%w", key, err))
+	}
+
+	observeAgentCall(_utils.AsString(os[4]), agentName, result)
+
+	fields := map[string]interface{}{"status": 1, "agentSrc": int(agentSrc), "agentName": agentName, "agentErr": agentErr, "agentStartTime": _utils.AsString(result.StartTime), "agentEndTime": _utils.AsString(result.EndTime), "agentResult": result.Result}
+	if err := _cache.SetAndExpire(agentCtx, key, fields, time.Duration(atomic.LoadInt64(&resultExpiration))); err != nil {
 		panic(err)
 	}
+	notifyReply(key, _utils.AsString(os[0]))
+
+	// 查询代理返回了内容时才推送到完成队列。跟随者查询的是相同的运单，只复制结果，不再推送。
+	if result.Result != "" {
+		completion := map[string]string{"key": key, "status": "1", "agentSrc": strconv.Itoa(int(agentSrc)), "agentName": agentName, "agentErr": agentErr, "agentStartTime": _utils.AsString(result.StartTime), "agentEndTime": _utils.AsString(result.EndTime), "agentResult": result.Result}
+		for i, f := range []string{"reqTime", "clientId", "carrierCode", "language", "trackingNo", "clientAddr"} {
+			completion[f] = _utils.AsString(os[i+2])
+		}
+		publishCompletion(key, completion)
+	}
+
+	// 已被放弃的领导者在调用查询代理之前已经结束了查询，仍在等待的跟随者记录在查询对象中。
+	var followers []string
+	if v := _utils.AsString(os[8]); v != "" {
+		followers = strings.Split(v, ",")
+	}
+
+	// 如果此查询对象不是领导者，那么结束查询不会产生任何效果。
+	if flightKey := _utils.AsString(os[1]); flightKey != "" {
+		if landed, err := _cache.LandFlight(agentCtx, flightKey, key); err != nil {
+			log.Printf("[WARN] Cannot land in-flight search(flight-key=%s). cause=%s\n", flightKey, err)
+		} else {
+			followers = append(followers, landed...)
+		}
+	}
+
+	for _, follower := range followers {
+		// 跟随者可能已经过期，此时不再写入，否则会生成一个缺少请求参数的查询对象。
+		if ok, err := _cache.ReplaceAndExpire(agentCtx, follower, fields, time.Duration(atomic.LoadInt64(&resultExpiration))); err != nil {
+			log.Printf("[WARN] Cannot copy result to tracking-search(key=%s). cause=%s\n", follower, err)
+		} else if !ok {
+			log.Printf("[WARN] Tracking-search(key=%s) expired before agent returned, result dropped\n", follower)
+			resultsDropped.Inc()
+		} else if os, err := _cache.Get(agentCtx, follower, "replyTo"); err == nil {
+			notifyReply(follower, _utils.AsString(os[0]))
+		}
+	}
+}
+
+// 记录一次查询代理调用的返回码和延迟。
+// carrierCode 运输商编号，无法获取时为空。
+// agentName 查询代理的名字，没有匹配到查询代理时为空。
+// result 查询代理返回的结果。
+func observeAgentCall(carrierCode, agentName string, result *agentResult) {
+	code, outcome := agentOutcome(result.Result)
+	agentCalls.With(carrierCode, agentName, strconv.Itoa(int(code)), outcome).Inc()
+
+	if !result.StartTime.IsZero() && !result.EndTime.IsZero() {
+		agentCallSeconds.With(carrierCode, agentName).Observe(result.EndTime.Sub(result.StartTime).Seconds())
+	}
+}
+
+// 按照查询接口服务解析查询结果的方式获取返回码。
+// agentRspJson 查询代理返回的内容。
+// 返回查询代理的返回码和调用的结果，结果可以是`success`（包括单号未查询到）、`failure`或者`error`（没有返回内容）。
+func agentOutcome(agentRspJson string) (AgCode, string) {
+	agentRspJson = strings.TrimSpace(agentRspJson)
+	if agentRspJson == "" {
+		// 没有返回内容时查询接口服务认为查询代理超时，无论是否发生了错误。
+		return AcTimeout, "error"
+	}
+
+	code := AcParseFailed
+	trackingResult := TrackingResult{}
+	if err := json.Unmarshal([]byte(agentRspJson), &trackingResult); err == nil {
+		code = trackingResult.Code
+	} else {
+		crawlerRsp := ResponseWrapper{}
+		if err := json.Unmarshal([]byte(agentRspJson), &crawlerRsp); err == nil {
+			code = AgCode(_utils.AsInt(crawlerRsp.Code, int(AcParseFailed)))
+		}
+	}
+
+	if IsSuccess(code) {
+		return code, "success"
+	}
+	return code, "failure"
+}
+
+// 将查询对象推送到完成队列，由持久化协程保存到数据库。
+// 推送失败时查询结果不会被保存，之后的请求会再次调用查询代理，所以只记录日志。
+// key 查询对象在缓存中的键。
+// completion 查询对象的所有字段。
+func publishCompletion(key string, completion map[string]string) {
+	if value, err := json.Marshal(completion); err != nil {
+		log.Printf("[WARN] Cannot serialize completion of tracking-search(key=%s). cause=%s\n", key, err)
+	} else if _, err := _queue.Push(agentCtx, trackingCompletedTopic, string(value)); err != nil {
+		log.Printf("[WARN] Cannot publish completion of tracking-search(key=%s). cause=%s\n", key, err)
+	}
+}
+
+// 通知等待结果的查询接口服务。通知失败时查询接口服务会在超时之后读取结果，所以只记录日志。
+// key 查询对象在缓存中的键。
+// replyKey 接收完成通知的列表的键。
+func notifyReply(key, replyKey string) {
+	if replyKey == "" {
+		return
+	}
+
+	if err := _cache.PushAndExpire(agentCtx, replyKey, key, time.Duration(atomic.LoadInt64(&replyExpiration))); err != nil {
+		log.Printf("[WARN] Cannot notify completion of tracking-search(key=%s). cause=%s\n", key, err)
+	}
 }
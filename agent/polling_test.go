@@ -0,0 +1,206 @@
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	_cache "com.cne/ai-tracking-search/cache"
+	_queue "com.cne/ai-tracking-search/queue"
+)
+
+func initTestBackends(t *testing.T) context.Context {
+	if err := _cache.InitMemoryCache(); err != nil {
+		t.Fatal(err)
+	}
+	if err := _queue.InitMemoryQueue(); err != nil {
+		t.Fatal(err)
+	}
+
+	return context.Background()
+}
+
+// 按照查询接口服务的方式创建查询对象，并加入合并的查询。
+// 返回领导者的键，领导者是第一个加入的查询对象。
+func pushTestSearch(t *testing.T, ctx context.Context, key, flightKey string) string {
+	t.Helper()
+
+	fields := map[string]interface{}{"reqTime": "2021-10-27T08:30:00Z", "clientId": "c", "carrierCode": "C1", "language": "en", "trackingNo": "T1", "postcode": "", "dest": "", "date": "", "clientAddr": "", "replyTo": key + "$R", "flight": flightKey, "status": -1}
+	if err := _cache.SetAndExpire(ctx, key, fields, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	leader, err := _cache.JoinFlight(ctx, flightKey, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leader
+}
+
+func assertNotified(t *testing.T, ctx context.Context, key string) {
+	t.Helper()
+
+	if v, err := _cache.BPop(ctx, key+"$R", 10*time.Millisecond); err != nil || v != key {
+		t.Errorf("notification of %s = %q, %v", key, v, err)
+	}
+}
+
+func TestUpdateCacheLandsFlight(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	pushTestSearch(t, ctx, "S$1", "F$1")
+	pushTestSearch(t, ctx, "S$2", "F$1")
+
+	// 查询代理失败时也要结束合并的查询，并将失败复制给跟随者。
+	updateCache("S$1", 0, "crawler", "$调用爬虫失败$", &agentResult{})
+
+	for _, key := range []string{"S$1", "S$2"} {
+		if os, err := _cache.Get(ctx, key, "status", "agentErr"); err != nil || os[0] != "1" || os[1] != "$调用爬虫失败$" {
+			t.Errorf("result of %s = %v, %v", key, os, err)
+		}
+		assertNotified(t, ctx, key)
+	}
+
+	// 没有返回内容时不推送完成通知。
+	if n, _ := _queue.Length(ctx, trackingCompletedTopic); n != 0 {
+		t.Errorf("completions = %d, want 0", n)
+	}
+
+	// 之后相同的查询成为新的领导者。
+	if leader := pushTestSearch(t, ctx, "S$3", "F$1"); leader != "" {
+		t.Errorf("leader after landing = %q, want none", leader)
+	}
+}
+
+func TestUpdateCacheSkipsExpiredFollower(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	pushTestSearch(t, ctx, "S$1", "F$1")
+	pushTestSearch(t, ctx, "S$2", "F$1")
+	pushTestSearch(t, ctx, "S$3", "F$1")
+	_cache.Del(ctx, "S$2")
+
+	before := resultsDropped.Value()
+	updateCache("S$1", 0, "crawler", "", &agentResult{Result: `{"code":200}`})
+
+	// 已经过期的跟随者不会被重新创建，其它跟随者照常获得结果。
+	if _, err := _cache.Get(ctx, "S$2", "status"); !errors.Is(err, _cache.Nil) {
+		t.Errorf("expired follower recreated, err = %v", err)
+	}
+	if os, err := _cache.Get(ctx, "S$3", "status"); err != nil || os[0] != "1" {
+		t.Errorf("result of S$3 = %v, %v", os, err)
+	}
+	assertNotified(t, ctx, "S$3")
+	if got := resultsDropped.Value() - before; got != 1 {
+		t.Errorf("results dropped = %v, want 1", got)
+	}
+}
+
+func TestUpdateCachePublishesCompletion(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	pushTestSearch(t, ctx, "S$1", "F$1")
+	pushTestSearch(t, ctx, "S$2", "F$1")
+	updateCache("S$1", 0, "crawler", "", &agentResult{Result: `{"code":200}`})
+
+	// 只推送领导者的完成通知，包括请求参数。
+	m, err := _queue.BPop(ctx, 10*time.Millisecond, trackingCompletedTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"key":"S$1"`, `"carrierCode":"C1"`, `"trackingNo":"T1"`, `"agentResult":"{\"code\":200}"`} {
+		if !strings.Contains(m.Value, s) {
+			t.Errorf("completion %s does not contain %s", m.Value, s)
+		}
+	}
+	if n, _ := _queue.Length(ctx, trackingCompletedTopic); n != 1 {
+		t.Errorf("completions = %d, want 1", n)
+	}
+}
+
+func TestUpdateCacheExpiredSearch(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	before := resultsDropped.Value()
+	updateCache("S$missing", 0, "crawler", "", &agentResult{Result: `{"code":200}`})
+
+	// 不生成缺少请求参数的查询对象，也不推送完成通知。
+	if _, err := _cache.Get(ctx, "S$missing", "status", "agentResult"); !errors.Is(err, _cache.Nil) {
+		t.Errorf("Get of expired search error = %v, want Nil", err)
+	}
+	if n, _ := _queue.Length(ctx, trackingCompletedTopic); n != 0 {
+		t.Errorf("completions = %d, want 0", n)
+	}
+	if got := resultsDropped.Value() - before; got != 1 {
+		t.Errorf("dropped results = %v, want 1", got)
+	}
+}
+
+func TestPollOneSkipsAbandonedSearch(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	key := trackingSearchKeyPrefix + "$1"
+	pushTestSearch(t, ctx, key, "F$1")
+	_cache.Update(ctx, key, map[string]interface{}{"abandoned": 1})
+
+	// 没有跟随者时不再调用查询代理，并且结束查询，之后相同的查询不会等待它。
+	before := searchsAbandoned.Value()
+	if outcome := pollOne("Low", &_queue.Message{Value: key}); outcome != pollDone {
+		t.Errorf("pollOne = %v, want pollDone", outcome)
+	}
+	if got := searchsAbandoned.Value() - before; got != 1 {
+		t.Errorf("abandoned searchs = %v, want 1", got)
+	}
+	if leader := pushTestSearch(t, ctx, trackingSearchKeyPrefix+"$2", "F$1"); leader != "" {
+		t.Errorf("leader after skipping = %q, want none", leader)
+	}
+}
+
+func TestAbandonedSearchWithFollowers(t *testing.T) {
+	ctx := initTestBackends(t)
+
+	pushTestSearch(t, ctx, "S$1", "F$1")
+	pushTestSearch(t, ctx, "S$2", "F$1")
+	pushTestSearch(t, ctx, "S$3", "F$1")
+	_cache.Update(ctx, "S$1", map[string]interface{}{"abandoned": 1})
+	_cache.Update(ctx, "S$3", map[string]interface{}{"abandoned": 1})
+
+	// 仍有跟随者在等待时继续调用查询代理，已被放弃的跟随者不再等待。
+	if !hasWaitingFollowers("S$1", "F$1") {
+		t.Fatal("hasWaitingFollowers = false, want true")
+	}
+	if os, err := _cache.Get(ctx, "S$1", "followers"); err != nil || os[0] != "S$2" {
+		t.Errorf("followers = %v, %v, want S$2", os, err)
+	}
+
+	// 查询已经结束，相同的查询不再合并到已被放弃的查询对象。
+	if leader := pushTestSearch(t, ctx, "S$4", "F$1"); leader != "" {
+		t.Errorf("leader after landing = %q, want none", leader)
+	}
+
+	// 查询代理返回时将结果复制给仍在等待的跟随者。
+	updateCache("S$1", 0, "crawler", "", &agentResult{Result: `{"code":200}`})
+	if os, err := _cache.Get(ctx, "S$2", "status", "agentResult"); err != nil || os[0] != "1" || os[1] != `{"code":200}` {
+		t.Errorf("result of follower = %v, %v", os, err)
+	}
+	assertNotified(t, ctx, "S$2")
+	if os, _ := _cache.Get(ctx, "S$3", "status"); os[0] != "-1" {
+		t.Errorf("status of abandoned follower = %v, want -1", os[0])
+	}
+
+	// 跟随者都已被放弃或者过期时不再调用查询代理。
+	pushTestSearch(t, ctx, "S$5", "F$2")
+	pushTestSearch(t, ctx, "S$6", "F$2")
+	_cache.Update(ctx, "S$6", map[string]interface{}{"abandoned": 1})
+	_cache.JoinFlight(ctx, "F$2", "S$expired", time.Minute)
+	if hasWaitingFollowers("S$5", "F$2") {
+		t.Error("hasWaitingFollowers = true, want false")
+	}
+	if hasWaitingFollowers("S$7", "") {
+		t.Error("hasWaitingFollowers without flight = true, want false")
+	}
+}
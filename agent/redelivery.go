@@ -0,0 +1,97 @@
+// 该模块定义了确认和重新投递查询对象的方法。
+// 查询对象只有在结果写入缓存之后才被确认。工作协程崩溃时未确认的查询对象会在可见性超时之后被重新投递，
+// 多次投递仍然失败的查询对象被转移到死信队列。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_queue "com.cne/ai-tracking-search/queue"
+	_types "com.cne/ai-tracking-search/types"
+	_utils "com.cne/ai-tracking-search/utils"
+)
+
+// 表示处理查询对象的结果。
+type pollOutcome int
+
+const (
+	pollFailed   pollOutcome = iota // 处理失败，查询对象需要重新投递。
+	pollDone                        // 已经处理，查询对象需要确认。
+	pollRequeued                    // 因为达到限制，查询对象已经被放回队列。
+	pollDeferred                    // 查询对象被加入批量，由批量负责确认。
+)
+
+const (
+	minReclaimInterval time.Duration = 1 * time.Second // 检查超时查询对象的最小间隔。
+)
+
+var (
+	visibilityTimeout time.Duration // 已出队的查询对象超过此时间仍未确认，会被重新投递。
+	maxDeliveries     int           // 查询对象的最大投递次数。
+
+	queueRedelivered *_metrics.CounterVec // 每个队列中被重新投递的查询对象数。
+	queueDeadLetters *_metrics.CounterVec // 每个队列中被转移到死信队列的查询对象数。
+)
+
+func init() {
+	queueRedelivered = _metrics.NewCounterVec("tracking_queue_redelivered_total", "Number of tracking searches redelivered after a failure or visibility timeout.", "queue")
+	queueDeadLetters = _metrics.NewCounterVec("tracking_queue_dead_letter_total", "Number of tracking searches moved to the dead-letter queue.", "queue")
+}
+
+// 确认查询对象已经处理完毕。
+// 确认失败时查询对象会在可见性超时之后被重新投递，所以只记录日志。
+func ack(m *_queue.Message) {
+	if err := _queue.Ack(agentCtx, m); err != nil {
+		log.Printf("[WARN] Cannot ack tracking-search(key=%s). cause=%s\n", m.Value, err)
+	}
+}
+
+// 报告查询对象处理失败。
+// 如果投递次数没有超过上限，那么放回队列，否则转移到死信队列，并且将失败写入缓存，避免客户端一直等待到超时。
+func fail(m *_queue.Message, reason string) {
+	queueName := m.Topic[len(trackingQueueKey)+1:]
+
+	if dead, err := _queue.Fail(agentCtx, m, reason, maxDeliveries); err != nil {
+		log.Printf("[WARN] Cannot report failure of tracking-search(key=%s). cause=%s\n", m.Value, err)
+	} else if dead {
+		queueDeadLetters.With(queueName).Inc()
+		log.Printf("[ERROR] Tracking-search(key=%s) failed %d times and moved to dead-letter queue. cause=%s\n", m.Value, m.Deliveries+1, reason)
+
+		func() {
+			defer _utils.RecoverPanic()
+
+			updateCache(m.Value, _types.SrcUnknown, "", fmt.Sprintf("$多次处理失败(deliveries=%d)$", m.Deliveries+1), &agentResult{})
+		}()
+	} else {
+		queueRedelivered.With(queueName).Inc()
+	}
+}
+
+// 定期重新投递超过可见性超时仍未确认的查询对象。
+// topics 需要检查的主题。
+func reclaimForEver(topics []string) {
+	interval := visibilityTimeout / 4
+	if interval < minReclaimInterval {
+		interval = minReclaimInterval
+	}
+
+	for {
+		time.Sleep(interval)
+
+		for _, topic := range topics {
+			if requeued, dead, err := _queue.Reclaim(agentCtx, topic, visibilityTimeout, maxDeliveries); err != nil {
+				log.Printf("[WARN] Cannot reclaim tracking-searchs from %s. cause=%s\n", topic, err)
+			} else if requeued > 0 || dead > 0 {
+				queueName := topic[len(trackingQueueKey)+1:]
+				queueRedelivered.With(queueName).Add(float64(requeued))
+				queueDeadLetters.With(queueName).Add(float64(dead))
+				log.Printf("[WARN] Reclaimed tracking-searchs from %s, %d redelivered, %d moved to dead-letter queue\n", topic, requeued, dead)
+			}
+		}
+	}
+}
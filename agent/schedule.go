@@ -0,0 +1,222 @@
+// 该模块定义了在多个优先级队列之间调度的方法。
+// 调度使用加权轮询，并且等待时间过长的队列会被提升到最前，避免低优先级的查询对象在缓存过期前一直得不到处理。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_queue "com.cne/ai-tracking-search/queue"
+	_types "com.cne/ai-tracking-search/types"
+)
+
+const (
+	agingCheckInterval time.Duration = 1 * time.Second // 检查队列等待时间的间隔。
+)
+
+// 优先级队列的调度器。
+type scheduler struct {
+	lock sync.Mutex
+
+	weights map[_types.Priority]int // 每个优先级的权重。
+	current map[_types.Priority]int // 平滑加权轮询的当前权重。
+	total   int                     // 所有优先级的权重之和。
+
+	agingThreshold time.Duration                     // 等待时间超过此阈值的队列会被提升到最前。
+	oldestWait     map[_types.Priority]time.Duration // 每个优先级队列中最早的查询对象已经等待的时间。
+	lastCheckTime  time.Time                         // 最后一次检查队列等待时间的时间。
+	checking       bool                              // 是否正在检查队列等待时间。
+}
+
+var (
+	sched *scheduler // 全局调度器。
+
+	queueWaitSeconds   *_metrics.HistogramVec // 每个优先级的查询对象在队列中的等待时间。
+	queueOldestSeconds *_metrics.GaugeVec     // 每个优先级的队列中最早的查询对象已经等待的时间。
+	queueAgedPromotion *_metrics.CounterVec   // 每个优先级因为等待时间过长而被提升到原本排在它前面的队列之前的次数。
+	queueDepth         *_metrics.GaugeVec     // 每个队列的长度，包括完成队列和死信队列。
+)
+
+func init() {
+	queueWaitSeconds = _metrics.NewHistogramVec("tracking_queue_wait_seconds", "Seconds tracking searches waited in queue before being polled.", []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}, "priority")
+	queueOldestSeconds = _metrics.NewGaugeVec("tracking_queue_oldest_seconds", "Seconds the oldest tracking search in queue has waited.", "priority")
+	queueAgedPromotion = _metrics.NewCounterVec("tracking_queue_aged_promotion_total", "Number of times a queue was polled ahead of its normal turn because its oldest tracking search waited too long.", "priority")
+	queueDepth = _metrics.NewGaugeVec("tracking_queue_depth", "Number of messages in queue, including the completed and dead-letter queues.", "queue")
+}
+
+// 更新每个队列的长度，在输出指标之前调用。
+// 完成队列的长度是尚未保存到数据库的查询结果数。查询失败的队列保留之前的值。
+// ctx 上下文。
+func CollectQueueDepth(ctx context.Context) {
+	topics := make([]string, 0, len(allPriorities)+2)
+	for _, p := range allPriorities {
+		topics = append(topics, trackingQueueKey+"$"+p.String())
+	}
+	topics = append(topics, trackingCompletedTopic, trackingQueueKey+"$DeadLetter")
+
+	for _, topic := range topics {
+		if n, err := _queue.Length(ctx, topic); err != nil {
+			log.Printf("[WARN] Cannot get length of queue %s. cause=%s\n", topic, err)
+		} else {
+			queueDepth.With(topic[len(trackingQueueKey)+1:]).Set(float64(n))
+		}
+	}
+}
+
+func newScheduler(weights map[_types.Priority]int, agingThreshold time.Duration) *scheduler {
+	s := scheduler{
+		weights:        make(map[_types.Priority]int),
+		current:        make(map[_types.Priority]int),
+		agingThreshold: agingThreshold,
+		oldestWait:     make(map[_types.Priority]time.Duration),
+	}
+
+	for _, p := range allPriorities {
+		s.weights[p] = weights[p]
+		s.total += weights[p]
+	}
+
+	return &s
+}
+
+// 计算工作协程出队的主题顺序。
+// 顺序是：等待时间超过阈值的队列（等待越久越靠前）、加权轮询选中的优先级、工作协程所属的优先级，最后是其它优先级（从高到低）。
+// 所有队列都有查询对象时，各个优先级的出队比例等于权重的比例；加权轮询选中的队列为空时，优先处理工作协程所属的优先级。
+// priority 工作协程所属的优先级。
+func (s *scheduler) topics(priority _types.Priority) []string {
+	s.refreshOldestWait()
+
+	// 不考虑等待时间时的顺序。
+	normal := make([]_types.Priority, 0, len(allPriorities)+2)
+	if p, ok := s.pick(); ok {
+		normal = append(normal, p)
+	}
+	normal = dedupPriorities(append(append(normal, priority), allPriorities...))
+
+	aged := s.agedPriorities()
+	order := dedupPriorities(append(aged, normal...))
+
+	// 只有被提升到原本排在它前面的队列之前时，才算作一次提升。
+	for _, p := range aged {
+		if indexOfPriority(order, p) < indexOfPriority(normal, p) {
+			queueAgedPromotion.With(p.String()).Inc()
+		}
+	}
+
+	topics := make([]string, 0, len(order))
+	for _, p := range order {
+		topics = append(topics, trackingQueueKey+"$"+p.String())
+	}
+
+	return topics
+}
+
+// 去掉重复的优先级，保留第一次出现的位置。
+func dedupPriorities(priorities []_types.Priority) []_types.Priority {
+	result := make([]_types.Priority, 0, len(priorities))
+	added := make(map[_types.Priority]bool, len(priorities))
+	for _, p := range priorities {
+		if !added[p] {
+			added[p] = true
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+func indexOfPriority(priorities []_types.Priority, p _types.Priority) int {
+	for i, v := range priorities {
+		if v == p {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// 使用平滑加权轮询选择一个优先级。
+// 如果所有优先级的权重都是0，那么返回false。
+func (s *scheduler) pick() (_types.Priority, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.total <= 0 {
+		return -1, false
+	}
+
+	best := _types.Priority(-1)
+	for _, p := range allPriorities {
+		s.current[p] += s.weights[p]
+		if best == -1 || s.current[p] > s.current[best] {
+			best = p
+		}
+	}
+	s.current[best] -= s.total
+
+	return best, true
+}
+
+// 返回等待时间超过阈值的优先级，等待越久越靠前。
+func (s *scheduler) agedPriorities() []_types.Priority {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.agingThreshold <= 0 {
+		return nil
+	}
+
+	result := make([]_types.Priority, 0)
+	for _, p := range allPriorities {
+		if s.oldestWait[p] >= s.agingThreshold {
+			result = append(result, p)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return s.oldestWait[result[i]] > s.oldestWait[result[j]] })
+
+	return result
+}
+
+// 刷新每个优先级队列中最早的查询对象已经等待的时间。
+// 最多每隔`agingCheckInterval`刷新一次，并且同时只有一个工作协程执行刷新。
+func (s *scheduler) refreshOldestWait() {
+	s.lock.Lock()
+	if s.checking || time.Since(s.lastCheckTime) < agingCheckInterval {
+		s.lock.Unlock()
+		return
+	}
+	s.checking = true
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.checking = false
+		s.lastCheckTime = time.Now()
+		s.lock.Unlock()
+	}()
+
+	now := time.Now()
+	for _, p := range allPriorities {
+		wait := time.Duration(0)
+		if m, err := _queue.Peek(agentCtx, trackingQueueKey+"$"+p.String()); err != nil {
+			if !errors.Is(err, _queue.Nil) {
+				log.Printf("[WARN] Cannot peek queue of priority %s. cause=%s\n", p.String(), err)
+			}
+		} else if !m.Time.IsZero() {
+			wait = now.Sub(m.Time)
+		}
+
+		queueOldestSeconds.With(p.String()).Set(wait.Seconds())
+
+		s.lock.Lock()
+		s.oldestWait[p] = wait
+		s.lock.Unlock()
+	}
+}
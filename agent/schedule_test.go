@@ -0,0 +1,115 @@
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	_types "com.cne/ai-tracking-search/types"
+)
+
+// 创建一个不会检查队列等待时间的调度器，等待时间由测试指定。
+func newTestScheduler(weights map[_types.Priority]int, wait map[_types.Priority]time.Duration) *scheduler {
+	s := newScheduler(weights, 30*time.Second)
+	s.lastCheckTime = time.Now().Add(time.Hour)
+	for p, w := range wait {
+		s.oldestWait[p] = w
+	}
+
+	return s
+}
+
+func promotions(p _types.Priority) float64 {
+	return queueAgedPromotion.With(p.String()).Value()
+}
+
+func TestSchedulerTopicsOrder(t *testing.T) {
+	weights := map[_types.Priority]int{_types.PriorityHighest: 6, _types.PriorityHigh: 3, _types.PriorityLow: 1}
+	wantRatio := map[string]float64{trackingQueueKey + "$Highest": 0.6, trackingQueueKey + "$High": 0.3, trackingQueueKey + "$Low": 0.1}
+
+	// 所有队列都有查询对象时，工作协程从第一个主题出队。无论工作协程属于哪个优先级，出队比例都等于权重的比例。
+	for _, priority := range allPriorities {
+		s := newTestScheduler(weights, nil)
+
+		const n = 1000
+		counts := make(map[string]int)
+		for i := 0; i < n; i++ {
+			topics := s.topics(priority)
+			if len(topics) != 3 {
+				t.Fatalf("topics = %v, want 3 topics", topics)
+			}
+			// 加权轮询选中的队列为空时，接下来是工作协程所属的优先级。
+			if topics[0] != trackingQueueKey+"$"+priority.String() && topics[1] != trackingQueueKey+"$"+priority.String() {
+				t.Fatalf("topics = %v, want %s in the first two", topics, priority.String())
+			}
+			counts[topics[0]]++
+		}
+
+		for topic, ratio := range wantRatio {
+			if got := float64(counts[topic]) / n; got < ratio-0.01 || got > ratio+0.01 {
+				t.Errorf("worker %s: dequeue ratio of %s = %v, want %v", priority.String(), topic, got, ratio)
+			}
+		}
+	}
+
+	// 所有优先级的权重都是0时，工作协程所属的优先级在最前。
+	s := newTestScheduler(map[_types.Priority]int{}, nil)
+	if topics := s.topics(_types.PriorityLow); topics[0] != trackingQueueKey+"$Low" {
+		t.Errorf("topics[0] = %s, want Low", topics[0])
+	}
+}
+
+func TestSchedulerAgedPromotion(t *testing.T) {
+	weights := map[_types.Priority]int{_types.PriorityHighest: 1, _types.PriorityHigh: 0, _types.PriorityLow: 0}
+
+	// 低优先级等待过久，被提升到最前。
+	s := newTestScheduler(weights, map[_types.Priority]time.Duration{_types.PriorityLow: time.Minute})
+	before := promotions(_types.PriorityLow)
+	topics := s.topics(_types.PriorityHighest)
+	want := []string{trackingQueueKey + "$Low", trackingQueueKey + "$Highest", trackingQueueKey + "$High"}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %v, want %v", topics, want)
+	}
+	if got := promotions(_types.PriorityLow) - before; got != 1 {
+		t.Errorf("promotions of Low = %v, want 1", got)
+	}
+
+	// 加权轮询本来就选中的队列等待过久时，不算作提升。
+	s = newTestScheduler(map[_types.Priority]int{_types.PriorityLow: 1}, map[_types.Priority]time.Duration{_types.PriorityLow: time.Minute})
+	before = promotions(_types.PriorityLow)
+	for i := 0; i < 5; i++ {
+		if topics := s.topics(_types.PriorityHighest); topics[0] != trackingQueueKey+"$Low" {
+			t.Errorf("topics[0] = %s, want Low", topics[0])
+		}
+	}
+	if got := promotions(_types.PriorityLow) - before; got != 0 {
+		t.Errorf("promotions of Low = %v, want 0", got)
+	}
+
+	// 多个队列等待过久时，等待越久越靠前；已经在最前的队列不算作提升。
+	s = newTestScheduler(weights, map[_types.Priority]time.Duration{_types.PriorityHighest: time.Minute, _types.PriorityHigh: 2 * time.Minute})
+	beforeHighest, beforeHigh := promotions(_types.PriorityHighest), promotions(_types.PriorityHigh)
+	topics = s.topics(_types.PriorityHighest)
+	want = []string{trackingQueueKey + "$High", trackingQueueKey + "$Highest", trackingQueueKey + "$Low"}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %v, want %v", topics, want)
+	}
+	if got := promotions(_types.PriorityHigh) - beforeHigh; got != 1 {
+		t.Errorf("promotions of High = %v, want 1", got)
+	}
+	if got := promotions(_types.PriorityHighest) - beforeHighest; got != 0 {
+		t.Errorf("promotions of Highest = %v, want 0", got)
+	}
+
+	// 等待时间没有超过阈值时不提升。
+	s = newTestScheduler(weights, map[_types.Priority]time.Duration{_types.PriorityLow: time.Second})
+	before = promotions(_types.PriorityLow)
+	if topics := s.topics(_types.PriorityHighest); topics[0] != trackingQueueKey+"$Highest" {
+		t.Errorf("topics[0] = %s, want Highest", topics[0])
+	}
+	if got := promotions(_types.PriorityLow) - before; got != 0 {
+		t.Errorf("promotions of Low = %v, want 0", got)
+	}
+}
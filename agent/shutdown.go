@@ -0,0 +1,98 @@
+// 该模块定义了查询代理工作进程的退出流程。
+// 退出时首先停止出队，立刻调用正在收集的批量，然后等待正在处理的查询对象处理完毕。
+// 超过截止时间仍未处理完毕时中止正在进行的查询代理调用，等待工作协程结束之后，才将仍未处理完毕的查询对象放回队列，由其它查询代理工作进程处理。
+// @Author: agent
+// @Created: 2026-10-18
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	_queue "com.cne/ai-tracking-search/queue"
+)
+
+const (
+	pushBackTimeout time.Duration = 5 * time.Second // 将未处理完毕的查询对象放回队列的超时时间。
+)
+
+var (
+	pollCtx     context.Context    // 工作协程出队时使用的上下文，开始退出时被取消。
+	stopPolling context.CancelFunc // 取消 pollCtx 的方法。
+	workCtx     context.Context    // 调用查询代理时使用的上下文，超过退出的截止时间时被取消。
+	abortWork   context.CancelFunc // 取消 workCtx 的方法。
+
+	workers  sync.WaitGroup   // 所有的工作协程。
+	flushes  sync.WaitGroup   // 所有尚未调用完毕的批量。
+	inflight *_queue.Inflight // 已出队但是尚未处理完毕的查询对象。
+
+	errWorkAborted = errors.New("agent call aborted at shutdown")
+)
+
+func init() {
+	pollCtx, stopPolling = context.WithCancel(context.Background())
+	workCtx, abortWork = context.WithCancel(context.Background())
+	inflight = _queue.NewInflight()
+}
+
+// 如果退出时中止了查询代理的调用，那么发生panic，查询对象不写入结果也不确认，由 Shutdown 放回队列。
+// err 调用查询代理返回的错误。
+func checkAborted(err error) {
+	if err != nil && workCtx.Err() != nil {
+		panic(errWorkAborted)
+	}
+}
+
+// 停止轮询，等待正在处理的查询对象处理完毕。
+// ctx 上下文，决定了等待的截止时间。
+// 超过截止时间时中止正在进行的查询代理调用，等待工作协程和批量结束，之后仍未处理完毕的查询对象被放回队列，不增加投递次数。
+// 工作协程结束之前不放回队列，否则其它进程会重复调用查询代理。
+func Shutdown(ctx context.Context) error {
+	stopPolling()
+	flushAllBatches()
+
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		if inflight.Wait(ctx) {
+			return nil
+		}
+	case <-ctx.Done():
+	}
+
+	abortWork()
+
+	// 中止之后调用很快返回，最多再等待 pushBackTimeout。
+	exited := make(chan struct{})
+	go func() {
+		<-stopped
+		flushes.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(pushBackTimeout):
+		log.Printf("[WARN] Workers are still running %s after agent calls were aborted\n", pushBackTimeout)
+	}
+
+	pushBackCtx, cancel := context.WithTimeout(context.Background(), pushBackTimeout)
+	defer cancel()
+
+	n, err := inflight.NackAll(pushBackCtx)
+	log.Printf("[WARN] Pushed back %d tracking-searchs still being processed at shutdown\n", n)
+	if err != nil {
+		return fmt.Errorf("cannot push back tracking-searchs: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+// 该模块定义了查询接口服务和查询代理工作进程共用的启动流程。
+// @Author: agent
+// @Created: 2026-10-18
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	_cache "com.cne/ai-tracking-search/cache"
+	_config "com.cne/ai-tracking-search/config"
+	_db "com.cne/ai-tracking-search/db"
+	_limiter "com.cne/ai-tracking-search/limiter"
+	_queue "com.cne/ai-tracking-search/queue"
+	_redisclient "com.cne/ai-tracking-search/redisclient"
+	_utils "com.cne/ai-tracking-search/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultDebug bool = false // 表示默认是否开启Debug模式。
+)
+
+const (
+	MigrateUp     string = "up"      // 执行所有未执行的迁移脚本。
+	MigrateStatus string = "status"  // 显示迁移脚本的执行状态。
+	MigrateDryRun string = "dry-run" // 只显示将要执行的SQL语句。
+)
+
+// 表示一个可以独立运行的应用程序。
+type App struct {
+	Name    string // 应用程序名。
+	Version string // 应用程序版本。
+
+	// 是否在同一个进程中运行查询接口服务和查询代理工作进程。只有这样的应用程序才能使用内存后端。
+	Standalone bool
+
+	// 初始化应用程序自身的资源，此时公共资源（数据库、缓存和队列）已经初始化。
+	Init func(configuration *_config.Configuration) error
+
+	// 开始服务，此方法在独立的协程中执行。
+	Serve func(configuration *_config.Configuration) error
+
+	// 停止服务，此方法在收到退出信号后执行。应当停止接收新的工作，等待正在进行的工作完成，并且在上下文的截止时间之前返回。
+	Shutdown func(ctx context.Context) error
+
+	// 使重新加载的配置生效，此方法在收到`SIGHUP`后执行。配置中只有可以在运行时修改的配置项发生了变化。
+	Reload func(configuration *_config.Configuration) error
+
+	// Reload 使之生效的顶层配置项，比如`Search`。其它顶层配置项中可以在运行时修改的配置项发生变化时被忽略。`ShutdownTimeout`由应用程序自身使之生效。
+	ReloadSections []string
+
+	configFile        string // 命令行参数指定的配置文件。
+	defaultConfigFile string // 默认的配置文件。
+}
+
+var (
+	flagVersion bool // 是否显示版权信息
+	flagHelp    bool // 是否显示帮助信息
+	flagVerify  bool // 是否只检查配置文件
+	flagDebug   bool // 是否显示调试信息
+
+	flagMigrate string // 迁移数据库结构的命令，空字符串表示正常运行。
+)
+
+// 运行应用程序。
+// 解析命令行参数，加载配置，初始化公共资源和应用程序自身的资源，然后开始服务，直到收到退出信号。
+func (a *App) Run() {
+	log.SetFlags(log.Ldate | log.Ltime | log.Llongfile)
+	log.SetOutput(&_utils.RollingFileLoggerWriter{Pattern: "log/" + a.Name + "-$date.log"})
+
+	flag.BoolVar(&flagVersion, "version", false, "Shows version message")
+	flag.BoolVar(&flagHelp, "h", false, "Shows this help message")
+	flag.BoolVar(&flagVerify, "verify", false, "Verify configuration and quit")
+	flag.BoolVar(&flagDebug, "debug", DefaultDebug, "Show debugging information")
+	flag.StringVar(&flagMigrate, "migrate", "", "Migrate database schema and quit: up, status or dry-run")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -version\n", a.Name)
+		fmt.Fprintf(os.Stderr, "Usage: %s -h\n", a.Name)
+		fmt.Fprintf(os.Stderr, "Usage: %s -verify\n", a.Name)
+		fmt.Fprintf(os.Stderr, "Usage: %s -migrate up|status|dry-run [CONFIG_FILE]\n", a.Name)
+		fmt.Fprintf(os.Stderr, "Usage: %s [-debug] [CONFIG_FILE]\n", a.Name)
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flagVersion {
+		fmt.Printf("%s version %s\n", a.Name, a.Version)
+		return
+	}
+
+	if flagHelp {
+		flag.Usage()
+		return
+	}
+
+	switch flagMigrate {
+	case "", MigrateUp, MigrateStatus, MigrateDryRun:
+	default:
+		fmt.Fprintf(os.Stderr, "Illegal migrate command: %s\n", flagMigrate)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !flagDebug {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	if flagDebug {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// 加载配置。
+	a.configFile, a.defaultConfigFile = strings.TrimSpace(flag.Arg(0)), "./"+a.Name+".json"
+	configuration, err := _config.Load(a.configFile, a.defaultConfigFile)
+	if err != nil {
+		if flagVerify {
+			fmt.Fprintf(os.Stderr, "Cannot load configuration: %v\n", err)
+			os.Exit(1)
+		}
+		panic(fmt.Errorf("cannot load configuration: %w", err))
+	}
+
+	if flagVerify {
+		if doc, err := configuration.Dump(); err != nil {
+			panic(err)
+		} else {
+			fmt.Printf("%s\n", doc)
+		}
+		return
+	}
+
+	if flagMigrate != "" {
+		if err := migrate(configuration, flagMigrate); err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 输出pid文件。
+	pidFilename := a.Name + "-pid"
+	if runtime.GOOS != "windows" {
+		pidFilename = "/var/run/" + pidFilename
+	}
+	if pidFile, err := os.Create(pidFilename); err == nil {
+		pidFile.WriteString(fmt.Sprintf("%v", os.Getpid()))
+		pidFile.Close()
+
+		defer os.Remove(pidFilename)
+	}
+
+	// 初始化数据库。
+	if err := _db.InitDB(&configuration.DB); err != nil {
+		panic(err)
+	}
+
+	// 拒绝在过期的数据库结构上运行。
+	if err := _db.CheckSchema(context.Background()); err != nil {
+		panic(err)
+	}
+
+	// 初始化缓存、队列和限制。
+	if err := a.initBackend(configuration); err != nil {
+		panic(err)
+	}
+
+	// 初始化应用程序自身的资源。
+	if a.Init != nil {
+		if err := a.Init(configuration); err != nil {
+			panic(err)
+		}
+	}
+
+	// 开始服务。
+	if err := a.serveForEver(configuration); err != nil {
+		panic(err)
+	}
+}
+
+// 迁移数据库结构。
+// configuration 配置。
+// command 迁移命令。
+func migrate(configuration *_config.Configuration, command string) error {
+	if err := _db.InitDB(&configuration.DB); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case MigrateStatus:
+		status, err := _db.QueryMigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			if s.Applied {
+				fmt.Printf("%-40s applied at %s\n", s, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%-40s pending\n", s)
+			}
+		}
+		return nil
+	case MigrateDryRun:
+		return _db.Migrate(ctx, true, os.Stdout)
+	default:
+		return _db.Migrate(ctx, false, os.Stdout)
+	}
+}
+
+func (a *App) initBackend(configuration *_config.Configuration) error {
+	if configuration.Backend == _config.BackendMemory {
+		// 内存后端只在当前进程中有效，查询接口服务和查询代理工作进程必须在同一个进程中。
+		if !a.Standalone {
+			return fmt.Errorf("backend %s is only available in standalone mode", configuration.Backend)
+		}
+
+		if err := _cache.InitMemoryCache(); err != nil {
+			return err
+		}
+		if err := _queue.InitMemoryQueue(); err != nil {
+			return err
+		}
+		return _limiter.InitMemoryLimiter()
+	}
+
+	// 初始化共享的Redis客户端。每个阻塞出队的工作协程和持久化协程会占用一个连接。
+	if err := _redisclient.Init(&configuration.Redis, configuration.Worker.Concurrency.Total()+configuration.Worker.Persisters); err != nil {
+		return err
+	}
+
+	// 初始化Redis缓存。
+	if err := _cache.InitRedisCache(); err != nil {
+		return err
+	}
+
+	// 初始化Redis队列。
+	if err := _queue.InitRedisQueue(); err != nil {
+		return err
+	}
+
+	// 初始化Redis限制。
+	return _limiter.InitRedisLimiter()
+}
+
+func (a *App) serveForEver(configuration *_config.Configuration) error {
+	errChannel := make(chan error, 1)
+	go func() {
+		errChannel <- a.Serve(configuration)
+	}()
+
+	// 启动守护routine。
+	sigChannel := make(chan os.Signal, 256)
+	signal.Notify(sigChannel, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case err := <-errChannel:
+			return err
+		case sig := <-sigChannel:
+			fmt.Fprintf(os.Stderr, "Received sig: %#v\n", sig)
+			switch sig {
+			case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
+				return a.shutdown(configuration, sigChannel)
+			case syscall.SIGHUP:
+				configuration = a.reload(configuration)
+			}
+		}
+	}
+}
+
+// 重新加载配置文件和环境变量，使可以在运行时修改的配置项生效。
+// 其它配置项发生变化时只输出警告，需要重启才能生效。重新加载失败时继续使用当前配置。
+// current 当前生效的配置。
+// 返回之后生效的配置。
+func (a *App) reload(current *_config.Configuration) *_config.Configuration {
+	next, err := _config.Load(a.configFile, a.defaultConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot reload configuration: %v\n", err)
+		return current
+	}
+
+	merged, reloaded, restart := _config.Merge(current, next, append([]string{"ShutdownTimeout"}, a.ReloadSections...))
+	for _, path := range restart {
+		fmt.Fprintf(os.Stderr, "Configuration %s changed, requires restart\n", path)
+	}
+	if len(reloaded) == 0 {
+		fmt.Fprintf(os.Stderr, "No reloadable configuration changed\n")
+		return current
+	}
+
+	if a.Reload != nil {
+		if err := a.Reload(merged); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot reload configuration: %v\n", err)
+			return current
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Configuration reloaded: %s\n", strings.Join(reloaded, ", "))
+	return merged
+}
+
+// 有序地停止服务。
+// 在配置的退出超时之内等待应用程序停止服务，期间再次收到退出信号时立刻退出。
+func (a *App) shutdown(configuration *_config.Configuration, sigChannel chan os.Signal) error {
+	if a.Shutdown == nil {
+		return nil
+	}
+
+	timeout := time.Duration(configuration.ShutdownTimeout) * time.Second
+	fmt.Fprintf(os.Stderr, "Shutting down in %s ...\n", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errChannel := make(chan error, 1)
+	go func() {
+		errChannel <- a.Shutdown(ctx)
+	}()
+
+	for {
+		select {
+		case err := <-errChannel:
+			if err == nil {
+				fmt.Fprintf(os.Stderr, "Shutdown completed\n")
+			}
+			return err
+		case sig := <-sigChannel:
+			switch sig {
+			case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
+				return fmt.Errorf("shutdown interrupted by sig: %#v", sig)
+			}
+		}
+	}
+}
@@ -1,153 +1,142 @@
 // 该模块实现了缓存。
+// 缓存的后端可以是Redis或者进程内的内存，由配置选择。
+// 所有的方法都接受上下文，上下文被取消或者超时后，正在进行的操作会尽快返回上下文的错误。
 // @Author: Haart
 // @Created: 2021-10-27
 package cache
 
 import (
 	"context"
-	"fmt"
-	"strconv"
+	"errors"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
+// 表示缓存的后端。
+// 缓存的内容是散列或者列表，每个键有独立的过期时间。读取的值总是字符串，和Redis的行为一致。
+type Store interface {
+	SetAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) error
+	ReplaceAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) (bool, error)
+	Update(ctx context.Context, key string, fields map[string]interface{}) error
+	Get(ctx context.Context, key string, fields ...string) ([]interface{}, error)
+	Del(ctx context.Context, key string) (int64, error)
+	Take(ctx context.Context, key string, fields ...string) ([]interface{}, error)
+	GetAndExpire(ctx context.Context, key string, expiration time.Duration, fields ...string) ([]interface{}, error)
+	PushAndExpire(ctx context.Context, key string, value string, expiration time.Duration) error
+	BPop(ctx context.Context, key string, timeout time.Duration) (string, error)
+	JoinFlight(ctx context.Context, flightKey string, member string, expiration time.Duration) (string, error)
+	LandFlight(ctx context.Context, flightKey string, leader string) ([]string, error)
+}
+
 var (
-	redisHost     string
-	redisPort     int
-	redisPassword string
-	redisDB       int
-	redisClient   *redis.Client
-	redisCtx      context.Context
-)
+	// 缓存内容不存在。
+	Nil = errors.New("cache: nil")
 
-func init() {
-	redisCtx = context.Background()
-}
+	store Store // 当前使用的缓存后端。
+)
 
-// 初始化Redis队列配置。
-// host Redis主机。
-// port Redis端口号。
-// password Redis口令。
-// db Redis队列使用的数据库。
-func InitRedisCache(host string, port int, password string, db int) error {
-	redisHost = host
-	redisPort = port
-	redisPassword = password
-	redisDB = db
-
-	client := redis.NewClient(&redis.Options{
-		Addr:     redisHost + ":" + strconv.Itoa(redisPort),
-		Password: redisPassword,
-		DB:       redisDB,
-	})
-	if client == nil {
-		return fmt.Errorf("cannot create redis client")
-	}
-	if _, err := client.Ping(redisCtx).Result(); err != nil {
-		return err
-	} else {
-		redisClient = client
-		return nil
-	}
+// 使用指定的缓存后端。
+// s 缓存后端。
+func Use(s Store) {
+	store = s
 }
 
-// Deprecated 此方法会清除之前设置的过期时间。
-// func Set(key string, fields map[string]interface{}) error {
-// 	_, err := redisClient.HMSet(key, fields).Result()
-// 	return err
-// }
-
 // 保存指定的值到缓存，并设置过期时间。
+// ctx 上下文。
 // key 缓存的键。
 // fields 缓存的内容。
 // expiration 缓存过期的时间。
-func SetAndExpire(key string, fields map[string]interface{}, expiration time.Duration) error {
-	p := redisClient.TxPipeline()
-
-	p.HMSet(redisCtx, key, fields)
-	p.Expire(redisCtx, key, expiration)
-
-	if _, err := p.Exec(redisCtx); err != nil {
-		return err
-	} else {
-		return nil
-	}
+func SetAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) error {
+	return store.SetAndExpire(ctx, key, fields, expiration)
 }
 
-func Update(key string, fields map[string]interface{}) error {
-	p := redisClient.Pipeline()
-
-	for hk, hv := range fields {
-		p.HSet(redisCtx, key, hk, hv)
-	}
+// 如果缓存存在，那么保存指定的值到缓存，并设置过期时间。
+// 缓存已经过期时不做任何事，不会生成只包含这些值的新缓存。
+// ctx 上下文。
+// key 缓存的键。
+// fields 缓存的内容。
+// expiration 缓存过期的时间。
+// 返回缓存是否存在。
+func ReplaceAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) (bool, error) {
+	return store.ReplaceAndExpire(ctx, key, fields, expiration)
+}
 
-	if _, err := p.Exec(redisCtx); err != nil {
-		return err
-	} else {
-		return nil
-	}
+// 更新缓存内容，不改变过期时间。
+// ctx 上下文。
+// key 缓存的键。
+// fields 需要更新的内容。
+func Update(ctx context.Context, key string, fields map[string]interface{}) error {
+	return store.Update(ctx, key, fields)
 }
 
 // 获取缓存内容。
+// ctx 上下文。
 // key 缓存的键。
 // fields 缓存内容的名字。
-// 返回被缓存的内容。
-func Get(key string, fields ...string) ([]interface{}, error) {
-	if r, err := redisClient.HMGet(redisCtx, key, fields...).Result(); err != nil {
-		return nil, err
-	} else {
-		allNil := true
-		for _, o := range r {
-			if o != nil {
-				allNil = false
-			}
-		}
-		if allNil {
-			return nil, redis.Nil
-		} else {
-			return r, nil
-		}
-	}
+// 返回被缓存的内容。如果所有内容都不存在，那么返回`Nil`。
+func Get(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return store.Get(ctx, key, fields...)
 }
 
 // 删除缓存。
+// ctx 上下文。
 // key 缓存的键。
-func Del(key string) (int64, error) {
-	return redisClient.Del(redisCtx, key).Result()
+func Del(ctx context.Context, key string) (int64, error) {
+	return store.Del(ctx, key)
 }
 
 // 获取并删除缓存内容。
+// ctx 上下文。
 // key 缓存的键。
 // fields 缓存内容的名字。
 // 返回被缓存的内容。
-func Take(key string, fields ...string) ([]interface{}, error) {
-	p := redisClient.Pipeline()
-
-	p.HMGet(redisCtx, key, fields...)
-	p.Del(redisCtx, key)
-
-	if cc, err := p.Exec(redisCtx); err != nil {
-		return nil, err
-	} else {
-		return cc[0].(*redis.SliceCmd).Result()
-	}
+func Take(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return store.Take(ctx, key, fields...)
 }
 
 // 获取缓存内容并延长过期时间。
+// ctx 上下文。
 // key 缓存的键。
 // expiration 缓存延长的过期时间。
 // fields 缓存内容的名字。
 // 返回被缓存的内容。
-func GetAndExpire(key string, expiration time.Duration, fields ...string) ([]interface{}, error) {
-	p := redisClient.Pipeline()
+func GetAndExpire(ctx context.Context, key string, expiration time.Duration, fields ...string) ([]interface{}, error) {
+	return store.GetAndExpire(ctx, key, expiration, fields...)
+}
+
+// 将值追加到列表的末尾，并设置列表的过期时间。
+// ctx 上下文。
+// key 列表的键。
+// value 待追加的值。
+// expiration 列表过期的时间。
+func PushAndExpire(ctx context.Context, key string, value string, expiration time.Duration) error {
+	return store.PushAndExpire(ctx, key, value, expiration)
+}
 
-	p.HMGet(redisCtx, key, fields...).Result()
-	p.Expire(redisCtx, key, expiration)
+// 从列表的头部阻塞地取出一个值。
+// ctx 上下文。
+// key 列表的键。
+// timeout 阻塞的超时时间，可以不足1秒，不会超过此时间返回。
+// 返回取出的值。如果超时则返回`Nil`，如果上下文被取消则返回上下文的错误。
+func BPop(ctx context.Context, key string, timeout time.Duration) (string, error) {
+	return store.BPop(ctx, key, timeout)
+}
+
+// 加入正在进行的查询。
+// 如果没有正在进行的查询，那么成员成为领导者；否则成员成为跟随者，等待领导者完成时获取相同的结果。
+// ctx 上下文。
+// flightKey 查询的键。
+// member 成员的键。
+// expiration 查询的过期时间，领导者没有完成时查询自动结束。
+// 返回领导者的键。如果成员成为领导者，那么返回空字符串。
+func JoinFlight(ctx context.Context, flightKey string, member string, expiration time.Duration) (string, error) {
+	return store.JoinFlight(ctx, flightKey, member, expiration)
+}
 
-	if cc, err := p.Exec(redisCtx); err != nil {
-		return nil, err
-	} else {
-		return cc[0].(*redis.SliceCmd).Result()
-	}
+// 结束正在进行的查询。
+// ctx 上下文。
+// flightKey 查询的键。
+// leader 领导者的键。如果领导者已经不是此查询的领导者（比如查询已经过期），那么不做任何事。
+// 返回所有的跟随者的键。
+func LandFlight(ctx context.Context, flightKey string, leader string) ([]string, error) {
+	return store.LandFlight(ctx, flightKey, leader)
 }
@@ -0,0 +1,324 @@
+// 该模块实现了进程内的内存缓存后端。
+// 内存缓存只在当前进程中有效，适用于在同一个进程中运行查询接口服务和查询代理工作进程的开发和集成测试环境。
+// @Author: agent
+// @Created: 2026-10-18
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	memoryPurgeInterval time.Duration = 10 * time.Second // 清理过期缓存的间隔。
+)
+
+type memoryEntry struct {
+	fields   map[string]string
+	expireAt time.Time // 过期时间，零值表示永不过期。
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+type memoryList struct {
+	values   []string
+	expireAt time.Time
+}
+
+type memoryFlight struct {
+	leader    string
+	followers []string
+	expireAt  time.Time
+}
+
+type memoryStore struct {
+	lock    sync.Mutex
+	entries map[string]*memoryEntry
+	lists   map[string]*memoryList
+	flights map[string]*memoryFlight
+	notify  chan struct{} // 有值追加到列表时关闭并替换，用于唤醒所有阻塞取值的协程。
+}
+
+// 初始化内存缓存，并作为当前的缓存后端。
+func InitMemoryCache() error {
+	s := &memoryStore{entries: make(map[string]*memoryEntry), lists: make(map[string]*memoryList), flights: make(map[string]*memoryFlight), notify: make(chan struct{})}
+	go s.purgeForEver()
+
+	Use(s)
+	return nil
+}
+
+// 获取未过期的缓存项，调用者必须持有锁。
+func (s *memoryStore) entry(key string) *memoryEntry {
+	if e, ok := s.entries[key]; !ok {
+		return nil
+	} else if e.expired(time.Now()) {
+		delete(s.entries, key)
+		return nil
+	} else {
+		return e
+	}
+}
+
+func (s *memoryStore) update(key string, fields map[string]interface{}) *memoryEntry {
+	e := s.entry(key)
+	if e == nil {
+		e = &memoryEntry{fields: make(map[string]string)}
+		s.entries[key] = e
+	}
+
+	for hk, hv := range fields {
+		e.fields[hk] = formatValue(hv)
+	}
+
+	return e
+}
+
+func (s *memoryStore) SetAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.update(key, fields).expireAt = time.Now().Add(expiration)
+	return nil
+}
+
+func (s *memoryStore) ReplaceAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.entry(key) == nil {
+		return false, nil
+	}
+
+	s.update(key, fields).expireAt = time.Now().Add(expiration)
+	return true, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, key string, fields map[string]interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.update(key, fields)
+	return nil
+}
+
+func (s *memoryStore) get(key string, fields []string) []interface{} {
+	result := make([]interface{}, len(fields))
+	if e := s.entry(key); e != nil {
+		for i, f := range fields {
+			if v, ok := e.fields[f]; ok {
+				result[i] = v
+			}
+		}
+	}
+
+	return result
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(key, fields)
+	for _, o := range r {
+		if o != nil {
+			return r, nil
+		}
+	}
+
+	return nil, Nil
+}
+
+func (s *memoryStore) Del(ctx context.Context, key string) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.entry(key) != nil {
+		delete(s.entries, key)
+		return 1, nil
+	}
+	if l, ok := s.lists[key]; ok && time.Now().Before(l.expireAt) {
+		delete(s.lists, key)
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(key, fields)
+	delete(s.entries, key)
+
+	return r, nil
+}
+
+func (s *memoryStore) GetAndExpire(ctx context.Context, key string, expiration time.Duration, fields ...string) ([]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(key, fields)
+	if e := s.entry(key); e != nil {
+		e.expireAt = time.Now().Add(expiration)
+	}
+
+	return r, nil
+}
+
+func (s *memoryStore) PushAndExpire(ctx context.Context, key string, value string, expiration time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	l, ok := s.lists[key]
+	if !ok || !time.Now().Before(l.expireAt) {
+		l = &memoryList{}
+		s.lists[key] = l
+	}
+	l.values = append(l.values, value)
+	l.expireAt = time.Now().Add(expiration)
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+
+	return nil
+}
+
+func (s *memoryStore) BPop(ctx context.Context, key string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		s.lock.Lock()
+		if l, ok := s.lists[key]; ok && time.Now().Before(l.expireAt) && len(l.values) != 0 {
+			v := l.values[0]
+			l.values = l.values[1:]
+			if len(l.values) == 0 {
+				delete(s.lists, key)
+			}
+			s.lock.Unlock()
+
+			return v, nil
+		}
+		notify := s.notify
+		s.lock.Unlock()
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return "", Nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+			return "", Nil
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (s *memoryStore) JoinFlight(ctx context.Context, flightKey string, member string, expiration time.Duration) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if f, ok := s.flights[flightKey]; ok && time.Now().Before(f.expireAt) {
+		f.followers = append(f.followers, member)
+		return f.leader, nil
+	}
+
+	s.flights[flightKey] = &memoryFlight{leader: member, expireAt: time.Now().Add(expiration)}
+	return "", nil
+}
+
+func (s *memoryStore) LandFlight(ctx context.Context, flightKey string, leader string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if f, ok := s.flights[flightKey]; !ok || f.leader != leader || !time.Now().Before(f.expireAt) {
+		return nil, nil
+	} else {
+		delete(s.flights, flightKey)
+		return f.followers, nil
+	}
+}
+
+// 定期清理过期的缓存项，避免从未被读取的缓存项一直占用内存。
+func (s *memoryStore) purgeForEver() {
+	for {
+		time.Sleep(memoryPurgeInterval)
+
+		now := time.Now()
+
+		s.lock.Lock()
+		for key, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, key)
+			}
+		}
+		for key, l := range s.lists {
+			if !now.Before(l.expireAt) {
+				delete(s.lists, key)
+			}
+		}
+		for key, f := range s.flights {
+			if !now.Before(f.expireAt) {
+				delete(s.flights, key)
+			}
+		}
+		s.lock.Unlock()
+	}
+}
+
+// 将值转换为字符串，转换规则和Redis客户端一致。
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
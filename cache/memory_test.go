@@ -0,0 +1,293 @@
+// @Author: agent
+// @Created: 2026-10-18
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func initTestCache(t *testing.T) context.Context {
+	if err := InitMemoryCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	return context.Background()
+}
+
+func TestMemoryGetAndUpdate(t *testing.T) {
+	ctx := initTestCache(t)
+
+	now := time.Date(2021, 10, 27, 8, 30, 0, 0, time.UTC)
+	if err := SetAndExpire(ctx, "k", map[string]interface{}{"s": "x", "i": 1, "b": true, "f": 1.5, "t": now, "n": nil}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// 和Redis一样，读取的值总是字符串，不存在的字段是nil。
+	got, err := Get(ctx, "k", "s", "i", "b", "f", "t", "n", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"x", "1", "1", "1.5", now.Format(time.RFC3339Nano), "", nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get = %#v, want %#v", got, want)
+	}
+
+	if err := Update(ctx, "k", map[string]interface{}{"s": "y", "status": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Get(ctx, "k", "s", "status", "i"); !reflect.DeepEqual(got, []interface{}{"y", "1", "1"}) {
+		t.Errorf("Get after Update = %#v", got)
+	}
+
+	// 所有字段都不存在时返回Nil。
+	if _, err := Get(ctx, "k", "missing"); !errors.Is(err, Nil) {
+		t.Errorf("Get of missing fields error = %v, want Nil", err)
+	}
+	if _, err := Get(ctx, "none", "s"); !errors.Is(err, Nil) {
+		t.Errorf("Get of missing key error = %v, want Nil", err)
+	}
+
+	// 和HSET一样，更新不存在的键会创建它。
+	if err := Update(ctx, "new", map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Get(ctx, "new", "a"); err != nil || got[0] != "1" {
+		t.Errorf("Get after Update of missing key = %#v, %v", got, err)
+	}
+}
+
+func TestMemoryExpiration(t *testing.T) {
+	ctx := initTestCache(t)
+
+	SetAndExpire(ctx, "short", map[string]interface{}{"a": 1}, 30*time.Millisecond)
+	SetAndExpire(ctx, "long", map[string]interface{}{"a": 1}, time.Minute)
+
+	// 更新不改变过期时间。
+	Update(ctx, "short", map[string]interface{}{"b": 2})
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := Get(ctx, "short", "a", "b"); !errors.Is(err, Nil) {
+		t.Errorf("Get of expired key error = %v, want Nil", err)
+	}
+	if _, err := Get(ctx, "long", "a"); err != nil {
+		t.Errorf("Get of unexpired key error = %v", err)
+	}
+
+	// 过期之后再次设置，不保留之前的字段。
+	SetAndExpire(ctx, "short", map[string]interface{}{"c": 3}, time.Minute)
+	if got, _ := Get(ctx, "short", "a", "c"); !reflect.DeepEqual(got, []interface{}{nil, "3"}) {
+		t.Errorf("Get after re-set = %#v", got)
+	}
+
+	// GetAndExpire延长过期时间。
+	SetAndExpire(ctx, "extend", map[string]interface{}{"a": 1}, 30*time.Millisecond)
+	if got, err := GetAndExpire(ctx, "extend", time.Minute, "a"); err != nil || got[0] != "1" {
+		t.Errorf("GetAndExpire = %#v, %v", got, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := Get(ctx, "extend", "a"); err != nil {
+		t.Errorf("Get after GetAndExpire error = %v", err)
+	}
+
+	// 和HMGET一样，GetAndExpire和Take读取不存在的键时返回全是nil的结果，而不是Nil。
+	if got, err := GetAndExpire(ctx, "none", time.Minute, "a"); err != nil || got[0] != nil {
+		t.Errorf("GetAndExpire of missing key = %#v, %v", got, err)
+	}
+}
+
+func TestMemoryReplaceAndExpire(t *testing.T) {
+	ctx := initTestCache(t)
+
+	SetAndExpire(ctx, "k", map[string]interface{}{"a": 1}, 30*time.Millisecond)
+	if ok, err := ReplaceAndExpire(ctx, "k", map[string]interface{}{"b": 2}, time.Minute); err != nil || !ok {
+		t.Fatalf("ReplaceAndExpire of existing key = %v, %v", ok, err)
+	}
+
+	// 保留原有的内容，并且使用新的过期时间。
+	time.Sleep(50 * time.Millisecond)
+	if got, err := Get(ctx, "k", "a", "b"); err != nil || got[0] != "1" || got[1] != "2" {
+		t.Errorf("Get after ReplaceAndExpire = %#v, %v", got, err)
+	}
+
+	// 缓存不存在或者已经过期时不生成新的缓存。
+	SetAndExpire(ctx, "short", map[string]interface{}{"a": 1}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"missing", "short"} {
+		if ok, err := ReplaceAndExpire(ctx, key, map[string]interface{}{"b": 2}, time.Minute); err != nil || ok {
+			t.Errorf("ReplaceAndExpire of %s = %v, %v", key, ok, err)
+		}
+		if _, err := Get(ctx, key, "b"); !errors.Is(err, Nil) {
+			t.Errorf("Get after ReplaceAndExpire of %s = %v, want Nil", key, err)
+		}
+	}
+}
+
+func TestMemoryDelAndTake(t *testing.T) {
+	ctx := initTestCache(t)
+
+	SetAndExpire(ctx, "k", map[string]interface{}{"a": 1}, time.Minute)
+	if n, err := Del(ctx, "k"); err != nil || n != 1 {
+		t.Errorf("Del = %d, %v, want 1", n, err)
+	}
+	if n, err := Del(ctx, "k"); err != nil || n != 0 {
+		t.Errorf("Del of missing key = %d, %v, want 0", n, err)
+	}
+
+	PushAndExpire(ctx, "list", "v", time.Minute)
+	if n, err := Del(ctx, "list"); err != nil || n != 1 {
+		t.Errorf("Del of list = %d, %v, want 1", n, err)
+	}
+	if _, err := BPop(ctx, "list", 10*time.Millisecond); !errors.Is(err, Nil) {
+		t.Errorf("BPop of deleted list error = %v, want Nil", err)
+	}
+
+	SetAndExpire(ctx, "k", map[string]interface{}{"a": 1, "b": 2}, time.Minute)
+	if got, err := Take(ctx, "k", "a", "c"); err != nil || !reflect.DeepEqual(got, []interface{}{"1", nil}) {
+		t.Errorf("Take = %#v, %v", got, err)
+	}
+	if _, err := Get(ctx, "k", "b"); !errors.Is(err, Nil) {
+		t.Errorf("Get after Take error = %v, want Nil", err)
+	}
+	if got, err := Take(ctx, "k", "a"); err != nil || got[0] != nil {
+		t.Errorf("Take of missing key = %#v, %v", got, err)
+	}
+}
+
+func TestMemoryBPop(t *testing.T) {
+	ctx := initTestCache(t)
+
+	// 先进先出。
+	PushAndExpire(ctx, "list", "a", time.Minute)
+	PushAndExpire(ctx, "list", "b", time.Minute)
+	for _, want := range []string{"a", "b"} {
+		if v, err := BPop(ctx, "list", time.Second); err != nil || v != want {
+			t.Errorf("BPop = %q, %v, want %q", v, err, want)
+		}
+	}
+
+	// 超时返回Nil，并且至少阻塞到超时。
+	start := time.Now()
+	if _, err := BPop(ctx, "list", 50*time.Millisecond); !errors.Is(err, Nil) {
+		t.Errorf("BPop of empty list error = %v, want Nil", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("BPop blocked %s, want about 50ms", elapsed)
+	}
+
+	// 阻塞期间追加的值立刻被取出，追加到其它列表不影响。
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		PushAndExpire(ctx, "other", "x", time.Minute)
+		time.Sleep(20 * time.Millisecond)
+		PushAndExpire(ctx, "list", "c", time.Minute)
+	}()
+	start = time.Now()
+	if v, err := BPop(ctx, "list", 5*time.Second); err != nil || v != "c" {
+		t.Errorf("BPop = %q, %v, want c", v, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("BPop blocked %s after push", elapsed)
+	}
+
+	// 上下文被取消时返回上下文的错误。
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := BPop(cctx, "empty", 5*time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("BPop error = %v, want context.Canceled", err)
+	}
+
+	// 过期的列表被看作不存在。
+	PushAndExpire(ctx, "expiring", "v", 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if _, err := BPop(ctx, "expiring", 10*time.Millisecond); !errors.Is(err, Nil) {
+		t.Errorf("BPop of expired list error = %v, want Nil", err)
+	}
+}
+
+func TestMemoryBPopConcurrent(t *testing.T) {
+	ctx := initTestCache(t)
+
+	// 每个值只被一个协程取出。
+	const n = 50
+	got := make(chan string, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, err := BPop(ctx, "list", 100*time.Millisecond)
+				if err != nil {
+					return
+				}
+				got <- v
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		PushAndExpire(ctx, "list", string(rune('A'+i)), time.Minute)
+	}
+	wg.Wait()
+	close(got)
+
+	seen := make(map[string]bool)
+	for v := range got {
+		if seen[v] {
+			t.Errorf("value %q popped twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != n {
+		t.Errorf("popped %d values, want %d", len(seen), n)
+	}
+}
+
+func TestMemoryFlight(t *testing.T) {
+	ctx := initTestCache(t)
+
+	if leader, err := JoinFlight(ctx, "f", "a", time.Minute); err != nil || leader != "" {
+		t.Fatalf("JoinFlight = %q, %v, want leader", leader, err)
+	}
+	for _, m := range []string{"b", "c"} {
+		if leader, err := JoinFlight(ctx, "f", m, time.Minute); err != nil || leader != "a" {
+			t.Errorf("JoinFlight(%s) = %q, %v, want a", m, leader, err)
+		}
+	}
+
+	// 只有领导者可以结束查询。
+	if followers, err := LandFlight(ctx, "f", "b"); err != nil || len(followers) != 0 {
+		t.Errorf("LandFlight by follower = %v, %v, want none", followers, err)
+	}
+	followers, err := LandFlight(ctx, "f", "a")
+	sort.Strings(followers)
+	if err != nil || !reflect.DeepEqual(followers, []string{"b", "c"}) {
+		t.Errorf("LandFlight = %v, %v, want [b c]", followers, err)
+	}
+
+	// 结束之后，下一个成员成为新的领导者。
+	if leader, err := JoinFlight(ctx, "f", "d", time.Minute); err != nil || leader != "" {
+		t.Errorf("JoinFlight after landing = %q, %v, want leader", leader, err)
+	}
+	if followers, err := LandFlight(ctx, "f", "a"); err != nil || len(followers) != 0 {
+		t.Errorf("LandFlight by old leader = %v, %v, want none", followers, err)
+	}
+
+	// 过期的查询自动结束。
+	JoinFlight(ctx, "g", "a", 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if leader, err := JoinFlight(ctx, "g", "b", time.Minute); err != nil || leader != "" {
+		t.Errorf("JoinFlight after expiration = %q, %v, want leader", leader, err)
+	}
+	if followers, err := LandFlight(ctx, "missing", "a"); err != nil || len(followers) != 0 {
+		t.Errorf("LandFlight of missing flight = %v, %v", followers, err)
+	}
+}
@@ -0,0 +1,233 @@
+// 该模块实现了基于Redis的缓存后端。
+// @Author: agent
+// @Created: 2026-10-18
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	_redisclient "com.cne/ai-tracking-search/redisclient"
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStore struct {
+	client redis.UniversalClient
+}
+
+const (
+	flightFollowersSuffix string = "$F" // 查询的跟随者列表的键的后缀。
+
+	bpopPollInterval time.Duration = 50 * time.Millisecond // 阻塞取值的剩余时间不足1秒时，非阻塞地取值的间隔。
+)
+
+var (
+	// 加入正在进行的查询。
+	// KEYS[1] 查询的键。KEYS[2] 跟随者列表的键。
+	// ARGV[1] 成员的键。ARGV[2] 过期时间（毫秒）。
+	joinFlightScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return ''
+end
+redis.call('RPUSH', KEYS[2], ARGV[1])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+return redis.call('GET', KEYS[1])
+`)
+
+	// 如果散列存在，那么保存值并设置过期时间。
+	// KEYS[1] 散列的键。
+	// ARGV[1] 过期时间（毫秒）。之后是成对的字段名和值。
+	replaceScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+	// 结束正在进行的查询。
+	// KEYS[1] 查询的键。KEYS[2] 跟随者列表的键。
+	// ARGV[1] 领导者的键。
+	landFlightScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return {}
+end
+local followers = redis.call('LRANGE', KEYS[2], 0, -1)
+redis.call('DEL', KEYS[1], KEYS[2])
+return followers
+`)
+)
+
+// 初始化Redis缓存，并作为当前的缓存后端。
+// 使用共享的Redis客户端，必须首先初始化共享的Redis客户端。
+func InitRedisCache() error {
+	client := _redisclient.Client()
+	if client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	Use(&redisStore{client: client})
+	return nil
+}
+
+// 返回Redis中实际使用的键。
+// 集群模式下整个键作为散列标签，所以附加了后缀的相关的键（比如跟随者列表）和它在同一个槽中。
+func (s *redisStore) key(key string) string {
+	return _redisclient.Key(key, "")
+}
+
+// Deprecated 此方法会清除之前设置的过期时间。
+// func Set(key string, fields map[string]interface{}) error {
+// 	_, err := redisClient.HMSet(key, fields).Result()
+// 	return err
+// }
+
+func (s *redisStore) SetAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) error {
+	p := s.client.TxPipeline()
+
+	p.HMSet(ctx, s.key(key), fields)
+	p.Expire(ctx, s.key(key), expiration)
+
+	if _, err := p.Exec(ctx); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (s *redisStore) ReplaceAndExpire(ctx context.Context, key string, fields map[string]interface{}, expiration time.Duration) (bool, error) {
+	args := make([]interface{}, 0, len(fields)*2+1)
+	args = append(args, expiration.Milliseconds())
+	for hk, hv := range fields {
+		args = append(args, hk, hv)
+	}
+
+	if r, err := replaceScript.Run(ctx, s.client, []string{s.key(key)}, args...).Int(); err != nil {
+		return false, err
+	} else {
+		return r == 1, nil
+	}
+}
+
+func (s *redisStore) Update(ctx context.Context, key string, fields map[string]interface{}) error {
+	p := s.client.Pipeline()
+
+	for hk, hv := range fields {
+		p.HSet(ctx, s.key(key), hk, hv)
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	if r, err := s.client.HMGet(ctx, s.key(key), fields...).Result(); err != nil {
+		return nil, err
+	} else {
+		allNil := true
+		for _, o := range r {
+			if o != nil {
+				allNil = false
+			}
+		}
+		if allNil {
+			return nil, Nil
+		} else {
+			return r, nil
+		}
+	}
+}
+
+func (s *redisStore) Del(ctx context.Context, key string) (int64, error) {
+	return s.client.Del(ctx, s.key(key)).Result()
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	p := s.client.Pipeline()
+
+	p.HMGet(ctx, s.key(key), fields...)
+	p.Del(ctx, s.key(key))
+
+	if cc, err := p.Exec(ctx); err != nil {
+		return nil, err
+	} else {
+		return cc[0].(*redis.SliceCmd).Result()
+	}
+}
+
+func (s *redisStore) GetAndExpire(ctx context.Context, key string, expiration time.Duration, fields ...string) ([]interface{}, error) {
+	p := s.client.Pipeline()
+
+	p.HMGet(ctx, s.key(key), fields...).Result()
+	p.Expire(ctx, s.key(key), expiration)
+
+	if cc, err := p.Exec(ctx); err != nil {
+		return nil, err
+	} else {
+		return cc[0].(*redis.SliceCmd).Result()
+	}
+}
+
+func (s *redisStore) PushAndExpire(ctx context.Context, key string, value string, expiration time.Duration) error {
+	p := s.client.TxPipeline()
+
+	p.RPush(ctx, s.key(key), value)
+	p.Expire(ctx, s.key(key), expiration)
+
+	if _, err := p.Exec(ctx); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+// BLPOP的超时以秒为单位，go-redis会将不足1秒的超时向上取整为1秒，使调用者超过自己的截止时间。
+// 所以整秒的部分使用BLPOP阻塞，剩余不足1秒的部分每隔`bpopPollInterval`非阻塞地取值，总的等待时间不会超过超时时间。
+func (s *redisStore) BPop(ctx context.Context, key string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+
+	if seconds := timeout.Truncate(time.Second); seconds > 0 {
+		if r, err := s.client.BLPop(ctx, seconds, s.key(key)).Result(); err == nil {
+			return r[1], nil
+		} else if err != redis.Nil {
+			return "", err
+		}
+	}
+
+	for {
+		if v, err := s.client.LPop(ctx, s.key(key)).Result(); err == nil {
+			return v, nil
+		} else if err != redis.Nil {
+			return "", err
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return "", Nil
+		}
+		if wait > bpopPollInterval {
+			wait = bpopPollInterval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (s *redisStore) JoinFlight(ctx context.Context, flightKey string, member string, expiration time.Duration) (string, error) {
+	return joinFlightScript.Run(ctx, s.client, []string{s.key(flightKey), _redisclient.Key(flightKey, flightFollowersSuffix)}, member, expiration.Milliseconds()).Text()
+}
+
+func (s *redisStore) LandFlight(ctx context.Context, flightKey string, leader string) ([]string, error) {
+	return landFlightScript.Run(ctx, s.client, []string{s.key(flightKey), _redisclient.Key(flightKey, flightFollowersSuffix)}, leader).StringSlice()
+}
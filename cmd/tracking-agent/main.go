@@ -0,0 +1,99 @@
+// 该模块是查询代理工作进程的入口模块。
+// 查询代理工作进程从队列中获取查询对象，调用查询代理，并将结果写入缓存。可以独立于查询接口服务扩容和重启。
+// @Author: agent
+// @Created: 2026-10-18
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	_agent "com.cne/ai-tracking-search/agent"
+	_app "com.cne/ai-tracking-search/app"
+	_config "com.cne/ai-tracking-search/config"
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_queue "com.cne/ai-tracking-search/queue"
+	_rpcclient "com.cne/ai-tracking-search/rpcclient"
+)
+
+const (
+	AppName    string = "tracking-agent" // 表示应用程序名。
+	AppVersion string = "0.1.0"          // 表示应用程序版本。
+)
+
+var (
+	metricsServer *http.Server // 输出指标和存活检查的HTTP服务器，没有配置绑定地址时是nil。
+)
+
+func main() {
+	app := _app.App{Name: AppName, Version: AppVersion, Init: doInit, Serve: doServe, Shutdown: doShutdown, Reload: doReload, ReloadSections: []string{"Worker"}}
+	app.Run()
+}
+
+func doInit(configuration *_config.Configuration) error {
+	if err := _agent.Configure(&configuration.Worker); err != nil {
+		return err
+	}
+
+	if configuration.Worker.MetricsListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", _metrics.Handler(_agent.CollectQueueDepth))
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"status":"ok"}`))
+		})
+		metricsServer = &http.Server{Addr: configuration.Worker.MetricsListen, Handler: mux}
+	}
+
+	return nil
+}
+
+func doServe(configuration *_config.Configuration) error {
+	fmt.Printf("Polling with %d workers\n", configuration.Worker.Concurrency.Total())
+
+	fmt.Printf("Persisting with %d workers\n", configuration.Worker.Persisters)
+
+	if err := _rpcclient.StartPersisters(&configuration.Worker); err != nil {
+		return err
+	}
+
+	if metricsServer != nil {
+		fmt.Printf("Serving metrics @ %s\n", configuration.Worker.MetricsListen)
+
+		go func() {
+			if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[ERROR] Cannot serve metrics. cause=%s\n", err)
+			}
+		}()
+	}
+
+	_agent.PollForEver()
+
+	return nil
+}
+
+// 使批量的时间窗口和缓存的过期时间生效。
+func doReload(configuration *_config.Configuration) error {
+	return _agent.Reconfigure(&configuration.Worker)
+}
+
+// 停止出队，等待正在处理的查询对象和正在保存的完成通知处理完毕，然后退出消费者组，最后停止输出指标。
+func doShutdown(ctx context.Context) error {
+	err := _agent.Shutdown(ctx)
+	if e := _rpcclient.StopPersisters(ctx); err == nil {
+		err = e
+	}
+	if e := _queue.Leave(ctx); err == nil {
+		err = e
+	}
+	if metricsServer != nil {
+		if e := metricsServer.Shutdown(ctx); err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+// 该模块是查询接口服务的入口模块。
+// 查询接口服务接收客户端的查询请求，将需要爬取的查询对象推送到队列，并等待查询代理工作进程返回结果。
+// @Author: agent
+// @Created: 2026-10-18
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	_app "com.cne/ai-tracking-search/app"
+	_config "com.cne/ai-tracking-search/config"
+	_freshness "com.cne/ai-tracking-search/freshness"
+	_rpc "com.cne/ai-tracking-search/rpc"
+)
+
+const (
+	AppName    string = "tracking-search" // 表示应用程序名。
+	AppVersion string = "0.1.0"           // 表示应用程序版本。
+)
+
+var (
+	server         *http.Server // 查询接口服务的HTTP服务器。
+	internalServer *http.Server // 输出指标和依赖诊断的HTTP服务器，没有配置内部绑定地址时是nil。
+)
+
+func main() {
+	app := _app.App{Name: AppName, Version: AppVersion, Init: doInit, Serve: doServe, Shutdown: doShutdown, Reload: doReload, ReloadSections: []string{"Search", "Freshness"}}
+	app.Run()
+}
+
+func doInit(configuration *_config.Configuration) error {
+	if err := doReload(configuration); err != nil {
+		return err
+	}
+
+	if err := _rpc.InitPersistence(&configuration.Persistence); err != nil {
+		return err
+	}
+
+	server = _rpc.NewServer(&configuration.Server)
+	internalServer = _rpc.NewInternalServer(&configuration.Server)
+	return nil
+}
+
+func doServe(configuration *_config.Configuration) error {
+	if internalServer != nil {
+		fmt.Printf("Serving metrics and diagnostics @ %s\n", configuration.Server.InternalListen)
+
+		go func() {
+			if err := internalServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[ERROR] Cannot serve metrics and diagnostics. cause=%s\n", err)
+			}
+		}()
+	}
+
+	fmt.Printf("Serving @ %s\n", configuration.Server.Listen)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// 使有效期策略和查询参数生效，启动时和重新加载配置时执行。
+func doReload(configuration *_config.Configuration) error {
+	if err := _freshness.InitFreshness(&configuration.Freshness); err != nil {
+		return err
+	}
+
+	_rpc.ConfigureSearch(&configuration.Search)
+	return nil
+}
+
+// 停止接收新的请求，等待正在处理的请求完成，然后写完待保存的查询日志，最后停止输出指标和依赖诊断。
+func doShutdown(ctx context.Context) error {
+	// 即使等待请求超时，也要写完或者溢出待保存的查询日志。
+	err := server.Shutdown(ctx)
+	if e := _rpc.ClosePersistence(ctx); err == nil {
+		err = e
+	}
+	if internalServer != nil {
+		if e := internalServer.Shutdown(ctx); err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+// 该模块是单进程应用程序的入口模块。
+// 单进程应用程序在同一个进程中运行查询接口服务和查询代理工作进程，可以使用内存后端，不需要Redis，适用于开发和集成测试。
+// @Author: agent
+// @Created: 2026-10-18
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	_agent "com.cne/ai-tracking-search/agent"
+	_app "com.cne/ai-tracking-search/app"
+	_config "com.cne/ai-tracking-search/config"
+	_freshness "com.cne/ai-tracking-search/freshness"
+	_queue "com.cne/ai-tracking-search/queue"
+	_rpc "com.cne/ai-tracking-search/rpc"
+	_rpcclient "com.cne/ai-tracking-search/rpcclient"
+)
+
+const (
+	AppName    string = "tracking-standalone" // 表示应用程序名。
+	AppVersion string = "0.1.0"               // 表示应用程序版本。
+)
+
+var (
+	server         *http.Server // 查询接口服务的HTTP服务器。
+	internalServer *http.Server // 输出指标和依赖诊断的HTTP服务器，没有配置内部绑定地址时是nil。
+)
+
+func main() {
+	app := _app.App{Name: AppName, Version: AppVersion, Standalone: true, Init: doInit, Serve: doServe, Shutdown: doShutdown, Reload: doReload, ReloadSections: []string{"Search", "Freshness", "Worker"}}
+	app.Run()
+}
+
+func doInit(configuration *_config.Configuration) error {
+	_rpc.ConfigureSearch(&configuration.Search)
+
+	if err := _freshness.InitFreshness(&configuration.Freshness); err != nil {
+		return err
+	}
+
+	if err := _rpc.InitPersistence(&configuration.Persistence); err != nil {
+		return err
+	}
+
+	if err := _agent.Configure(&configuration.Worker); err != nil {
+		return err
+	}
+
+	server = _rpc.NewServer(&configuration.Server)
+	internalServer = _rpc.NewInternalServer(&configuration.Server)
+	return nil
+}
+
+func doServe(configuration *_config.Configuration) error {
+	fmt.Printf("Polling with %d workers\n", configuration.Worker.Concurrency.Total())
+
+	fmt.Printf("Persisting with %d workers\n", configuration.Worker.Persisters)
+
+	if err := _rpcclient.StartPersisters(&configuration.Worker); err != nil {
+		return err
+	}
+
+	go _agent.PollForEver()
+
+	if internalServer != nil {
+		fmt.Printf("Serving metrics and diagnostics @ %s\n", configuration.Server.InternalListen)
+
+		go func() {
+			if err := internalServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[ERROR] Cannot serve metrics and diagnostics. cause=%s\n", err)
+			}
+		}()
+	}
+
+	fmt.Printf("Serving @ %s\n", configuration.Server.Listen)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// 使有效期策略、查询参数、批量的时间窗口和缓存的过期时间生效。
+// 有效期配置最先生效，有误时不修改其它参数。
+func doReload(configuration *_config.Configuration) error {
+	if err := _freshness.InitFreshness(&configuration.Freshness); err != nil {
+		return err
+	}
+
+	_rpc.ConfigureSearch(&configuration.Search)
+	return _agent.Reconfigure(&configuration.Worker)
+}
+
+// 首先停止接收新的请求并等待正在处理的请求完成，此时查询代理工作进程仍然在处理这些请求的查询对象。
+// 然后停止出队，等待正在处理的查询对象和正在保存的完成通知处理完毕并退出消费者组，再写完待保存的查询日志，最后停止输出指标和依赖诊断。
+func doShutdown(ctx context.Context) error {
+	// 即使某一步超时，之后的步骤也要执行，超过截止时间的工作被放回队列或者溢出到磁盘。
+	err := server.Shutdown(ctx)
+	if e := _agent.Shutdown(ctx); err == nil {
+		err = e
+	}
+	if e := _rpcclient.StopPersisters(ctx); err == nil {
+		err = e
+	}
+	if e := _queue.Leave(ctx); err == nil {
+		err = e
+	}
+	if e := _rpc.ClosePersistence(ctx); err == nil {
+		err = e
+	}
+	if internalServer != nil {
+		if e := internalServer.Shutdown(ctx); err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,626 @@
+// 该模块定义了配置类型，以及加载配置的方法。
+// 查询接口服务和查询代理工作进程共享同一个配置格式，各自使用自己的配置节。
+// @Author: agent
+// @Created: 2026-10-18
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultListenAddress   string = ":8001" // 表示默认的监听地址。
+	DefaultInternalListen  string = ":8003" // 表示默认的查询接口服务输出指标和依赖诊断的监听地址。
+	DefaultTimeout         int    = 30      // 表示默认的请求超时秒数。
+	DefaultShutdownTimeout int    = 60      // 表示默认的退出时等待正在处理的请求和查询对象的秒数。
+
+	BackendRedis   string = "redis"      // 表示使用Redis作为队列、缓存和限制的后端。
+	BackendMemory  string = "memory"     // 表示使用进程内的内存作为队列、缓存和限制的后端。
+	DefaultBackend string = BackendRedis // 表示默认的后端。
+
+	RedisModeStandalone string = "standalone"        // 表示连接单个Redis实例。
+	RedisModeSentinel   string = "sentinel"          // 表示通过哨兵连接Redis主节点，主节点故障时自动切换。
+	RedisModeCluster    string = "cluster"           // 表示连接Redis集群。
+	DefaultRedisMode    string = RedisModeStandalone // 表示默认的Redis部署方式。
+
+	DefaultRedisHost         string = "localhost" // 表示默认的Redis主机地址。
+	DefaultRedisPort         int    = 6379        // 表示默认的Redis端口号。
+	DefaultRedisPassword     string = ""          // 表示默认的Redis口令。
+	DefaultRedisDB           int    = 0           // 表示默认的Redis数据库。
+	DefaultRedisDialTimeout  int    = 5000        // 表示默认的建立连接的超时（毫秒）。
+	DefaultRedisReadTimeout  int    = 3000        // 表示默认的读取超时（毫秒）。
+	DefaultRedisWriteTimeout int    = 3000        // 表示默认的写入超时（毫秒）。
+
+	DefaultDBMaxOpenConns    int = 100     // 表示默认的最大连接数。
+	DefaultDBMaxIdleConns    int = 90      // 表示默认的最大空闲连接数。
+	DefaultDBConnMaxLifetime int = 20 * 60 // 表示默认的连接最长使用时间（秒）。
+	DefaultDBConnMaxIdleTime int = 0       // 表示默认的连接最长空闲时间（秒），不限制。
+	DefaultDBDialTimeout     int = 5       // 表示默认的建立连接的超时（秒）。
+
+	DefaultWorkerHighestConcurrency int = 40  // 表示默认的最高优先级工作协程数。
+	DefaultWorkerHighConcurrency    int = 60  // 表示默认的高优先级工作协程数。
+	DefaultWorkerLowConcurrency     int = 100 // 表示默认的低优先级工作协程数。
+	DefaultWorkerHighestWeight      int = 6   // 表示默认的最高优先级调度权重。
+	DefaultWorkerHighWeight         int = 3   // 表示默认的高优先级调度权重。
+	DefaultWorkerLowWeight          int = 1   // 表示默认的低优先级调度权重。
+	DefaultWorkerAgingSeconds       int = 30  // 表示默认的提升等待队列的阈值（秒）。
+	DefaultWorkerBatchWindow        int = 300 // 表示默认的批量收集时间窗口（毫秒）。
+	DefaultWorkerVisibilityTimeout  int = 120 // 表示默认的未确认消息的可见性超时（秒）。
+	DefaultWorkerMaxDeliveries      int = 3   // 表示默认的消息最大投递次数。
+	DefaultWorkerPersisters         int = 4   // 表示默认的持久化协程数。
+	DefaultWorkerReplyExpiration    int = 60  // 表示默认的完成通知列表的过期时间（秒）。
+	DefaultWorkerResultExpiration   int = 10  // 表示默认的查询结果在缓存中的过期时间（秒）。
+
+	DefaultWorkerMetricsListen string = ":8002" // 表示默认的查询代理工作进程输出指标的监听地址。
+
+	DefaultSearchMaxOrders        int = 30    // 表示默认的每个查询请求中允许包含的最多运单号。
+	DefaultSearchMaxQueueLength   int = 10000 // 表示默认的查询队列的最大长度。
+	DefaultSearchPullTimeout      int = 15    // 表示默认的等待查询代理返回结果的超时时间（秒）。
+	DefaultSearchSearchExpiration int = 120   // 表示默认的查询对象在缓存中的过期时间（秒）。
+
+	DefaultPersistenceQueueSize       int    = 10000     // 表示默认的持久化队列容量。
+	DefaultPersistenceBatchSize       int    = 100       // 表示默认的每批写入的记录数。
+	DefaultPersistenceFlushInterval   int    = 1000      // 表示默认的不足一批的记录的最长等待时间（毫秒）。
+	DefaultPersistenceWriters         int    = 2         // 表示默认的写入协程数。
+	DefaultPersistenceJournalDir      string = "journal" // 表示默认的磁盘日志目录。
+	DefaultPersistenceJournalMaxBytes int64  = 256 << 20 // 表示默认的磁盘日志最大字节数。
+	DefaultPersistenceReplayInterval  int    = 10        // 表示默认的重放磁盘日志的间隔（秒）。
+
+	DefaultFreshnessPreTransit int = 8 * 3600 // 表示默认的尚无事件的运单的有效期（秒）。
+	DefaultFreshnessInTransit  int = 2 * 3600 // 表示默认的运输途中的运单的有效期（秒）。
+	DefaultFreshnessException  int = 2 * 3600 // 表示默认的投递异常的运单的有效期（秒）。
+	DefaultFreshnessDelivered  int = -1       // 表示默认的已妥投的运单的有效期（秒），永不过期。
+)
+
+// Configuration 表示全局配置对象。
+type Configuration struct {
+	Server ServerConfiguration // 查询接口服务配置。
+
+	Search SearchConfiguration `config:"reload"` // 查询接口服务处理查询请求的配置。
+
+	Worker WorkerConfiguration // 查询代理工作进程配置。
+
+	DB DBConfiguration // 数据库设置。
+
+	Freshness FreshnessConfiguration `config:"reload"` // 数据库中的跟踪记录的有效期配置。
+
+	Persistence PersistenceConfiguration // 查询接口服务异步保存查询日志的配置。
+
+	Redis RedisConfiguration // Redis配置。
+
+	Backend string `config:"enum=redis|memory"` // 队列、缓存和限制的后端，可以是`redis`或者`memory`。`memory`只能用于在同一个进程中运行查询接口服务和查询代理工作进程的应用程序。
+
+	ShutdownTimeout int `config:"min=1,reload"` // 收到退出信号后，等待正在处理的请求、查询对象和待保存的记录的最长时间（秒）。
+}
+
+type ServerConfiguration struct {
+	Listen         string // 提供服务的绑定地址。
+	InternalListen string // 输出指标和依赖诊断的绑定地址，只应当在内部网络中可以访问，为空表示不输出。
+	Timeout        int    `config:"min=1"` // 读取请求的超时和每个请求的截止时间（秒），写入响应的超时比此时间多5秒。
+}
+
+// 表示查询接口服务处理查询请求的配置。
+type SearchConfiguration struct {
+	MaxOrders        int `config:"min=1,max=1000"` // 每个查询请求中允许包含的最多运单号。
+	MaxQueueLength   int `config:"min=1"`          // 查询队列的最大长度，队列已满时拒绝新的查询对象。
+	PullTimeout      int `config:"min=1"`          // 等待查询代理返回结果的超时时间（秒），实际等待的时间不超过请求的截止时间。
+	SearchExpiration int `config:"min=1"`          // 查询对象在缓存中的过期时间（秒），超过此时间尚未被查询代理执行则放弃。
+}
+
+type WorkerConfiguration struct {
+	Concurrency  WorkerConcurrencyConfiguration // 每个优先级的工作协程数。
+	Weights      WorkerWeightsConfiguration     // 每个优先级的调度权重。
+	AgingSeconds int                            `config:"min=0"`                 // 查询对象等待超过此时间（秒）后，所在的队列会被提升到最前。0表示不提升。
+	BatchWindow  int                            `config:"min=0,max=5000,reload"` // 批量调用查询代理时收集查询对象的时间窗口（毫秒）。
+
+	VisibilityTimeout int `config:"min=1"` // 已出队的查询对象超过此时间（秒）仍未确认，会被重新投递。应当大于调用查询代理的超时时间。
+	MaxDeliveries     int `config:"min=1"` // 查询对象的最大投递次数，超过此次数的查询对象被转移到死信队列。
+
+	Persisters int `config:"min=1"` // 将查询代理的结果保存到数据库的持久化协程数。
+
+	ReplyExpiration  int `config:"min=1,reload"` // 完成通知列表的过期时间（秒）。查询接口服务不再等待时，列表自动过期。
+	ResultExpiration int `config:"min=1,reload"` // 查询结果在缓存中的过期时间（秒）。
+
+	MetricsListen string // 查询代理工作进程输出指标和存活检查的绑定地址，为空表示不输出。
+}
+
+// 表示数据库中的跟踪记录的有效期配置。
+// 有效期内的跟踪记录直接返回给客户端，不再调用查询代理。有效期是-1表示永不过期，0表示总是调用查询代理。
+type FreshnessConfiguration struct {
+	Default FreshnessTTLConfiguration    // 每种运单状态的默认有效期。
+	Rules   []FreshnessRuleConfiguration // 有效期规则，按照顺序匹配，第一个匹配的规则生效。没有匹配的规则时使用默认有效期。
+}
+
+// 表示每种运单状态的有效期（秒）。
+type FreshnessTTLConfiguration struct {
+	PreTransit int `config:"min=-1"` // 尚无事件。
+	InTransit  int `config:"min=-1"` // 运输途中。
+	Exception  int `config:"min=-1"` // 投递异常。
+	Delivered  int `config:"min=-1"` // 已妥投。
+}
+
+// 表示一条有效期规则。条件为空表示匹配任意值。
+type FreshnessRuleConfiguration struct {
+	Carrier     string // 运输商编号。
+	CarrierType string // 运输商类别，比如`EMS`、`CN`。
+	State       string `config:"enum=PreTransit|InTransit|Exception|Delivered"` // 运单状态，可以是`PreTransit`、`InTransit`、`Exception`或者`Delivered`。
+	Priority    string `config:"enum=Highest|High|Low"`                         // 优先级，可以是`Highest`、`High`或者`Low`。
+	TTL         int    `config:"min=-1"`                                        // 有效期（秒）。
+}
+
+// 表示异步保存到数据库的配置。
+// 待保存的记录首先进入有界的内存队列，由写入协程批量写入。数据库不可用或者内存队列已满时，记录被溢出到磁盘日志，数据库恢复后重放。
+type PersistenceConfiguration struct {
+	QueueSize       int    `config:"min=1"` // 内存队列的容量。
+	BatchSize       int    `config:"min=1"` // 每批写入的最多记录数。
+	FlushInterval   int    `config:"min=1"` // 不足一批的记录最多等待此时间（毫秒）后写入。
+	Writers         int    `config:"min=1"` // 写入协程数。
+	JournalDir      string // 磁盘日志所在的目录，相对路径相对于进程的工作目录，启动时解析为绝对路径。空字符串表示不溢出到磁盘，无法写入的记录被丢弃。
+	JournalMaxBytes int64  `config:"min=0"` // 磁盘日志的最大字节数，超过后无法写入的记录被丢弃。0表示不限制。
+	ReplayInterval  int    `config:"min=1"` // 重放磁盘日志的间隔（秒）。
+}
+
+// 每个优先级的调度权重。
+// 工作协程每次出队时按照权重在各个优先级之间加权轮询，选中的队列为空时再依次尝试工作协程所属的优先级和其它优先级。
+type WorkerWeightsConfiguration struct {
+	Highest int `config:"min=0"` // 最高优先级的权重。
+	High    int `config:"min=0"` // 高优先级的权重。
+	Low     int `config:"min=0"` // 低优先级的权重。
+}
+
+// 每个优先级的工作协程数。
+// 每个优先级的工作协程优先处理此优先级的查询对象，空闲时也会处理其它优先级的查询对象。
+type WorkerConcurrencyConfiguration struct {
+	Highest int `config:"min=0"` // 最高优先级的工作协程数。
+	High    int `config:"min=0"` // 高优先级的工作协程数。
+	Low     int `config:"min=0"` // 低优先级的工作协程数。
+}
+
+// 返回所有优先级的工作协程总数。
+func (c *WorkerConcurrencyConfiguration) Total() int {
+	return c.Highest + c.High + c.Low
+}
+
+type DBConfiguration struct {
+	DSN        string `config:"required,dsn"` // 连接数据库的字符串。
+	ReplicaDSN string `config:"dsn"`          // 连接只读副本的字符串。读取量大的查询优先使用只读副本，只读副本不可用时使用主库。空字符串表示没有只读副本。
+
+	MaxOpenConns    int `config:"min=0"` // 每个连接池的最大连接数，0表示不限制。
+	MaxIdleConns    int `config:"min=0"` // 每个连接池的最大空闲连接数。
+	ConnMaxLifetime int `config:"min=0"` // 连接最长使用时间（秒），0表示不限制。
+	ConnMaxIdleTime int `config:"min=0"` // 连接最长空闲时间（秒），0表示不限制。
+	DialTimeout     int `config:"min=0"` // 建立连接的超时（秒），连接字符串中指定了`timeout`参数时以连接字符串为准。0表示使用驱动的默认值。
+}
+
+// 表示Redis连接配置。队列、缓存和限制共享同一个Redis客户端。
+type RedisConfiguration struct {
+	Mode string `config:"enum=standalone|sentinel|cluster"` // 部署方式，可以是`standalone`、`sentinel`或者`cluster`。
+
+	Host string // Redis 的地址，只用于`standalone`。
+	Port int    `config:"min=1,max=65535"` // Redis 的端口，只用于`standalone`。
+
+	Addrs            []string // `sentinel`时是哨兵的地址，`cluster`时是集群节点的地址，格式是`host:port`。
+	MasterName       string   // `sentinel`时主节点的名字。
+	SentinelPassword string   `config:"secret"` // 哨兵的口令。
+
+	Username string // Redis 的ACL用户名，空字符串表示使用默认用户。
+	Password string `config:"secret"` // Redis 的口令。
+	DB       int    `config:"min=0"`  // 使用的Redis数据库，`cluster`时只能是0。
+
+	TLS RedisTLSConfiguration // TLS配置。
+
+	PoolSize     int `config:"min=0"` // 连接池的大小，0表示根据阻塞出队的工作协程数自动计算。
+	MinIdleConns int `config:"min=0"` // 最少空闲连接数。
+	DialTimeout  int `config:"min=0"` // 建立连接的超时（毫秒）。
+	ReadTimeout  int `config:"min=0"` // 读取超时（毫秒），阻塞命令会自动加上阻塞的时间。
+	WriteTimeout int `config:"min=0"` // 写入超时（毫秒）。
+	PoolTimeout  int `config:"min=0"` // 连接池中没有可用连接时的等待时间（毫秒），0表示读取超时加1秒。
+
+	KeyPrefix string // 所有的键的前缀，用于在同一个Redis中区分不同的环境，比如`staging:`。
+}
+
+// 表示连接Redis的TLS配置。
+type RedisTLSConfiguration struct {
+	Enabled            bool   // 是否使用TLS。
+	CAFile             string // 校验服务器证书的CA证书文件，空字符串表示使用系统的CA证书。
+	CertFile           string // 客户端证书文件，空字符串表示不使用客户端证书。
+	KeyFile            string // 客户端私钥文件。
+	ServerName         string // 校验服务器证书时使用的主机名，空字符串表示使用连接的主机名。
+	InsecureSkipVerify bool   // 是否跳过服务器证书的校验，只能用于测试。
+}
+
+// 创建具有默认值的配置对象。
+func New() *Configuration {
+	return &Configuration{
+		Server: ServerConfiguration{
+			Listen:         DefaultListenAddress,
+			InternalListen: DefaultInternalListen,
+			Timeout:        DefaultTimeout,
+		},
+		DB: DBConfiguration{
+			MaxOpenConns:    DefaultDBMaxOpenConns,
+			MaxIdleConns:    DefaultDBMaxIdleConns,
+			ConnMaxLifetime: DefaultDBConnMaxLifetime,
+			ConnMaxIdleTime: DefaultDBConnMaxIdleTime,
+			DialTimeout:     DefaultDBDialTimeout,
+		},
+		Redis: RedisConfiguration{
+			Mode:         DefaultRedisMode,
+			Host:         DefaultRedisHost,
+			Port:         DefaultRedisPort,
+			Password:     DefaultRedisPassword,
+			DB:           DefaultRedisDB,
+			DialTimeout:  DefaultRedisDialTimeout,
+			ReadTimeout:  DefaultRedisReadTimeout,
+			WriteTimeout: DefaultRedisWriteTimeout,
+		},
+		Worker: WorkerConfiguration{
+			Concurrency: WorkerConcurrencyConfiguration{
+				Highest: DefaultWorkerHighestConcurrency,
+				High:    DefaultWorkerHighConcurrency,
+				Low:     DefaultWorkerLowConcurrency,
+			},
+			Weights: WorkerWeightsConfiguration{
+				Highest: DefaultWorkerHighestWeight,
+				High:    DefaultWorkerHighWeight,
+				Low:     DefaultWorkerLowWeight,
+			},
+			AgingSeconds: DefaultWorkerAgingSeconds,
+			BatchWindow:  DefaultWorkerBatchWindow,
+
+			VisibilityTimeout: DefaultWorkerVisibilityTimeout,
+			MaxDeliveries:     DefaultWorkerMaxDeliveries,
+
+			Persisters: DefaultWorkerPersisters,
+
+			ReplyExpiration:  DefaultWorkerReplyExpiration,
+			ResultExpiration: DefaultWorkerResultExpiration,
+
+			MetricsListen: DefaultWorkerMetricsListen,
+		},
+		Search: SearchConfiguration{
+			MaxOrders:        DefaultSearchMaxOrders,
+			MaxQueueLength:   DefaultSearchMaxQueueLength,
+			PullTimeout:      DefaultSearchPullTimeout,
+			SearchExpiration: DefaultSearchSearchExpiration,
+		},
+		Freshness: FreshnessConfiguration{
+			Default: FreshnessTTLConfiguration{
+				PreTransit: DefaultFreshnessPreTransit,
+				InTransit:  DefaultFreshnessInTransit,
+				Exception:  DefaultFreshnessException,
+				Delivered:  DefaultFreshnessDelivered,
+			},
+			Rules: []FreshnessRuleConfiguration{
+				// 最高优先级的查询总是调用查询代理。
+				{Priority: "Highest", TTL: 0},
+			},
+		},
+		Persistence: PersistenceConfiguration{
+			QueueSize:       DefaultPersistenceQueueSize,
+			BatchSize:       DefaultPersistenceBatchSize,
+			FlushInterval:   DefaultPersistenceFlushInterval,
+			Writers:         DefaultPersistenceWriters,
+			JournalDir:      DefaultPersistenceJournalDir,
+			JournalMaxBytes: DefaultPersistenceJournalMaxBytes,
+			ReplayInterval:  DefaultPersistenceReplayInterval,
+		},
+		Backend:         DefaultBackend,
+		ShutdownTimeout: DefaultShutdownTimeout,
+	}
+}
+
+// 加载配置。
+// 依次使用默认值、配置文件和环境变量，后者覆盖前者，然后检查配置。
+// configFile 配置文件名，如果是目录那么加载此目录下的默认配置文件。空字符串表示加载默认配置文件。
+// defaultConfigFile 默认的配置文件名。扩展名是`.json`、`.yaml`或者`.yml`，默认配置文件不存在时依次尝试其它扩展名，都不存在时只使用默认值和环境变量。
+// 返回加载并检查后的配置。
+func Load(configFile, defaultConfigFile string) (*Configuration, error) {
+	configFile, err := findConfigFile(configFile, defaultConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	configuration := New()
+	if configFile != "" {
+		if err = loadFromFile(configFile, configuration); err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Printf("No configuration file found, using defaults and environment variables ...\n")
+	}
+
+	if applied, err := applyEnv(configuration); err != nil {
+		return nil, err
+	} else if len(applied) != 0 {
+		fmt.Printf("Overriding configuration from environment variables: %s\n", strings.Join(applied, ", "))
+	}
+
+	configuration.normalize()
+
+	if err := configuration.Validate(); err != nil {
+		return nil, err
+	}
+
+	return configuration, nil
+}
+
+// 查找配置文件。
+// 返回配置文件的绝对路径，没有指定配置文件并且默认配置文件不存在时返回空字符串。
+func findConfigFile(configFile, defaultConfigFile string) (string, error) {
+	dir, explicit := "", configFile != ""
+	if explicit {
+		absFile, err := filepath.Abs(configFile)
+		if err != nil {
+			return "", err
+		}
+
+		configFileStat, err := os.Stat(absFile)
+		if err != nil {
+			return "", err
+		}
+		if !configFileStat.IsDir() {
+			return absFile, nil
+		}
+		dir = absFile
+	}
+
+	ext := filepath.Ext(defaultConfigFile)
+	base := strings.TrimSuffix(defaultConfigFile, ext)
+	candidates := []string{defaultConfigFile}
+	for _, e := range []string{".json", ".yaml", ".yml"} {
+		if e != ext {
+			candidates = append(candidates, base+e)
+		}
+	}
+
+	for _, candidate := range candidates {
+		absFile, err := filepath.Abs(filepath.Join(dir, candidate))
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(absFile); err == nil {
+			return absFile, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if explicit {
+		return "", fmt.Errorf("no configuration file found in %s", dir)
+	}
+
+	return "", nil
+}
+
+// 规范化配置项，空字符串使用默认值。
+func (c *Configuration) normalize() {
+	c.Server.Listen = strings.ToLower(strings.TrimSpace(c.Server.Listen))
+	if c.Server.Listen == "" || c.Server.Listen == ":" {
+		c.Server.Listen = DefaultListenAddress
+	}
+	c.Server.InternalListen = strings.ToLower(strings.TrimSpace(c.Server.InternalListen))
+	c.Worker.MetricsListen = strings.ToLower(strings.TrimSpace(c.Worker.MetricsListen))
+
+	c.DB.DSN = strings.TrimSpace(c.DB.DSN)
+	c.DB.ReplicaDSN = strings.TrimSpace(c.DB.ReplicaDSN)
+
+	c.Backend = strings.ToLower(strings.TrimSpace(c.Backend))
+	if c.Backend == "" {
+		c.Backend = DefaultBackend
+	}
+
+	c.Redis.Mode = strings.ToLower(strings.TrimSpace(c.Redis.Mode))
+	if c.Redis.Mode == "" {
+		c.Redis.Mode = DefaultRedisMode
+	}
+}
+
+// 检查配置。
+// 返回`*ValidationError`，包含所有不符合要求的配置项。
+func (c *Configuration) Validate() error {
+	problems := checkRules(c)
+
+	// 检查服务绑定地址的格式是否正确。
+	if !strings.HasPrefix(c.Server.Listen, ":") {
+		problems = append(problems, fmt.Sprintf("Server.Listen should start with colon(:), do you prefer %v ?", ":"+c.Server.Listen))
+	}
+	if c.Server.InternalListen != "" && !strings.HasPrefix(c.Server.InternalListen, ":") {
+		problems = append(problems, fmt.Sprintf("Server.InternalListen should start with colon(:), do you prefer %v ?", ":"+c.Server.InternalListen))
+	}
+	if c.Server.InternalListen != "" && c.Server.InternalListen == c.Server.Listen {
+		problems = append(problems, fmt.Sprintf("Server.InternalListen should differ from Server.Listen, but both %v", c.Server.Listen))
+	}
+	if c.Worker.MetricsListen != "" && !strings.HasPrefix(c.Worker.MetricsListen, ":") {
+		problems = append(problems, fmt.Sprintf("Worker.MetricsListen should start with colon(:), do you prefer %v ?", ":"+c.Worker.MetricsListen))
+	}
+
+	// 检查Redis配置。
+	if c.Backend == BackendRedis {
+		problems = append(problems, checkRedis(&c.Redis)...)
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func checkRedis(redis *RedisConfiguration) []string {
+	problems := make([]string, 0)
+	switch redis.Mode {
+	case RedisModeSentinel:
+		if len(redis.Addrs) == 0 {
+			problems = append(problems, "Redis.Addrs is required in sentinel mode")
+		}
+		if strings.TrimSpace(redis.MasterName) == "" {
+			problems = append(problems, "Redis.MasterName is required in sentinel mode")
+		}
+	case RedisModeCluster:
+		if len(redis.Addrs) == 0 {
+			problems = append(problems, "Redis.Addrs is required in cluster mode")
+		}
+		if redis.DB != 0 {
+			problems = append(problems, fmt.Sprintf("Redis.DB should be 0 in cluster mode, but %d", redis.DB))
+		}
+	}
+
+	if (redis.TLS.CertFile == "") != (redis.TLS.KeyFile == "") {
+		problems = append(problems, "Redis.TLS.CertFile and Redis.TLS.KeyFile should be specified together")
+	}
+
+	return problems
+}
+
+// 表示旧版本配置文件中的顶层配置项，加载时映射到新的配置项，同时输出警告。
+type legacyConfiguration struct {
+	Listen  *string                   // 已被`Server.Listen`取代。
+	Timeout *int                      // 已被`Server.Timeout`取代。
+	Agent   *legacyAgentConfiguration // 已被`Worker`取代。
+}
+
+type legacyAgentConfiguration struct {
+	PollingBatchSize *int // 已被`Worker.Concurrency`取代，没有对应的配置项，被忽略。
+	BatchWindow      *int // 已被`Worker.BatchWindow`取代。
+}
+
+// 从配置文件中加载配置，覆盖已有的配置项。
+// 根据扩展名决定格式，`.yaml`和`.yml`是YAML，其它是JSON。两种格式使用相同的配置项名，不认识的配置项被认为是错误。
+// 旧版本的顶层配置项`Listen`、`Timeout`和`Agent`仍然可以使用，同时设置了新的配置项时以新的配置项为准。
+func loadFromFile(configFile string, configuration *Configuration) (err error) {
+	var content []byte
+
+	fmt.Printf("Loading configuration from %s ...\n", configFile)
+
+	if content, err = ioutil.ReadFile(configFile); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".yaml", ".yml":
+		if content, err = yamlToJSON(content); err != nil {
+			return fmt.Errorf("%s: %w", configFile, err)
+		}
+	}
+
+	var warnings []string
+	if content, warnings, err = applyLegacy(content, configuration); err != nil {
+		return fmt.Errorf("%s: %w", configFile, err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", configFile, warning)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(configuration); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", configFile, err)
+	}
+
+	return nil
+}
+
+// 将JSON文档中旧版本的顶层配置项映射到新的配置项，并从文档中删除。
+// 之后再加载文档中的其它配置项，所以同时设置了新的配置项时以新的配置项为准。
+// content JSON文档。
+// configuration 被修改的配置。
+// 返回删除了旧版本配置项的JSON文档，以及每个旧版本配置项的警告。文档不是JSON对象时原样返回，由调用方报告错误。
+func applyLegacy(content []byte, configuration *Configuration) ([]byte, []string, error) {
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return content, nil, nil
+	}
+
+	// 和加载其它配置项一样，配置项名不区分大小写。
+	legacyDoc := make(map[string]json.RawMessage)
+	for k, v := range doc {
+		for _, name := range []string{"Listen", "Timeout", "Agent"} {
+			if strings.EqualFold(k, name) {
+				legacyDoc[k] = v
+				delete(doc, k)
+			}
+		}
+	}
+	if len(legacyDoc) == 0 {
+		return content, nil, nil
+	}
+
+	legacyContent, err := json.Marshal(legacyDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	legacy := legacyConfiguration{}
+	dec := json.NewDecoder(bytes.NewReader(legacyContent))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&legacy); err != nil {
+		return nil, nil, err
+	}
+
+	warnings := make([]string, 0)
+	if legacy.Listen != nil {
+		configuration.Server.Listen = *legacy.Listen
+		warnings = append(warnings, "Listen is deprecated, use Server.Listen instead")
+	}
+	if legacy.Timeout != nil {
+		configuration.Server.Timeout = *legacy.Timeout
+		warnings = append(warnings, "Timeout is deprecated, use Server.Timeout instead")
+	}
+	if legacy.Agent != nil {
+		if legacy.Agent.PollingBatchSize != nil {
+			warnings = append(warnings, "Agent.PollingBatchSize is deprecated and ignored, use Worker.Concurrency instead")
+		}
+		if legacy.Agent.BatchWindow != nil {
+			configuration.Worker.BatchWindow = *legacy.Agent.BatchWindow
+			warnings = append(warnings, "Agent.BatchWindow is deprecated, use Worker.BatchWindow instead")
+		}
+	}
+
+	if content, err = json.Marshal(doc); err != nil {
+		return nil, nil, err
+	}
+
+	return content, warnings, nil
+}
+
+// 将YAML文档转换为JSON文档。
+func yamlToJSON(content []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(convertYAML(doc))
+}
+
+// YAML的映射的键可以是任意类型，转换为字符串之后才能输出为JSON。
+func convertYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			result[fmt.Sprint(k)] = convertYAML(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(t))
+		for i, e := range t {
+			result[i] = convertYAML(e)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,151 @@
+// @Author: agent
+// @Created: 2026-10-18
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validConfiguration() *Configuration {
+	c := New()
+	c.DB.DSN = "user:password@tcp(localhost:3306)/aitrack"
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfiguration().Validate(); err != nil {
+		t.Fatalf("Validate() of default configuration = %v, want nil", err)
+	}
+
+	c := validConfiguration()
+	c.DB.DSN = ""
+	c.Server.Listen = "8001"
+	c.Worker.Concurrency.Highest = -1
+	c.Worker.BatchWindow = 6000
+	c.Backend = "etcd"
+	c.Redis.Mode = RedisModeCluster
+	c.Redis.DB = 1
+	c.Redis.TLS.CertFile = "client.crt"
+	c.Freshness.Rules = append(c.Freshness.Rules, FreshnessRuleConfiguration{State: "Lost", TTL: 0})
+
+	err := c.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() = %v, want *ValidationError", err)
+	}
+
+	want := []string{
+		"DB.DSN is required",
+		"Server.Listen should start with colon(:)",
+		"Worker.Concurrency.Highest should be at least 0, but -1",
+		"Worker.BatchWindow should be at most 5000, but 6000",
+		"Backend should be one of redis, memory",
+		"Freshness.Rules[1].State should be one of PreTransit, InTransit, Exception, Delivered",
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("Validate() = %v, want problem %q", err, w)
+		}
+	}
+
+	// 后端不是Redis时不检查Redis配置。
+	for _, p := range verr.Problems {
+		if strings.HasPrefix(p, "Redis.") {
+			t.Errorf("Validate() reported %q, want no redis problems when backend is not redis", p)
+		}
+	}
+
+	c = validConfiguration()
+	c.Server.InternalListen = c.Server.Listen
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "Server.InternalListen should differ from Server.Listen") {
+		t.Errorf("Validate() = %v, want internal listen problem", err)
+	}
+
+	c = validConfiguration()
+	c.Server.InternalListen = ""
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() without internal listen = %v, want nil", err)
+	}
+
+	c = validConfiguration()
+	c.Redis.Mode = RedisModeCluster
+	c.Redis.DB = 1
+	c.Redis.TLS.CertFile = "client.crt"
+	err = c.Validate()
+	for _, w := range []string{"Redis.Addrs is required in cluster mode", "Redis.DB should be 0 in cluster mode, but 1", "Redis.TLS.CertFile and Redis.TLS.KeyFile should be specified together"} {
+		if err == nil || !strings.Contains(err.Error(), w) {
+			t.Errorf("Validate() = %v, want problem %q", err, w)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadFromFileLegacyKeys(t *testing.T) {
+	file := writeConfigFile(t, "legacy.json", `{"Listen": ":9001", "Timeout": 10, "Agent": {"PollingBatchSize": 20, "BatchWindow": 500}, "DB": {"DSN": "u:p@tcp(db:3306)/aitrack"}}`)
+
+	c := New()
+	if err := loadFromFile(file, c); err != nil {
+		t.Fatalf("loadFromFile() = %v", err)
+	}
+	if c.Server.Listen != ":9001" || c.Server.Timeout != 10 || c.Worker.BatchWindow != 500 {
+		t.Errorf("legacy keys mapped to Server=%+v, Worker.BatchWindow=%d", c.Server, c.Worker.BatchWindow)
+	}
+	if c.DB.DSN != "u:p@tcp(db:3306)/aitrack" {
+		t.Errorf("DB.DSN = %q, want the value from file", c.DB.DSN)
+	}
+}
+
+func TestLoadFromFileLegacyKeysYAML(t *testing.T) {
+	file := writeConfigFile(t, "legacy.yaml", "listen: \":9001\"\nServer:\n  Timeout: 20\nTimeout: 10\n")
+
+	c := New()
+	if err := loadFromFile(file, c); err != nil {
+		t.Fatalf("loadFromFile() = %v", err)
+	}
+	// 同时设置了新的配置项时以新的配置项为准。
+	if c.Server.Listen != ":9001" || c.Server.Timeout != 20 {
+		t.Errorf("Server = %+v, want Listen from legacy key and Timeout from Server", c.Server)
+	}
+}
+
+func TestApplyLegacyWarnings(t *testing.T) {
+	content, warnings, err := applyLegacy([]byte(`{"Timeout": 10, "Agent": {"PollingBatchSize": 20}, "Search": {"MaxOrders": 5}}`), New())
+	if err != nil {
+		t.Fatalf("applyLegacy() = %v", err)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "Timeout is deprecated") || !strings.Contains(warnings[1], "Agent.PollingBatchSize is deprecated and ignored") {
+		t.Errorf("warnings = %q", warnings)
+	}
+	if string(content) != `{"Search":{"MaxOrders":5}}` {
+		t.Errorf("content = %s, want legacy keys removed", content)
+	}
+
+	if _, warnings, err := applyLegacy([]byte(`{"Search": {"MaxOrders": 5}}`), New()); err != nil || len(warnings) != 0 {
+		t.Errorf("applyLegacy() without legacy keys = %q, %v", warnings, err)
+	}
+}
+
+func TestLoadFromFileUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.json":        `{"Listenn": ":9001"}`,
+		"unknown-nested.json": `{"Server": {"Listenn": ":9001"}}`,
+		"unknown-agent.json":  `{"Agent": {"Concurrency": 10}}`,
+	} {
+		if err := loadFromFile(writeConfigFile(t, name, content), New()); err == nil {
+			t.Errorf("loadFromFile(%s) = nil, want error", content)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+// 该模块定义了配置的模式，以及基于模式的环境变量覆盖、检查、脱敏和重新加载。
+// 配置项的约束写在字段的`config`标签中，多个约束以逗号分隔：
+// `required`：不能为空。
+// `min=N`、`max=N`：整数的取值范围。
+// `enum=a|b|c`：字符串的可选值，不区分大小写。空字符串表示使用默认值，总是允许的。
+// `secret`：口令等敏感信息，输出配置时被隐藏。
+// `dsn`：数据库连接字符串，输出配置时隐藏其中的口令。
+// `reload`：收到`SIGHUP`后重新加载时立刻生效，不需要重启。标记在结构体类型的字段上时对其所有字段生效。
+// @Author: agent
+// @Created: 2026-10-18
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	EnvPrefix string = "TRACKING_" // 覆盖配置项的环境变量名的前缀，比如`TRACKING_DB_MAX_OPEN_CONNS`覆盖`DB.MaxOpenConns`。
+
+	redactedValue string = "******" // 输出配置时替代敏感信息的字符串。
+)
+
+// 表示配置项的约束。
+type fieldRules struct {
+	required bool
+	secret   bool
+	dsn      bool
+	reload   bool
+	min      *int64
+	max      *int64
+	enum     []string
+}
+
+// 表示一个配置项。
+type field struct {
+	fieldRules
+	path  string        // 配置项的路径，比如`Worker.Concurrency.Highest`。
+	env   string        // 覆盖此配置项的环境变量名。数组中的元素没有对应的环境变量。
+	value reflect.Value // 配置项的值，可以修改。
+}
+
+// 表示配置检查失败，包含所有不符合约束的配置项。
+type ValidationError struct {
+	Problems []string // 每个不符合约束的配置项的说明。
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// 依次访问配置中的所有配置项。结构体类型的字段被展开为它的字段。
+// c 配置。
+// elements 是否同时访问结构体数组中每个元素的字段，用于检查数组中的元素。
+// fn 访问配置项的方法，返回错误时停止访问。
+func walk(c *Configuration, elements bool, fn func(f *field) error) error {
+	return walkStruct(reflect.ValueOf(c).Elem(), "", EnvPrefix, fieldRules{}, elements, fn)
+}
+
+func walkStruct(v reflect.Value, path string, env string, parent fieldRules, elements bool, fn func(f *field) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		rules := parseRules(t.Name()+"."+sf.Name, sf.Tag.Get("config"))
+		rules.reload = rules.reload || parent.reload
+
+		f := field{fieldRules: rules, path: sf.Name, value: v.Field(i)}
+		if path != "" {
+			f.path = path + "." + sf.Name
+		}
+		if env != "" {
+			f.env = env + envName(sf.Name)
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			if err := walkStruct(f.value, f.path, f.env+"_", rules, elements, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(&f); err != nil {
+			return err
+		}
+
+		if elements && sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct {
+			for j := 0; j < f.value.Len(); j++ {
+				if err := walkStruct(f.value.Index(j), fmt.Sprintf("%s[%d]", f.path, j), "", rules, elements, fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// 解析配置项的约束。
+// 约束是在代码中定义的，格式错误时直接panic。
+func parseRules(name string, tag string) fieldRules {
+	result := fieldRules{}
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		kv := strings.SplitN(rule, "=", 2)
+		switch kv[0] {
+		case "required":
+			result.required = true
+		case "secret":
+			result.secret = true
+		case "dsn":
+			result.dsn = true
+		case "reload":
+			result.reload = true
+		case "min", "max":
+			if len(kv) != 2 {
+				panic(fmt.Errorf("illegal config tag of %s: %s", name, rule))
+			}
+			n, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				panic(fmt.Errorf("illegal config tag of %s: %s", name, rule))
+			}
+			if kv[0] == "min" {
+				result.min = &n
+			} else {
+				result.max = &n
+			}
+		case "enum":
+			if len(kv) != 2 {
+				panic(fmt.Errorf("illegal config tag of %s: %s", name, rule))
+			}
+			result.enum = strings.Split(kv[1], "|")
+		default:
+			panic(fmt.Errorf("illegal config tag of %s: %s", name, rule))
+		}
+	}
+
+	return result
+}
+
+// 将字段名转换为环境变量名中的部分，比如`MaxOpenConns`转换为`MAX_OPEN_CONNS`，`CAFile`转换为`CA_FILE`。
+func envName(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+
+	return sb.String()
+}
+
+// 使用环境变量覆盖配置项。
+// c 配置。
+// 返回被使用的环境变量名。
+// 字符串数组使用逗号分隔，结构体数组和映射使用JSON，其它类型使用字面值。
+func applyEnv(c *Configuration) ([]string, error) {
+	applied := make([]string, 0)
+	err := walk(c, false, func(f *field) error {
+		s, ok := os.LookupEnv(f.env)
+		if !ok {
+			return nil
+		}
+
+		if err := setFromString(f.value, s); err != nil {
+			return fmt.Errorf("cannot parse environment variable %s for %s: %w", f.env, f.path, err)
+		}
+		applied = append(applied, f.env)
+		return nil
+	})
+
+	return applied, err
+}
+
+func setFromString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			items := make([]string, 0)
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+		fallthrough
+	default:
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+			return err
+		}
+		v.Set(p.Elem())
+	}
+
+	return nil
+}
+
+// 按照约束检查配置项。
+// 返回所有不符合约束的配置项的说明。
+func checkRules(c *Configuration) []string {
+	problems := make([]string, 0)
+	walk(c, true, func(f *field) error {
+		if problem := f.check(); problem != "" {
+			problems = append(problems, problem)
+		}
+		return nil
+	})
+
+	return problems
+}
+
+func (f *field) check() string {
+	switch f.value.Kind() {
+	case reflect.String:
+		s := strings.TrimSpace(f.value.String())
+		if s == "" {
+			if f.required {
+				return fmt.Sprintf("%s is required", f.path)
+			}
+			return ""
+		}
+		if f.dsn && (!strings.Contains(s, "@") || !strings.Contains(s, ":")) {
+			return fmt.Sprintf("%s should contain at(@) and colon(:)", f.path)
+		}
+		if len(f.enum) != 0 {
+			for _, e := range f.enum {
+				if strings.EqualFold(s, e) {
+					return ""
+				}
+			}
+			return fmt.Sprintf("%s should be one of %s, but %q", f.path, strings.Join(f.enum, ", "), s)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := f.value.Int()
+		if f.min != nil && n < *f.min {
+			return fmt.Sprintf("%s should be at least %d, but %d", f.path, *f.min, n)
+		}
+		if f.max != nil && n > *f.max {
+			return fmt.Sprintf("%s should be at most %d, but %d", f.path, *f.max, n)
+		}
+	case reflect.Slice, reflect.Map:
+		if f.required && f.value.Len() == 0 {
+			return fmt.Sprintf("%s is required", f.path)
+		}
+	}
+
+	return ""
+}
+
+// 返回配置的深拷贝。
+func (c *Configuration) clone() *Configuration {
+	content, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+
+	result := &Configuration{}
+	if err := json.Unmarshal(content, result); err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// 返回隐藏了口令等敏感信息的配置副本，用于输出配置。
+func (c *Configuration) Redacted() *Configuration {
+	result := c.clone()
+	walk(result, false, func(f *field) error {
+		if f.value.Kind() != reflect.String || f.value.String() == "" {
+			return nil
+		}
+
+		if f.secret {
+			f.value.SetString(redactedValue)
+		} else if f.dsn {
+			f.value.SetString(redactDSN(f.value.String()))
+		}
+		return nil
+	})
+
+	return result
+}
+
+// 隐藏数据库连接字符串中的口令。
+// 连接字符串的格式是`user:password@protocol(address)/dbname?param=value`，口令中可能包含`@`和`:`。
+func redactDSN(dsn string) string {
+	end := strings.LastIndex(dsn, "/")
+	if end < 0 {
+		end = len(dsn)
+	}
+	at := strings.LastIndex(dsn[:end], "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+
+	return dsn[:colon+1] + redactedValue + dsn[at:]
+}
+
+// 将配置输出为隐藏了敏感信息的JSON文档，格式和配置文件相同。
+func (c *Configuration) Dump() ([]byte, error) {
+	return json.MarshalIndent(c.Redacted(), "", "  ")
+}
+
+// 将重新加载的配置中可以在运行时修改的配置项合并到当前配置中。
+// current 当前生效的配置。
+// next 重新加载的配置。
+// sections 应用程序在运行时使之生效的顶层配置项，比如`Search`。其它顶层配置项中可以在运行时修改的配置项发生变化时被忽略，因为应用程序不使用它们。
+// 返回合并后的配置、已经合并的发生了变化的配置项，以及发生了变化但是需要重启才能生效的配置项。当前配置不会被修改。
+func Merge(current, next *Configuration, sections []string) (*Configuration, []string, []string) {
+	nextFields := make(map[string]reflect.Value)
+	walk(next, false, func(f *field) error {
+		nextFields[f.path] = f.value
+		return nil
+	})
+
+	result := current.clone()
+	reloaded, restart := make([]string, 0), make([]string, 0)
+	walk(result, false, func(f *field) error {
+		v := nextFields[f.path]
+		if equalValues(f.value, v) {
+			return nil
+		}
+
+		if f.reload {
+			if !inSections(f.path, sections) {
+				return nil
+			}
+			f.value.Set(v)
+			reloaded = append(reloaded, f.path)
+		} else {
+			restart = append(restart, f.path)
+		}
+		return nil
+	})
+
+	return result, reloaded, restart
+}
+
+// 判断配置项是否属于某个顶层配置项。
+// path 配置项的路径。
+// sections 顶层配置项。
+func inSections(path string, sections []string) bool {
+	for _, section := range sections {
+		if path == section || strings.HasPrefix(path, section+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 比较两个配置项的值是否相同，空数组和nil被认为是相同的。
+func equalValues(a, b reflect.Value) bool {
+	if (a.Kind() == reflect.Slice || a.Kind() == reflect.Map) && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
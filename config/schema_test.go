@@ -0,0 +1,133 @@
+// @Author: agent
+// @Created: 2026-10-18
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("TRACKING_DB_DSN", "u:p@tcp(db:3306)/aitrack")
+	t.Setenv("TRACKING_WORKER_CONCURRENCY_HIGHEST", "8")
+	t.Setenv("TRACKING_REDIS_TLS_ENABLED", "true")
+	t.Setenv("TRACKING_REDIS_TLS_CA_FILE", "ca.pem")
+	t.Setenv("TRACKING_REDIS_ADDRS", "a:26379, b:26379,")
+	t.Setenv("TRACKING_FRESHNESS_RULES", `[{"Carrier": "DHL", "TTL": 60}]`)
+
+	c := New()
+	applied, err := applyEnv(c)
+	if err != nil {
+		t.Fatalf("applyEnv() = %v", err)
+	}
+	if len(applied) != 6 {
+		t.Errorf("applied = %q, want 6 variables", applied)
+	}
+
+	if c.DB.DSN != "u:p@tcp(db:3306)/aitrack" || c.Worker.Concurrency.Highest != 8 || !c.Redis.TLS.Enabled || c.Redis.TLS.CAFile != "ca.pem" {
+		t.Errorf("configuration = DB=%+v, Worker.Concurrency=%+v, Redis.TLS=%+v", c.DB, c.Worker.Concurrency, c.Redis.TLS)
+	}
+	if !reflect.DeepEqual(c.Redis.Addrs, []string{"a:26379", "b:26379"}) {
+		t.Errorf("Redis.Addrs = %q", c.Redis.Addrs)
+	}
+	if !reflect.DeepEqual(c.Freshness.Rules, []FreshnessRuleConfiguration{{Carrier: "DHL", TTL: 60}}) {
+		t.Errorf("Freshness.Rules = %+v", c.Freshness.Rules)
+	}
+}
+
+func TestApplyEnvIllegalValue(t *testing.T) {
+	t.Setenv("TRACKING_SERVER_TIMEOUT", "ten")
+
+	if _, err := applyEnv(New()); err == nil {
+		t.Errorf("applyEnv() = nil, want error")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"DSN":          "DSN",
+		"MaxOpenConns": "MAX_OPEN_CONNS",
+		"CAFile":       "CA_FILE",
+		"TLS":          "TLS",
+		"Highest":      "HIGHEST",
+	} {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"user:password@tcp(localhost:3306)/aitrack":       "user:******@tcp(localhost:3306)/aitrack",
+		"user:p@ss:w@rd@tcp(localhost:3306)/aitrack?a=@b": "user:******@tcp(localhost:3306)/aitrack?a=@b",
+		"user@tcp(localhost:3306)/aitrack":                "user@tcp(localhost:3306)/aitrack",
+		"user:password@/aitrack":                          "user:******@/aitrack",
+		"aitrack":                                         "aitrack",
+	} {
+		if got := redactDSN(dsn); got != want {
+			t.Errorf("redactDSN(%s) = %s, want %s", dsn, got, want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := New()
+	c.DB.DSN = "user:password@tcp(localhost:3306)/aitrack"
+	c.Redis.Password = "secret"
+
+	r := c.Redacted()
+	if r.DB.DSN != "user:******@tcp(localhost:3306)/aitrack" || r.Redis.Password != redactedValue || r.Redis.SentinelPassword != "" {
+		t.Errorf("Redacted() = DB.DSN %q, Redis.Password %q, Redis.SentinelPassword %q", r.DB.DSN, r.Redis.Password, r.Redis.SentinelPassword)
+	}
+	if c.Redis.Password != "secret" {
+		t.Errorf("Redacted() modified the configuration")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	current := New()
+	next := New()
+	next.Search.MaxOrders = 10
+	next.Freshness.Rules = nil
+	next.Worker.BatchWindow = 100
+	next.Worker.Concurrency.Low = 10
+	next.Server.Listen = ":9001"
+	next.ShutdownTimeout = 30
+
+	merged, reloaded, restart := Merge(current, next, []string{"ShutdownTimeout", "Search", "Freshness"})
+
+	if !reflect.DeepEqual(reloaded, []string{"Search.MaxOrders", "Freshness.Rules", "ShutdownTimeout"}) {
+		t.Errorf("reloaded = %q", reloaded)
+	}
+	if !reflect.DeepEqual(restart, []string{"Server.Listen", "Worker.Concurrency.Low"}) {
+		t.Errorf("restart = %q", restart)
+	}
+
+	if merged.Search.MaxOrders != 10 || len(merged.Freshness.Rules) != 0 || merged.ShutdownTimeout != 30 {
+		t.Errorf("merged = Search %+v, Freshness.Rules %+v, ShutdownTimeout %d", merged.Search, merged.Freshness.Rules, merged.ShutdownTimeout)
+	}
+	// 不在配置节中的可以重新加载的配置项和需要重启的配置项都不合并。
+	if merged.Worker.BatchWindow != DefaultWorkerBatchWindow || merged.Worker.Concurrency.Low != DefaultWorkerLowConcurrency || merged.Server.Listen != DefaultListenAddress {
+		t.Errorf("merged = Worker %+v, Server %+v", merged.Worker, merged.Server)
+	}
+	if current.Search.MaxOrders != DefaultSearchMaxOrders {
+		t.Errorf("Merge() modified the current configuration")
+	}
+
+	_, reloaded, _ = Merge(current, next, []string{"Worker"})
+	if !reflect.DeepEqual(reloaded, []string{"Worker.BatchWindow"}) {
+		t.Errorf("reloaded = %q, want only Worker fields", reloaded)
+	}
+}
+
+func TestMergeEmptySlices(t *testing.T) {
+	current := New()
+	current.Redis.Addrs = nil
+	next := New()
+	next.Redis.Addrs = []string{}
+
+	if _, reloaded, restart := Merge(current, next, []string{"Redis"}); len(reloaded) != 0 || len(restart) != 0 {
+		t.Errorf("Merge() = %q, %q, want nothing changed", reloaded, restart)
+	}
+}
@@ -0,0 +1,61 @@
+// 该模块定义了查询所有生效的查询代理的地址的方法，用于诊断。
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AgentKindCrawler string = "crawler" // 表示爬虫类型的查询代理，地址来自`tracking_crawler_info`表。
+	AgentKindApi     string = "api"     // 表示API类型的查询代理，地址来自`tracking_api`表。
+)
+
+// 表示一个查询代理的地址。
+type AgentUrlPo struct {
+	Kind string // 查询代理的类型，可以是`crawler`或者`api`。
+	Name string // 查询代理名称。
+	Url  string // 访问查询代理的URL。
+}
+
+const (
+	selectAgentUrls string = `select distinct 'crawler', tci.name, tci.req_url
+	from tracking_crawler_info tci
+	where tci.status = 1
+	and tci.service_status = 1
+	and tci.req_url <> ''
+	and tci.start_time <= ?
+	and tci.end_time >= ?
+	union
+	select distinct 'api', ta.name, ta.api_url
+	from tracking_api ta
+	where ta.status = 1
+	and ta.service_status = 1
+	and ta.api_url <> ''
+	and ta.start_time <= ?
+	and ta.end_time >= ?`
+)
+
+// 查询所有在指定时间生效的查询代理的地址。
+// ctx 上下文。
+// datePoint 时间点。
+func QueryAgentUrls(ctx context.Context, datePoint time.Time) ([]*AgentUrlPo, error) {
+	rows, err := readQuery(ctx, selectAgentUrls, datePoint, datePoint, datePoint, datePoint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*AgentUrlPo, 0)
+	for rows.Next() {
+		agentUrlPo := AgentUrlPo{}
+		if err := rows.Scan(&agentUrlPo.Kind, &agentUrlPo.Name, &agentUrlPo.Url); err != nil {
+			return nil, err
+		}
+		result = append(result, &agentUrlPo)
+	}
+
+	return result, rows.Err()
+}
@@ -4,6 +4,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -16,6 +17,9 @@ type ApiInfoPo struct {
 	ReqHttpType int // 1-GET 2-POST。
 
 	Id int64 // API设置ID。
+
+	MaxConcurrency int // 最大并发数，0表示不限制。
+	MaxRps         int // 每秒最大请求数，0表示不限制。
 }
 
 type ApiParamPo struct {
@@ -29,7 +33,7 @@ type ApiParamPo struct {
 }
 
 const (
-	selectApiInfoByCarrierCode = `select ta.id, ta.name, ta.api_url, ta.request_type
+	selectApiInfoByCarrierCode = `select ta.id, ta.name, ta.api_url, ta.request_type, coalesce(ta.max_concurrency, 0), coalesce(ta.max_rps, 0)
 from tracking_api ta
 join carrier_info ci on ci.id = ta.carrier_id
 where ci.carrier_code = ?
@@ -49,9 +53,11 @@ where ci.carrier_code = ?
 	`
 )
 
-func QueryApiInfoByCarrierCode(carrierCode string, datePoint time.Time) *ApiInfoPo {
+// 依赖的表结构见迁移脚本`migrations/0004_crawl_limits.sql`。
+
+func QueryApiInfoByCarrierCode(ctx context.Context, carrierCode string, datePoint time.Time) *ApiInfoPo {
 	result := ApiInfoPo{}
-	if err := db.QueryRow(selectApiInfoByCarrierCode, carrierCode, datePoint, datePoint).Scan(&result.Id, &result.Name, &result.Url, &result.ReqHttpType); err != nil {
+	if err := readQueryRow(ctx, selectApiInfoByCarrierCode, carrierCode, datePoint, datePoint).Scan(&result.Id, &result.Name, &result.Url, &result.ReqHttpType, &result.MaxConcurrency, &result.MaxRps); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		} else {
@@ -62,9 +68,9 @@ func QueryApiInfoByCarrierCode(carrierCode string, datePoint time.Time) *ApiInfo
 	}
 }
 
-func QueryApiParamsByApiId(apiId int64) []*ApiParamPo {
+func QueryApiParamsByApiId(ctx context.Context, apiId int64) []*ApiParamPo {
 	result := make([]*ApiParamPo, 0)
-	if rows, err := db.Query(selectApiParamsByApiId, apiId); err != nil {
+	if rows, err := readQuery(ctx, selectApiParamsByApiId, apiId); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return result
 		} else {
@@ -0,0 +1,54 @@
+// 该模块定义了`tracking_api_mapping`对象的数据库访问方法。
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// API响应的映射规则。
+// 直接调用API时，按照此规则将API返回的JSON或者XML转换为查询代理的事件列表。
+type ApiMappingPo struct {
+	ApiId            int64  // API设置ID。
+	Format           string // 响应格式，JSON或者XML。
+	EventsPath       string // 事件列表的选择器。
+	DatePath         string // 事件日期的选择器，相对于事件节点。
+	PlacePath        string // 事件地点的选择器，相对于事件节点。
+	DetailsPath      string // 事件明细的选择器，相对于事件节点。
+	StatusPath       string // 响应状态码的选择器，相对于根节点。
+	SuccessStatus    string // 表示成功的状态码，多个状态码用逗号分隔。
+	NoTrackingStatus string // 表示单号未查询到的状态码，多个状态码用逗号分隔。
+	DateFormat       string // 事件日期的格式。
+	TimeZone         string // 事件日期的时区。
+}
+
+const (
+	selectApiMappingByApiId = `select tam.api_id, tam.format, tam.events_path, tam.date_path, coalesce(tam.place_path, ''), tam.details_path,
+	coalesce(tam.status_path, ''), coalesce(tam.success_status, ''), coalesce(tam.no_tracking_status, ''), coalesce(tam.date_format, ''), coalesce(tam.time_zone, '')
+	from tracking_api_mapping tam
+	where tam.api_id = ?
+	and tam.status = 1
+	limit 1
+	`
+)
+
+// 依赖的表结构见迁移脚本`migrations/0005_tracking_api_mapping.sql`。
+
+// 根据API设置ID查询响应映射规则。
+// 如果不存在符合条件的记录则返回nil，此时应当继续通过Python查询代理调用API。
+func QueryApiMappingByApiId(ctx context.Context, apiId int64) *ApiMappingPo {
+	result := ApiMappingPo{}
+	if err := readQueryRow(ctx, selectApiMappingByApiId, apiId).Scan(&result.ApiId, &result.Format, &result.EventsPath, &result.DatePath, &result.PlacePath, &result.DetailsPath,
+		&result.StatusPath, &result.SuccessStatus, &result.NoTrackingStatus, &result.DateFormat, &result.TimeZone); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else {
+			panic(err)
+		}
+	} else {
+		return &result
+	}
+}
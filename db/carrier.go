@@ -4,8 +4,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	_types "com.cne/ai-tracking-search/types"
 )
@@ -34,8 +36,9 @@ type TrackingNoRulePo struct {
 }
 
 const (
-	selectCarrierInfoByCarrierCode string = `select id, country_id from carrier_info where carrier_code = ? and status = 1`
-	selectAllCarrierInfo           string = `select distinct ci.id, ci.carrier_code, ci.name_cn, ci.name_en, ci.carrier_type, ci.country_id, ci.website_url, ci.tel, ci.email, ci.description, ci.service_status,
+	selectCarrierInfoByCarrierCodes string = `select id, carrier_code, carrier_type, country_id from carrier_info where carrier_code in (%s) and status = 1`
+	countCarrierInfo                string = `select count(*) from carrier_info where status = 1 and carrier_code is not null`
+	selectAllCarrierInfo            string = `select distinct ci.id, ci.carrier_code, ci.name_cn, ci.name_en, ci.carrier_type, ci.country_id, ci.website_url, ci.tel, ci.email, ci.description, ci.service_status,
 	sba.real_path, sba.file_name,
 	tnr.id, tnr.name, tnrd.code
 	from carrier_info ci
@@ -46,24 +49,52 @@ const (
 	order by ci.id, tnr.id`
 )
 
-func QueryCarrierByCode(carrierCode string) *CarrierPo {
-	// TODO: 使用缓存。
-	result := CarrierPo{}
-	if err := db.QueryRow(selectCarrierInfoByCarrierCode, carrierCode).Scan(&result.Id, &result.CountryId); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil
-		} else {
+// 根据多个运输商号码查询运输商，所有的运输商只需要一次查询。
+// ctx 上下文。
+// carrierCodes 运输商号码，可以重复。
+// 返回找到的运输商，不存在的运输商号码不会出现在结果中。
+func QueryCarriersByCodes(ctx context.Context, carrierCodes []string) map[string]*CarrierPo {
+	result := make(map[string]*CarrierPo, len(carrierCodes))
+	if len(carrierCodes) == 0 {
+		return result
+	}
+
+	args := distinctArgs(carrierCodes)
+	if rows, err := readQuery(ctx, fmt.Sprintf(selectCarrierInfoByCarrierCodes, placeholders(len(args))), args...); err != nil {
+		panic(err)
+	} else {
+		defer rows.Close()
+
+		for rows.Next() {
+			carrierPo := CarrierPo{}
+			if err := rows.Scan(&carrierPo.Id, &carrierPo.Code, &carrierPo.CarrierType, &carrierPo.CountryId); err != nil {
+				panic(err)
+			}
+			result[carrierPo.Code] = &carrierPo
+		}
+		if err := rows.Err(); err != nil {
 			panic(err)
 		}
-	} else {
-		return &result
+
+		return result
 	}
 }
 
-func QueryAllCarrier() []*CarrierPo {
+// 查询可用的运输商的数量，用于检查运输商表中是否有可用的运输商。
+// ctx 上下文。
+func CountCarriers(ctx context.Context) (int, error) {
+	var result int
+	if err := readQueryRow(ctx, countCarrierInfo).Scan(&result); err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func QueryAllCarrier(ctx context.Context) []*CarrierPo {
 	// TODO: 使用缓存。
 	result := make([]*CarrierPo, 0)
-	if rows, err := db.Query(selectAllCarrierInfo); err != nil {
+	if rows, err := readQuery(ctx, selectAllCarrierInfo); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return result
 		} else {
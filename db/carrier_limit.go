@@ -0,0 +1,45 @@
+// 该模块定义了`tracking_carrier_limit`对象的数据库访问方法。
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// 调用查询代理爬取某个运输商时的限制，对这个运输商的所有查询代理（API和爬虫）有效。
+type CarrierLimitPo struct {
+	CarrierCode    string // 运输商编号。
+	MaxConcurrency int    // 最大并发数，0表示不限制。
+	MaxRps         int    // 每秒最大请求数，0表示不限制。
+}
+
+const (
+	selectCarrierLimitByCarrierCode = `select tcl.max_concurrency, tcl.max_rps
+	from tracking_carrier_limit tcl
+	join carrier_info ci on ci.id = tcl.carrier_id
+	where ci.carrier_code = ?
+	and ci.status = 1
+	and tcl.status = 1
+	limit 1
+	`
+)
+
+// 依赖的表结构见迁移脚本`migrations/0004_crawl_limits.sql`。
+
+// 根据运输商编号查询爬取限制。
+// 如果不存在符合条件的记录，那么返回不限制。
+func QueryCarrierLimitByCarrierCode(ctx context.Context, carrierCode string) *CarrierLimitPo {
+	result := CarrierLimitPo{CarrierCode: carrierCode}
+	if err := readQueryRow(ctx, selectCarrierLimitByCarrierCode, carrierCode).Scan(&result.MaxConcurrency, &result.MaxRps); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &result
+		} else {
+			panic(err)
+		}
+	} else {
+		return &result
+	}
+}
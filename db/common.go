@@ -1,36 +1,199 @@
 // 该模块定义了初始化数据库的方法和公共数据库对象。
+// 写入总是使用主库。读取量大的查询（运输商、匹配规则、查询代理配置和跟踪结果）优先使用只读副本，只读副本不可用时自动改为使用主库。
 // @Author: Haart
 // @Created: 2021-10-27
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	_config "com.cne/ai-tracking-search/config"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	replicaRetryInterval time.Duration = 30 * time.Second // 只读副本出错后，在此时间内不再使用只读副本。
+
+	mysqlErrConCount       uint16 = 1040 // ER_CON_COUNT_ERROR
+	mysqlErrServerShutdown uint16 = 1053 // ER_SERVER_SHUTDOWN
 )
 
 var (
-	db *sql.DB
+	db      *sql.DB // 主库。
+	replica *sql.DB // 只读副本，没有配置只读副本时是nil。
+
+	replicaDownUntil int64 // 只读副本出错后，恢复使用只读副本的时间（毫秒）。
 )
 
 // 初始化数据配置。
-// dsn_ 数据库连接字符串。
-// 尝试根据指定的连接字符串创建数据库连接并且Ping，如果成功则返回nil，否则返回连接时发生的错误。
-func InitDB(dsn_ string) error {
-	if db_, err := sql.Open("mysql", dsn_); err != nil {
+// dbConfig 数据库配置。
+// 尝试根据配置的连接字符串创建主库和只读副本的连接并且Ping，如果成功则返回nil，否则返回连接时发生的错误。
+// 只读副本不可用时不返回错误，读取暂时改为使用主库，之后自动重试只读副本。
+func InitDB(dbConfig *_config.DBConfiguration) error {
+	if db_, err := open(dbConfig.DSN, dbConfig); err != nil {
+		return err
+	} else if err := db_.Ping(); err != nil {
+		db_.Close()
 		return err
 	} else {
-		// TODO: 以下参数应当改为通过配置文件配置。
-		db_.SetMaxOpenConns(100)
-		db_.SetMaxIdleConns(90)
-		db_.SetConnMaxLifetime(20 * time.Minute)
+		db = db_
+	}
 
-		if err := db_.Ping(); err != nil {
+	if dbConfig.ReplicaDSN != "" {
+		if replica_, err := open(dbConfig.ReplicaDSN, dbConfig); err != nil {
+			// 连接字符串有误，属于配置错误。
 			return err
 		} else {
-			db = db_
-			return nil
+			replica = replica_
+		}
+
+		if err := replica.Ping(); err != nil {
+			markReplicaDown(err)
+		}
+	}
+
+	return nil
+}
+
+// 创建数据库连接池，此时还不会连接数据库。
+// dsn_ 数据库连接字符串。如果其中没有指定连接超时，那么使用配置的连接超时。
+// dbConfig 数据库配置。
+func open(dsn_ string, dbConfig *_config.DBConfiguration) (*sql.DB, error) {
+	if dbConfig.DialTimeout > 0 {
+		if c, err := mysql.ParseDSN(dsn_); err != nil {
+			return nil, err
+		} else if c.Timeout == 0 {
+			c.Timeout = time.Duration(dbConfig.DialTimeout) * time.Second
+			dsn_ = c.FormatDSN()
 		}
 	}
+
+	if db_, err := sql.Open("mysql", dsn_); err != nil {
+		return nil, err
+	} else {
+		db_.SetMaxOpenConns(dbConfig.MaxOpenConns)
+		db_.SetMaxIdleConns(dbConfig.MaxIdleConns)
+		db_.SetConnMaxLifetime(time.Duration(dbConfig.ConnMaxLifetime) * time.Second)
+		db_.SetConnMaxIdleTime(time.Duration(dbConfig.ConnMaxIdleTime) * time.Second)
+
+		return db_, nil
+	}
+}
+
+// 检查主库是否可用。
+// ctx 上下文。
+func Ping(ctx context.Context) error {
+	return db.PingContext(ctx)
+}
+
+// 返回是否配置了只读副本。
+func HasReplica() bool {
+	return replica != nil
+}
+
+// 检查只读副本是否可用。只读副本不可用时读取会自动改为使用主库，因此只读副本出错不影响服务。
+// ctx 上下文。
+func PingReplica(ctx context.Context) error {
+	if replica == nil {
+		return errors.New("no read replica configured")
+	}
+
+	return replica.PingContext(ctx)
+}
+
+// 返回当前可以使用的只读副本。如果没有配置只读副本或者只读副本最近出错，那么返回nil。
+func usableReplica() *sql.DB {
+	if replica == nil || time.Now().UnixMilli() < atomic.LoadInt64(&replicaDownUntil) {
+		return nil
+	}
+
+	return replica
+}
+
+// 判断错误是否说明数据库不可用，比如连接失败、连接断开或者连接数已满。
+// SQL语句本身的错误和读取结果的错误不说明数据库不可用。
+func isConnError(err error) bool {
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr) {
+		return true
+	}
+
+	return isMySQLError(err, mysqlErrConCount, mysqlErrServerShutdown)
+}
+
+// 记录只读副本出错，在一段时间内不再使用只读副本。
+func markReplicaDown(err error) {
+	log.Printf("[WARN] Read replica is unavailable, fallback to primary for %s. cause=%s\n", replicaRetryInterval, err)
+	atomic.StoreInt64(&replicaDownUntil, time.Now().Add(replicaRetryInterval).UnixMilli())
+}
+
+// 执行只读查询，优先使用只读副本，只读副本不可用时使用主库。
+// 只有连接错误会改为使用主库，SQL语句的错误和上下文被取消导致的错误直接返回。
+func readQuery(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if r := usableReplica(); r != nil {
+		if rows, err := r.QueryContext(ctx, query, args...); err == nil || ctx.Err() != nil || !isConnError(err) {
+			return rows, err
+		} else {
+			markReplicaDown(err)
+		}
+	}
+
+	return db.QueryContext(ctx, query, args...)
+}
+
+// 表示只读查询的单行结果。
+type readRow struct {
+	ctx   context.Context
+	query string
+	args  []interface{}
+}
+
+// 执行只读查询并返回单行结果，查询在调用`Scan`时执行。
+func readQueryRow(ctx context.Context, query string, args ...interface{}) *readRow {
+	return &readRow{ctx: ctx, query: query, args: args}
+}
+
+// 读取单行结果，优先使用只读副本，只读副本不可用时使用主库。
+// 和`sql.Row.Scan`一样，没有结果时返回`sql.ErrNoRows`。只有连接错误会改为使用主库。
+func (r *readRow) Scan(dest ...interface{}) error {
+	if rp := usableReplica(); rp != nil {
+		if err := rp.QueryRowContext(r.ctx, r.query, r.args...).Scan(dest...); err == nil || r.ctx.Err() != nil || !isConnError(err) {
+			return err
+		} else {
+			markReplicaDown(err)
+		}
+	}
+
+	return db.QueryRowContext(r.ctx, r.query, r.args...).Scan(dest...)
+}
+
+// 生成`IN`子句中的参数占位符。
+// n 参数的个数，必须大于0。
+// 返回以逗号分隔的占位符，比如`?, ?, ?`。
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// 将字符串切片转换为查询参数，重复的字符串只保留一个。
+// ss 待转换的字符串切片。
+// 返回查询参数。
+func distinctArgs(ss []string) []interface{} {
+	result := make([]interface{}, 0, len(ss))
+	seen := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+
+	return result
 }
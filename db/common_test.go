@@ -0,0 +1,38 @@
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsConnError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad conn", driver.ErrBadConn, true},
+		{"invalid conn", mysql.ErrInvalidConn, true},
+		{"dial", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"wrapped dial", fmt.Errorf("query failed: %w", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("reset")}), true},
+		{"too many connections", &mysql.MySQLError{Number: 1040, Message: "Too many connections"}, true},
+		{"syntax", &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, false},
+		{"no such table", &mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}, false},
+		{"no rows", sql.ErrNoRows, false},
+		{"scan", errors.New("sql: Scan error on column index 0"), false},
+	}
+
+	for _, c := range cases {
+		if got := isConnError(c.err); got != c.want {
+			t.Errorf("isConnError(%s) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
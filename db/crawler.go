@@ -4,6 +4,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -28,12 +29,16 @@ type CrawlerInfoPo struct {
 	TrackingFieldType int    // 附加字段类型。
 	SiteCrawlingName  string
 	SiteAnalyzedName  string
+	BatchSize         int // 每次调用最多可以查询的运单数，大于1表示支持批量查询。
+	MaxConcurrency    int // 最大并发数，0表示不限制。
+	MaxRps            int // 每秒最大请求数，0表示不限制。
 }
 
 const (
 	selectCrawlerInfoByCarrierCode = `select tci.id,
 	tci.name, tci.req_url, tci.type, coalesce(tcp.req_url, ''), coalesce(tcp.req_method, ''), coalesce(tcp.req_headers, ''), coalesce(tcp.req_data, ''), coalesce(tcp.req_verify, 0), coalesce(tcp.req_json, 0), coalesce(tcp.req_proxy, ''),
-	coalesce(tcp.req_timeout, 0), coalesce(tcp.site_encrypt, 0), coalesce(tcp.tracking_field_name, ''), coalesce(tcp.tracking_field_type, 0), coalesce(tcp.site_crawling_name, ''), coalesce(tcp.site_analyzed_name, '')
+	coalesce(tcp.req_timeout, 0), coalesce(tcp.site_encrypt, 0), coalesce(tcp.tracking_field_name, ''), coalesce(tcp.tracking_field_type, 0), coalesce(tcp.site_crawling_name, ''), coalesce(tcp.site_analyzed_name, ''),
+	coalesce(tci.batch_size, 1), coalesce(tci.max_concurrency, 0), coalesce(tci.max_rps, 0)
 	from tracking_crawler_info  tci
 left join tracking_crawler_param tcp on tcp.info_id = tci.id
 join carrier_info ci on ci.id = tci.carrier_id
@@ -50,10 +55,13 @@ where ci.carrier_code = ?
 	updateCrawlerHeartBeatNo = `update tracking_crawler_info set heart_beat_no = ? where id = ? and result_status <> 0`
 )
 
-func QueryCrawlerInfoByCarrierCode(carrierCode string, datePoint time.Time) *CrawlerInfoPo {
+// 依赖的表结构见迁移脚本`migrations/0003_crawler_batch_size.sql`和`migrations/0004_crawl_limits.sql`。
+
+func QueryCrawlerInfoByCarrierCode(ctx context.Context, carrierCode string, datePoint time.Time) *CrawlerInfoPo {
 	result := CrawlerInfoPo{}
-	if err := db.QueryRow(selectCrawlerInfoByCarrierCode, carrierCode, datePoint, datePoint).Scan(&result.Id, &result.Name, &result.Url, &result.Type, &result.TargetUrl, &result.ReqHttpMethod, &result.ReqHttpHeaders, &result.ReqHttpBody,
-		&result.Verify, &result.Json, &result.ReqProxy, &result.ReqTimeout, &result.SiteEncrypt, &result.TrackingFieldName, &result.TrackingFieldType, &result.SiteCrawlingName, &result.SiteAnalyzedName); err != nil {
+	if err := readQueryRow(ctx, selectCrawlerInfoByCarrierCode, carrierCode, datePoint, datePoint).Scan(&result.Id, &result.Name, &result.Url, &result.Type, &result.TargetUrl, &result.ReqHttpMethod, &result.ReqHttpHeaders, &result.ReqHttpBody,
+		&result.Verify, &result.Json, &result.ReqProxy, &result.ReqTimeout, &result.SiteEncrypt, &result.TrackingFieldName, &result.TrackingFieldType, &result.SiteCrawlingName, &result.SiteAnalyzedName,
+		&result.BatchSize, &result.MaxConcurrency, &result.MaxRps); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		} else {
@@ -69,8 +77,8 @@ func QueryCrawlerInfoByCarrierCode(carrierCode string, datePoint time.Time) *Cra
 // crawlerId 爬虫ID。
 // trackingNo 新的运单号。
 // 成功修改的记录数。**注意！！如果新的运单号等于当前运单号，那么实际不会修改任何记录，返回值是0**
-func UpgradeHeartBeatNo(crawlerId int64, trackingNo string) int {
-	if r, err := db.Exec(updateCrawlerHeartBeatNo, trackingNo, crawlerId); err != nil {
+func UpgradeHeartBeatNo(ctx context.Context, crawlerId int64, trackingNo string) int {
+	if r, err := db.ExecContext(ctx, updateCrawlerHeartBeatNo, trackingNo, crawlerId); err != nil {
 		panic(err)
 	} else {
 		if c, err := r.RowsAffected(); err != nil {
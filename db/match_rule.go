@@ -4,8 +4,8 @@
 package db
 
 import (
-	"database/sql"
-	"errors"
+	"context"
+	"fmt"
 	"regexp"
 	"time"
 )
@@ -26,13 +26,13 @@ func (m *MatchRulePo) Match(detail string) bool {
 }
 
 const (
-	selectMatchRuleByCarrierCode = `select ter.id, ted.target_type, ter.content, tes2.name_en from tracking_event_rule ter
+	selectMatchRuleByCarrierCodes = `select ci.carrier_code, ter.id, ted.target_type, ter.content, tes2.name_en from tracking_event_rule ter
 	join tracking_event_rule_detail ted on ted.event_rule_id = ter.id
 	join carrier_info ci on ci.id = ted.carrier_id
 	join tracking_event_info tei on tei.id = ter.event_id
 	join tracking_event_status tes1 on tes1.id  = tei.event_status_id
 	join tracking_event_status tes2 on tes2.id = tes1.parent_id
-	where ci.carrier_code = ?
+	where ci.carrier_code in (%s)
 	and ci.status = 1
 	and ted.status = 1
 	and ter.status = 1
@@ -44,28 +44,40 @@ const (
 	`
 )
 
-// 根据运输商号码和时间从数据库中查询有效的匹配规则。
-// 如果不存在符合条件的记录则返回空切片。
-func QueryMatchRuleByCarrierCode(carrierCode string, datePoint time.Time) []*MatchRulePo {
-	result := make([]*MatchRulePo, 0)
-	if rows, err := db.Query(selectMatchRuleByCarrierCode, carrierCode, datePoint, datePoint); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return result
-		} else {
-			panic(err)
-		}
+// 根据多个运输商号码和时间从数据库中查询有效的匹配规则，所有的运输商只需要一次查询。
+// ctx 上下文。
+// carrierCodes 运输商号码，可以重复。
+// datePoint 规则生效的时间。
+// 返回每个运输商的匹配规则。不存在匹配规则的运输商对应空切片。
+func QueryMatchRulesByCarrierCodes(ctx context.Context, carrierCodes []string, datePoint time.Time) map[string][]*MatchRulePo {
+	result := make(map[string][]*MatchRulePo, len(carrierCodes))
+	for _, carrierCode := range carrierCodes {
+		result[carrierCode] = make([]*MatchRulePo, 0)
+	}
+	if len(carrierCodes) == 0 {
+		return result
+	}
+
+	args := distinctArgs(carrierCodes)
+	query := fmt.Sprintf(selectMatchRuleByCarrierCodes, placeholders(len(args)))
+	if rows, err := readQuery(ctx, query, append(args, datePoint, datePoint)...); err != nil {
+		panic(err)
 	} else {
 		defer rows.Close()
 
 		for rows.Next() {
+			var carrierCode string
 			matchRule := MatchRulePo{}
-			if err := rows.Scan(&matchRule.Id, &matchRule.TargetType, &matchRule.Content, &matchRule.Code); err != nil {
+			if err := rows.Scan(&carrierCode, &matchRule.Id, &matchRule.TargetType, &matchRule.Content, &matchRule.Code); err != nil {
 				panic(err)
 			}
 			if matchRule.Content != "" {
 				matchRule.rp1, _ = regexp.Compile(matchRule.Content)
 			}
-			result = append(result, &matchRule)
+			result[carrierCode] = append(result[carrierCode], &matchRule)
+		}
+		if err := rows.Err(); err != nil {
+			panic(err)
 		}
 
 		return result
@@ -0,0 +1,321 @@
+// 该模块定义了数据库结构的版本化迁移。
+// 迁移脚本保存在`migrations`目录下并且嵌入到应用程序中，文件名的格式是`版本号_名称.sql`，按照版本号依次执行。
+// 已执行的迁移记录在`schema_migrations`表中。
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	migrationsDir string = "migrations" // 迁移脚本所在的目录。
+
+	migrationLockName    string = "schema_migrations" // 执行迁移时持有的MySQL命名锁，避免多个进程同时迁移。
+	migrationLockTimeout int    = 60                  // 等待命名锁的超时（秒）。
+
+	mysqlErrTableExists  uint16 = 1050 // ER_TABLE_EXISTS_ERROR
+	mysqlErrDupFieldName uint16 = 1060 // ER_DUP_FIELDNAME
+	mysqlErrDupKeyName   uint16 = 1061 // ER_DUP_KEYNAME
+	mysqlErrDupEntry     uint16 = 1062 // ER_DUP_ENTRY
+	mysqlErrNoSuchTable  uint16 = 1146 // ER_NO_SUCH_TABLE
+)
+
+const (
+	createSchemaMigrations string = `create table if not exists schema_migrations (
+	  version int(11) not null,
+	  name varchar(255) not null,
+	  applied_at datetime not null,
+	  primary key (version)
+	) comment='已执行的数据库迁移'`
+
+	selectSchemaMigrations string = `select version, applied_at from schema_migrations`
+
+	insertSchemaMigration string = `insert into schema_migrations (version, name, applied_at) values(?, ?, ?)`
+
+	countUniqueKey string = `select count(*) from information_schema.statistics where table_schema = database() and table_name = ? and index_name = ? and non_unique = 0`
+)
+
+// 保存跟踪结果依赖的唯一键，缺少唯一键时并发保存会产生重复的记录。
+var requiredUniqueKeys = [][2]string{{"tracking_result", "uk_tracking_result"}, {"tracking", "uk_tracking"}}
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// 表示一个迁移脚本。
+type Migration struct {
+	Version    int      // 版本号。
+	Name       string   // 名称。
+	Statements []string // 依次执行的SQL语句。
+}
+
+// 返回迁移脚本的文件名，不包括扩展名。
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// 表示一个迁移脚本的执行状态。
+type MigrationStatusPo struct {
+	*Migration
+	Applied   bool      // 是否已执行。
+	AppliedAt time.Time // 执行时间。
+}
+
+// 加载嵌入的迁移脚本，按照版本号排序。
+func Migrations() ([]*Migration, error) {
+	entries, err := migrationFiles.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Migration, 0, len(entries))
+	versions := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		fileName := entry.Name()
+		baseName := strings.TrimSuffix(fileName, ".sql")
+		parts := strings.SplitN(baseName, "_", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("illegal migration file name: %s", fileName)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("illegal migration version: %s", fileName)
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, fileName)
+		}
+		versions[version] = fileName
+
+		content, err := migrationFiles.ReadFile(path.Join(migrationsDir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &Migration{Version: version, Name: parts[1], Statements: splitStatements(string(content))})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// 将迁移脚本拆分为SQL语句。
+// 每条语句以行末的分号结束，以`--`开头的行是注释。
+func splitStatements(content string) []string {
+	result := make([]string, 0)
+	statement := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, ";") {
+			statement = append(statement, strings.TrimSuffix(strings.TrimRight(line, " \t\r"), ";"))
+			result = append(result, strings.Join(statement, "\n"))
+			statement = statement[:0]
+		} else {
+			statement = append(statement, strings.TrimRight(line, "\r"))
+		}
+	}
+	if len(statement) != 0 {
+		result = append(result, strings.Join(statement, "\n"))
+	}
+
+	return result
+}
+
+// 查询已执行的迁移的版本号和执行时间。
+// ctx 上下文。
+// q 执行查询的数据库或者连接。
+// 如果`schema_migrations`表不存在，那么返回空的结果。
+func queryAppliedMigrations(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}) (map[int]time.Time, error) {
+	result := make(map[int]time.Time)
+	rows, err := q.QueryContext(ctx, selectSchemaMigrations)
+	if err != nil {
+		if isMySQLError(err, mysqlErrNoSuchTable) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = appliedAt
+	}
+
+	return result, rows.Err()
+}
+
+// 查询所有迁移脚本的执行状态，按照版本号排序。
+// ctx 上下文。
+func QueryMigrationStatus(ctx context.Context) ([]*MigrationStatusPo, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := queryAppliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*MigrationStatusPo, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		result = append(result, &MigrationStatusPo{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return result, nil
+}
+
+// 依次执行所有未执行的迁移脚本。
+// ctx 上下文。
+// dryRun 是否只输出将要执行的SQL语句，不修改数据库。
+// out 输出执行过程。
+// 迁移总是在主库上执行，多个进程同时迁移时依次执行。
+// 迁移脚本中的DDL语句无法回滚，因此重复创建表、列或者索引的错误被忽略，从而可以重新执行部分失败的迁移，也可以接管手工修改过的数据库。
+func Migrate(ctx context.Context, dryRun bool, out io.Writer) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !dryRun {
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "select get_lock(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked); err != nil {
+			return err
+		} else if locked.Int64 != 1 {
+			return fmt.Errorf("cannot acquire migration lock %s in %d seconds", migrationLockName, migrationLockTimeout)
+		}
+		defer conn.ExecContext(context.Background(), "select release_lock(?)", migrationLockName)
+
+		if _, err := conn.ExecContext(ctx, createSchemaMigrations); err != nil {
+			return err
+		}
+	}
+
+	applied, err := queryAppliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		pending++
+
+		if dryRun {
+			fmt.Fprintf(out, "-- %s\n", m)
+			for _, statement := range m.Statements {
+				fmt.Fprintf(out, "%s;\n\n", statement)
+			}
+			continue
+		}
+
+		fmt.Fprintf(out, "Applying %s\n", m)
+		for _, statement := range m.Statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				if isMySQLError(err, mysqlErrTableExists, mysqlErrDupFieldName, mysqlErrDupKeyName) {
+					fmt.Fprintf(out, "  already applied: %v\n", err)
+					continue
+				}
+				if isMySQLError(err, mysqlErrDupEntry) {
+					// 清理重复记录之后仍有进程写入了重复的记录。
+					return fmt.Errorf("cannot apply migration %s: duplicate rows were written while migrating (%v); stop processes of older versions and run -migrate up again", m, err)
+				}
+				return fmt.Errorf("cannot apply migration %s: %w", m, err)
+			}
+		}
+
+		if _, err := conn.ExecContext(ctx, insertSchemaMigration, m.Version, m.Name, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if pending == 0 {
+		fmt.Fprintln(out, "Database schema is up to date")
+	}
+
+	return nil
+}
+
+// 检查数据库结构是否是最新版本。
+// ctx 上下文。
+// 如果存在未执行的迁移脚本，那么返回错误。数据库中存在应用程序不认识的新版本时不会返回错误，从而可以先迁移数据库再逐步部署应用程序。
+func CheckSchema(ctx context.Context) error {
+	status, err := QueryMigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot check database schema: %w", err)
+	}
+
+	pending := make([]string, 0)
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.String())
+		}
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("database schema is outdated, pending migrations: %s; run with -migrate up", strings.Join(pending, ", "))
+	}
+
+	return ensureUniqueKeys(ctx)
+}
+
+// 检查保存跟踪结果依赖的唯一键是否存在。
+// 迁移记录可能和实际的表结构不一致（比如手工修改了迁移记录或者删除了索引），缺少唯一键时拒绝启动。
+// ctx 上下文。
+func ensureUniqueKeys(ctx context.Context) error {
+	for _, k := range requiredUniqueKeys {
+		var n int
+		if err := db.QueryRowContext(ctx, countUniqueKey, k[0], k[1]).Scan(&n); err != nil {
+			return fmt.Errorf("cannot check unique key %s of table %s: %w", k[1], k[0], err)
+		} else if n == 0 {
+			return fmt.Errorf("unique key %s of table %s is missing, concurrent saves would create duplicate rows; "+
+				"delete migration 6 from schema_migrations and run with -migrate up, which removes duplicate rows and creates the key", k[1], k[0])
+		}
+	}
+
+	return nil
+}
+
+func isMySQLError(err error, numbers ...uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		for _, number := range numbers {
+			if mysqlErr.Number == number {
+				return true
+			}
+		}
+	}
+
+	return false
+}
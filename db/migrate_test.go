@@ -0,0 +1,54 @@
+// @Author: agent
+// @Created: 2026-10-18
+package db
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	content := "-- 注释\n\nALTER TABLE `a`\nADD COLUMN `b` int;\n-- 注释\nDELETE FROM `a`;  \nSELECT 1"
+	want := []string{"ALTER TABLE `a`\nADD COLUMN `b` int", "DELETE FROM `a`", "SELECT 1"}
+	if got := splitStatements(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements = %#v, want %#v", got, want)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", m, m.Version, i+1)
+		}
+		if len(m.Statements) == 0 {
+			t.Errorf("migration %s has no statements", m)
+		}
+	}
+}
+
+// 创建唯一键之前必须删除同一个表中的重复记录，否则有重复数据的数据库无法迁移。
+func TestMigrationsDedupBeforeUniqueKey(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uniqueKey := regexp.MustCompile("(?is)^ALTER TABLE `(\\w+)`.*ADD UNIQUE KEY")
+	dedup := regexp.MustCompile("(?is)^DELETE (\\w+) FROM `(\\w+)` (\\w+)")
+	for _, m := range migrations {
+		deduped := make(map[string]bool)
+		for _, statement := range m.Statements {
+			if mm := dedup.FindStringSubmatch(statement); mm != nil && mm[1] == mm[3] {
+				deduped[mm[2]] = true
+			} else if mm := uniqueKey.FindStringSubmatch(statement); mm != nil && !deduped[mm[1]] {
+				t.Errorf("migration %s adds unique key to %s without removing duplicates first", m, mm[1])
+			}
+		}
+	}
+}
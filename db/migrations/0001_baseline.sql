@@ -0,0 +1,229 @@
+-- 基础表结构，也就是引入版本化迁移之前各个环境中已经存在的表。
+-- 只包含应用程序访问的列，已有的表不会被修改。
+
+CREATE TABLE IF NOT EXISTS `carrier_info` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_code` varchar(64) DEFAULT NULL COMMENT '运输商编号',
+  `name_cn` varchar(255) NOT NULL DEFAULT '' COMMENT '中文名称',
+  `name_en` varchar(255) NOT NULL DEFAULT '' COMMENT '英文名称',
+  `carrier_type` int(11) NOT NULL DEFAULT 0 COMMENT '运输商类别',
+  `country_id` int(11) NOT NULL DEFAULT 0 COMMENT '国家ID',
+  `website_url` varchar(255) DEFAULT NULL COMMENT '官网',
+  `tel` varchar(64) DEFAULT NULL COMMENT '电话',
+  `email` varchar(255) DEFAULT NULL COMMENT '邮箱',
+  `description` varchar(1024) DEFAULT NULL COMMENT '说明',
+  `service_status` tinyint(4) NOT NULL DEFAULT 1 COMMENT '服务状态',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_code` (`carrier_code`)
+) COMMENT='运输商';
+
+CREATE TABLE IF NOT EXISTS `tracking_no_rule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '规则名称',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_id` (`carrier_id`)
+) COMMENT='运单号规则';
+
+CREATE TABLE IF NOT EXISTS `tracking_no_rule_detail` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `rule_id` bigint(20) NOT NULL COMMENT '运单号规则ID',
+  `code` varchar(255) NOT NULL DEFAULT '' COMMENT '规则内容',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_rule_id` (`rule_id`)
+) COMMENT='运单号规则明细';
+
+CREATE TABLE IF NOT EXISTS `sys_biz_attachment` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ext_id` bigint(20) NOT NULL COMMENT '业务对象ID',
+  `ext_type` int(11) NOT NULL COMMENT '业务对象类型，1-运输商图标',
+  `real_path` varchar(255) NOT NULL DEFAULT '' COMMENT '文件路径',
+  `file_name` varchar(255) NOT NULL DEFAULT '' COMMENT '文件名',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_ext` (`ext_id`, `ext_type`)
+) COMMENT='业务附件';
+
+CREATE TABLE IF NOT EXISTS `tracking_api` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '查询代理名称',
+  `api_url` varchar(1024) NOT NULL DEFAULT '' COMMENT '访问查询代理的URL',
+  `request_type` int(11) NOT NULL DEFAULT 1 COMMENT '1-GET 2-POST',
+  `service_status` tinyint(4) NOT NULL DEFAULT 1 COMMENT '服务状态',
+  `start_time` datetime NOT NULL COMMENT '生效时间',
+  `end_time` datetime NOT NULL COMMENT '失效时间',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_id` (`carrier_id`)
+) COMMENT='API设置';
+
+CREATE TABLE IF NOT EXISTS `tracking_api_encrypt` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `encrypt_type` int(11) NOT NULL DEFAULT 0 COMMENT '加密算法',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`)
+) COMMENT='API参数加密设置';
+
+CREATE TABLE IF NOT EXISTS `tracking_api_param` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `api_id` bigint(20) NOT NULL COMMENT 'API设置ID',
+  `field_type` int(11) NOT NULL DEFAULT 0 COMMENT '字段类型',
+  `field_name` varchar(255) NOT NULL DEFAULT '' COMMENT '字段名',
+  `field_value` varchar(1024) NOT NULL DEFAULT '' COMMENT '字段值',
+  `is_head_param` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否请求头字段',
+  `is_body_param` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否请求体字段',
+  `need_encrypt` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否需要加密',
+  `encrypt_id` bigint(20) DEFAULT NULL COMMENT '加密设置ID',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_api_id` (`api_id`)
+) COMMENT='API参数';
+
+CREATE TABLE IF NOT EXISTS `tracking_crawler_info` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '查询代理名称',
+  `req_url` varchar(1024) NOT NULL DEFAULT '' COMMENT '访问查询代理的URL',
+  `type` varchar(64) NOT NULL DEFAULT '' COMMENT '查询代理类型',
+  `priority` int(11) NOT NULL DEFAULT 0 COMMENT '优先级，越小越优先',
+  `heart_beat_no` varchar(255) DEFAULT NULL COMMENT '心跳运单号',
+  `result_status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '最近的查询结果状态',
+  `service_status` tinyint(4) NOT NULL DEFAULT 1 COMMENT '服务状态',
+  `start_time` datetime NOT NULL COMMENT '生效时间',
+  `end_time` datetime NOT NULL COMMENT '失效时间',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_id` (`carrier_id`)
+) COMMENT='查询代理';
+
+CREATE TABLE IF NOT EXISTS `tracking_crawler_param` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `info_id` bigint(20) NOT NULL COMMENT '查询代理ID',
+  `req_url` varchar(1024) DEFAULT NULL COMMENT '目标网页的URL',
+  `req_method` varchar(16) DEFAULT NULL COMMENT '访问目标网页的HTTP Method',
+  `req_headers` text COMMENT '访问目标网页附带的头部',
+  `req_data` text COMMENT '访问目标网页附带的数据',
+  `req_verify` tinyint(1) DEFAULT NULL COMMENT '是否需要验证请求结果',
+  `req_json` tinyint(1) DEFAULT NULL COMMENT '是否需要将payload序列化为json',
+  `req_proxy` varchar(255) DEFAULT NULL COMMENT '代理服务器',
+  `req_timeout` int(11) DEFAULT NULL COMMENT '访问目标网页的超时时间',
+  `site_encrypt` tinyint(4) DEFAULT NULL COMMENT '目标站点是否加密 0-不加密，1-需要加密',
+  `tracking_field_name` varchar(255) DEFAULT NULL COMMENT '附加字段名',
+  `tracking_field_type` int(11) DEFAULT NULL COMMENT '附加字段类型',
+  `site_crawling_name` varchar(255) DEFAULT NULL,
+  `site_analyzed_name` varchar(255) DEFAULT NULL,
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_info_id` (`info_id`)
+) COMMENT='查询代理参数';
+
+CREATE TABLE IF NOT EXISTS `tracking_event_status` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `parent_id` bigint(20) DEFAULT NULL COMMENT '上级状态ID',
+  `name_en` varchar(255) NOT NULL DEFAULT '' COMMENT '状态代码',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`)
+) COMMENT='事件状态';
+
+CREATE TABLE IF NOT EXISTS `tracking_event_info` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `event_status_id` bigint(20) NOT NULL COMMENT '事件状态ID',
+  `start_time` datetime NOT NULL COMMENT '生效时间',
+  `end_time` datetime NOT NULL COMMENT '失效时间',
+  PRIMARY KEY (`id`)
+) COMMENT='事件';
+
+CREATE TABLE IF NOT EXISTS `tracking_event_rule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `event_id` bigint(20) NOT NULL COMMENT '事件ID',
+  `content` varchar(1024) NOT NULL DEFAULT '' COMMENT '规则匹配内容',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`)
+) COMMENT='事件匹配规则';
+
+CREATE TABLE IF NOT EXISTS `tracking_event_rule_detail` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `event_rule_id` bigint(20) NOT NULL COMMENT '事件匹配规则ID',
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `target_type` varchar(64) NOT NULL DEFAULT '' COMMENT '目标类型',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_id` (`carrier_id`)
+) COMMENT='事件匹配规则适用的运输商';
+
+CREATE TABLE IF NOT EXISTS `tracking_result` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `language` int(11) NOT NULL COMMENT '查询语言',
+  `tracking_no` varchar(64) NOT NULL COMMENT '运单号',
+  `events_json` mediumtext NOT NULL COMMENT '事件JSON',
+  `md5` char(32) NOT NULL COMMENT '事件JSON的MD5',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  `create_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  `tracking_status` int(11) DEFAULT NULL COMMENT '运单状态，4表示已妥投',
+  PRIMARY KEY (`id`),
+  KEY `idx_tracking_no` (`tracking_no`)
+) COMMENT='跟踪结果';
+
+CREATE TABLE IF NOT EXISTS `tracking` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `language` int(11) NOT NULL COMMENT '查询语言',
+  `tracking_no` varchar(64) NOT NULL COMMENT '运单号',
+  `delivery_time` datetime DEFAULT NULL COMMENT '妥投时间',
+  `destination` varchar(255) DEFAULT NULL COMMENT '妥投地点',
+  `collector_type` int(11) NOT NULL DEFAULT 0 COMMENT '跟踪结果的来源',
+  `collector_real_name` varchar(255) NOT NULL DEFAULT '' COMMENT '查询代理的名字',
+  `create_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`)
+) COMMENT='跟踪记录';
+
+CREATE TABLE IF NOT EXISTS `tracking_detail` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `info_id` bigint(20) NOT NULL COMMENT '跟踪记录ID',
+  `date` datetime DEFAULT NULL COMMENT '事件时间',
+  `place` varchar(255) NOT NULL DEFAULT '' COMMENT '事件地点',
+  `details` varchar(1024) NOT NULL DEFAULT '' COMMENT '事件详情',
+  `state` int(11) NOT NULL DEFAULT 0 COMMENT '事件状态',
+  `event_id` bigint(20) DEFAULT NULL COMMENT '匹配的事件ID',
+  `event_name` varchar(255) DEFAULT NULL COMMENT '匹配的事件名称',
+  `event_rule_match` smallint(6) DEFAULT NULL COMMENT '是否匹配了事件规则',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  `create_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_info_id` (`info_id`)
+) COMMENT='跟踪事件';
+
+CREATE TABLE IF NOT EXISTS `tracking_log` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `client_id` varchar(64) NOT NULL DEFAULT '' COMMENT '客户端ID',
+  `carrier_id` bigint(20) DEFAULT NULL COMMENT '运输商ID',
+  `tracking_no` varchar(64) NOT NULL DEFAULT '' COMMENT '运单号',
+  `match_type` int(11) NOT NULL DEFAULT 0 COMMENT '运输商的匹配方式',
+  `country_id` int(11) DEFAULT NULL COMMENT '国家ID',
+  `timing` int(11) NOT NULL DEFAULT 0 COMMENT '查询耗时（毫秒）',
+  `host` varchar(64) NOT NULL DEFAULT '' COMMENT '客户端地址',
+  `result_status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '查询是否成功',
+  `statistics_date` date NOT NULL COMMENT '统计日期',
+  `collector_type` int(11) DEFAULT NULL COMMENT '跟踪结果的来源',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  `create_time` datetime NOT NULL,
+  `creator` varchar(64) NOT NULL DEFAULT '',
+  `update_time` datetime NOT NULL,
+  `modifier` varchar(64) NOT NULL DEFAULT '',
+  `request_time` datetime DEFAULT NULL COMMENT '请求时间',
+  `crawler_req_time` datetime DEFAULT NULL COMMENT '调用查询代理的时间',
+  `crawler_resp_time` datetime DEFAULT NULL COMMENT '查询代理返回响应的时间',
+  `crawler_resp_body` mediumtext COMMENT '查询代理返回的内容',
+  `result_note` varchar(1024) NOT NULL DEFAULT '' COMMENT '查询结果说明',
+  PRIMARY KEY (`id`),
+  KEY `idx_statistics_date` (`statistics_date`)
+) COMMENT='查询日志';
@@ -0,0 +1,4 @@
+-- 区分v2版本的跟踪结果，查询接口服务只读取v2版本的记录。
+
+ALTER TABLE `tracking_result`
+ADD COLUMN `v2` tinyint(4) NOT NULL DEFAULT 0 COMMENT '是否v2版本的记录' AFTER `tracking_status`;
@@ -0,0 +1,4 @@
+-- 支持批量查询的查询代理。
+
+ALTER TABLE `tracking_crawler_info`
+ADD COLUMN `batch_size` int(11) NOT NULL DEFAULT 1 COMMENT '每次调用最多可以查询的运单数，大于1表示支持批量查询' AFTER `priority`;
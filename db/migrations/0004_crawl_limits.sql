@@ -0,0 +1,19 @@
+-- 运输商和查询代理的爬取限制。
+
+ALTER TABLE `tracking_crawler_info`
+ADD COLUMN `max_concurrency` int(11) NOT NULL DEFAULT 0 COMMENT '最大并发数，0表示不限制',
+ADD COLUMN `max_rps` int(11) NOT NULL DEFAULT 0 COMMENT '每秒最大请求数，0表示不限制';
+
+ALTER TABLE `tracking_api`
+ADD COLUMN `max_concurrency` int(11) NOT NULL DEFAULT 0 COMMENT '最大并发数，0表示不限制',
+ADD COLUMN `max_rps` int(11) NOT NULL DEFAULT 0 COMMENT '每秒最大请求数，0表示不限制';
+
+CREATE TABLE IF NOT EXISTS `tracking_carrier_limit` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `carrier_id` bigint(20) NOT NULL COMMENT '运输商ID',
+  `max_concurrency` int(11) NOT NULL DEFAULT 0 COMMENT '最大并发数，0表示不限制',
+  `max_rps` int(11) NOT NULL DEFAULT 0 COMMENT '每秒最大请求数，0表示不限制',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_carrier_id` (`carrier_id`)
+) COMMENT='运输商爬取限制';
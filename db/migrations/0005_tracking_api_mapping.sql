@@ -0,0 +1,19 @@
+-- 直接调用API时，将响应映射为跟踪事件的规则。
+
+CREATE TABLE IF NOT EXISTS `tracking_api_mapping` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `api_id` bigint(20) NOT NULL COMMENT '对应的API设置ID',
+  `format` varchar(8) NOT NULL DEFAULT 'JSON' COMMENT '响应格式：JSON或者XML',
+  `events_path` varchar(255) NOT NULL COMMENT '事件列表的选择器',
+  `date_path` varchar(255) NOT NULL COMMENT '事件日期的选择器',
+  `place_path` varchar(255) DEFAULT NULL COMMENT '事件地点的选择器',
+  `details_path` varchar(255) NOT NULL COMMENT '事件明细的选择器',
+  `status_path` varchar(255) DEFAULT NULL COMMENT '响应状态码的选择器',
+  `success_status` varchar(255) DEFAULT NULL COMMENT '表示成功的状态码，逗号分隔',
+  `no_tracking_status` varchar(255) DEFAULT NULL COMMENT '表示单号未查询到的状态码，逗号分隔',
+  `date_format` varchar(64) DEFAULT NULL COMMENT '事件日期的格式',
+  `time_zone` varchar(64) DEFAULT NULL COMMENT '事件日期的时区',
+  `status` tinyint(4) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `idx_api_id` (`api_id`)
+) COMMENT='API响应映射规则';
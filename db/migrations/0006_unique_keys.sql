@@ -0,0 +1,28 @@
+-- 并发保存同一个运单时依赖的唯一键。
+-- 创建唯一键之前首先清理已有的重复记录，每组重复记录只保留一条。清理语句可以重复执行，没有重复记录时不删除任何记录。
+
+-- 每个运输商、语言、运单号和事件JSON只保留一条跟踪结果：优先保留v2版本的记录，其次保留更新时间最晚的记录，更新时间相同时保留ID最大的记录。
+DELETE r FROM `tracking_result` r
+JOIN `tracking_result` n ON n.`tracking_no` = r.`tracking_no` AND n.`carrier_id` = r.`carrier_id` AND n.`language` = r.`language` AND n.`md5` = r.`md5`
+  AND (n.`v2` > r.`v2` OR (n.`v2` = r.`v2` AND (n.`update_time` > r.`update_time` OR (n.`update_time` = r.`update_time` AND n.`id` > r.`id`))));
+
+ALTER TABLE `tracking_result`
+ADD UNIQUE KEY `uk_tracking_result` (`carrier_id`, `language`, `tracking_no`, `md5`);
+
+-- 清理重复的跟踪记录时按照运单号关联，之后也用于按照运单号查询。
+ALTER TABLE `tracking`
+ADD KEY `idx_tracking_no` (`tracking_no`);
+
+-- 每个运输商、语言和运单号只保留一条跟踪记录：保留更新时间最晚的记录，更新时间相同时保留ID最大的记录。
+-- 保存新的跟踪结果时总是替换跟踪记录的全部跟踪事件，所以保留的记录已经包含完整的跟踪事件，其它记录的跟踪事件直接删除。
+DELETE d FROM `tracking_detail` d
+JOIN `tracking` t ON t.`id` = d.`info_id`
+JOIN `tracking` n ON n.`tracking_no` = t.`tracking_no` AND n.`carrier_id` = t.`carrier_id` AND n.`language` = t.`language`
+  AND (n.`update_time` > t.`update_time` OR (n.`update_time` = t.`update_time` AND n.`id` > t.`id`));
+
+DELETE t FROM `tracking` t
+JOIN `tracking` n ON n.`tracking_no` = t.`tracking_no` AND n.`carrier_id` = t.`carrier_id` AND n.`language` = t.`language`
+  AND (n.`update_time` > t.`update_time` OR (n.`update_time` = t.`update_time` AND n.`id` > t.`id`));
+
+ALTER TABLE `tracking`
+ADD UNIQUE KEY `uk_tracking` (`carrier_id`, `language`, `tracking_no`);
@@ -1,60 +1,136 @@
-// 该模块定义了`tracking_log`对象的数据库访问方法。
+// 该模块定义了`tracking`和`tracking_detail`对象的数据库访问方法。
 // @Author: Haart
 // @Created: 2021-10-27
 package db
 
 import (
+	"context"
+	"crypto/md5"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	_types "com.cne/ai-tracking-search/types"
 )
 
+// 表示一次查询得到的跟踪结果快照。
+type TrackingSnapshotPo struct {
+	CarrierId         int64                    // 运输商ID。
+	Language          _types.LangId            // 需要爬取的语言。
+	TrackingNo        string                   // 运单号。
+	EventsJson        string                   // 事件JSON。
+	Done              bool                     // 是否已妥投。
+	DoneTime          time.Time                // 妥投时间。
+	DonePlace         string                   // 妥投地点。
+	CollectorType     _types.TrackingResultSrc // 跟踪结果的来源。
+	CollectorRealName string                   // 查询代理的名字。
+	Details           []*TrackingDetailPo      // 跟踪事件。
+}
+
+// 表示一个跟踪事件。
+type TrackingDetailPo struct {
+	Date    time.Time // 事件时间。
+	Place   string    // 事件地点。
+	Details string    // 事件详情。
+	State   int       // 事件状态。
+}
+
 const (
-	insertTracking string = `insert into tracking(carrier_id, language, tracking_no, delivery_time, destination, collector_type, collector_real_name, create_time, update_time, status)
+	maxDetailsPerInsert int = 100 // 每条插入语句中最多包含的跟踪事件数。
+
+	// 每个运输商、语言和运单号只有一条跟踪记录，再次保存时更新原有记录。`last_insert_id(id)`使更新时也能获得原有记录的ID。
+	upsertTracking string = `insert into tracking(carrier_id, language, tracking_no, delivery_time, destination, collector_type, collector_real_name, create_time, update_time, status)
 	values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	on duplicate key update id = last_insert_id(id), delivery_time = values(delivery_time), destination = values(destination), collector_type = values(collector_type),
+	  collector_real_name = values(collector_real_name), update_time = values(update_time), status = values(status)
 	`
 
-	insertTrackingDetail string = `insert into tracking_detail(info_id, date, place, details, state, event_id, event_name, event_rule_match, status, create_time, update_time)
-	values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	insertTrackingDetails string = `insert into tracking_detail(info_id, date, place, details, state, event_id, event_name, event_rule_match, status, create_time, update_time)
+	values %s`
 
-	deleteTracking string = `delete from tracking where carrier_id = ? and language = ? and tracking_no = ?`
+	deleteTrackingDetails string = `delete from tracking_detail where info_id = ?`
 )
 
-func SaveTrackingToDb(carrierId int64, language _types.LangId, trackingNo string, deliveryTime time.Time, destination string, collectorType _types.TrackingResultSrc, collectorRealName string, datePoint time.Time, done bool) int64 {
-	deliveryTime_ := sql.NullTime{Time: deliveryTime, Valid: done}
-	destination_ := sql.NullString{String: destination, Valid: destination != ""}
-	if result, err := db.Exec(insertTracking, carrierId, int(language), trackingNo, deliveryTime_, destination_, int(collectorType), collectorRealName, datePoint, datePoint, 1 /*status*/); err != nil {
+// 依赖的表结构见迁移脚本`migrations/0006_unique_keys.sql`。
+
+// 在一个事务中保存跟踪结果快照。
+// 首先按照唯一键保存跟踪结果，如果相同的跟踪结果已经存在，那么只刷新更新时间，不再改写跟踪记录和跟踪事件；
+// 否则更新跟踪记录，并替换其中的所有跟踪事件。并发保存同一个运单时，唯一键和行锁保证不会产生重复或者不完整的记录。
+// ctx 上下文。
+// s 待保存的快照。
+// datePoint 保存的时间。
+// 返回是否保存了新的跟踪结果。如果相同的跟踪结果已经存在则返回false。
+func SaveTrackingSnapshot(ctx context.Context, s *TrackingSnapshotPo, datePoint time.Time) (saved bool) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
 		panic(err)
-	} else {
-		if lastRowId, err := result.LastInsertId(); err != nil {
-			panic(err)
-		} else {
-			return lastRowId
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
 		}
+	}()
+
+	eventsJsonMd5 := fmt.Sprintf("%x", md5.Sum([]byte(s.EventsJson)))
+	var trackingStatus int
+	if s.Done {
+		trackingStatus = 4 // 已投递。
+	} else {
+		trackingStatus = 1 // 在途。
 	}
-}
 
-func SaveTrackingDetailToDb(infoId int64, date time.Time, place string, details string, state int, datePoint time.Time) int64 {
-	if result, err := db.Exec(insertTrackingDetail, infoId, date, place, details, state, sql.NullInt64{}, sql.NullString{}, sql.NullInt16{}, 1 /*status*/, datePoint, datePoint); err != nil {
+	if result, err := tx.ExecContext(ctx, upsertTrackingResult, s.CarrierId, s.Language, s.TrackingNo, s.EventsJson, eventsJsonMd5, 1 /*status*/, datePoint, datePoint, trackingStatus, 1 /*v2*/); err != nil {
+		panic(err)
+	} else if c, err := result.RowsAffected(); err != nil {
 		panic(err)
 	} else {
-		if lastRowId, err := result.LastInsertId(); err != nil {
+		saved = c == 1
+	}
+
+	if saved {
+		deliveryTime_ := sql.NullTime{Time: s.DoneTime, Valid: s.Done}
+		destination_ := sql.NullString{String: s.DonePlace, Valid: s.DonePlace != ""}
+		var trackingId int64
+		if result, err := tx.ExecContext(ctx, upsertTracking, s.CarrierId, int(s.Language), s.TrackingNo, deliveryTime_, destination_, int(s.CollectorType), s.CollectorRealName, datePoint, datePoint, 1 /*status*/); err != nil {
+			panic(err)
+		} else if trackingId, err = result.LastInsertId(); err != nil {
+			panic(err)
+		}
+
+		if _, err := tx.ExecContext(ctx, deleteTrackingDetails, trackingId); err != nil {
 			panic(err)
-		} else {
-			return lastRowId
+		}
+
+		for i := 0; i < len(s.Details); i += maxDetailsPerInsert {
+			end := i + maxDetailsPerInsert
+			if end > len(s.Details) {
+				end = len(s.Details)
+			}
+			insertTrackingDetailsTx(ctx, tx, trackingId, s.Details[i:end], datePoint)
 		}
 	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+	committed = true
+
+	return saved
 }
 
-func DeleteTracking(carrierId int64, language _types.LangId, trackingNo string) int64 {
-	if result, err := db.Exec(deleteTracking, carrierId, int(language), trackingNo); err != nil {
+// 通过一条插入语句保存多个跟踪事件。
+func insertTrackingDetailsTx(ctx context.Context, tx *sql.Tx, infoId int64, details []*TrackingDetailPo, datePoint time.Time) {
+	values := make([]string, 0, len(details))
+	args := make([]interface{}, 0, len(details)*11)
+	for _, d := range details {
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, infoId, d.Date, d.Place, d.Details, d.State, sql.NullInt64{}, sql.NullString{}, sql.NullInt16{}, 1 /*status*/, datePoint, datePoint)
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(insertTrackingDetails, strings.Join(values, ", ")), args...); err != nil {
 		panic(err)
-	} else {
-		if c, err := result.RowsAffected(); err != nil {
-			panic(err)
-		} else {
-			return c
-		}
 	}
 }
@@ -4,33 +4,76 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	_types "com.cne/ai-tracking-search/types"
 	_utils "com.cne/ai-tracking-search/utils"
 )
 
+// 表示一条查询日志。
+type TrackingLogPo struct {
+	ClientId         string                   // 客户端ID。
+	CarrierCode      string                   // 运输商编号，保存时转换为运输商ID和国家ID。
+	TrackingNo       string                   // 运单号。
+	MatchType        int                      // 运输商的匹配方式。
+	Timing           int                      // 查询耗时（毫秒）。
+	Host             string                   // 客户端地址。
+	ResultStatus     int                      // 查询是否成功。
+	StatisticsDate   time.Time                // 统计日期。
+	CollectorType    _types.TrackingResultSrc // 跟踪结果的来源。
+	DatePoint        time.Time                // 创建时间。
+	Creator          string                   // 创建者。
+	RequestTime      time.Time                // 请求时间。
+	CrawlerStartTime time.Time                // 调用查询代理的时间。
+	CrawlerEndTime   time.Time                // 查询代理返回响应的时间。
+	CrawlerRespBody  string                   // 查询代理返回的内容。
+	ResultNote       string                   // 查询结果说明。
+}
+
 const (
-	insertTrackingLog string = `insert into tracking_log (client_id, carrier_id, tracking_no, match_type, country_id, timing, host, result_status, statistics_date, collector_type, status, 
+	insertTrackingLogs string = `insert into tracking_log (client_id, carrier_id, tracking_no, match_type, country_id, timing, host, result_status, statistics_date, collector_type, status,
 		create_time, creator, update_time, modifier, request_time, crawler_req_time, crawler_resp_time, crawler_resp_body, result_note)
-	values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	values %s`
 )
 
-func SaveTrackingLog(clientId string, carrierId int64, trackingNo string, matchType int, countryId int, timing int, host string, resultStatus int, statisticsDate time.Time, collectorType _types.TrackingResultSrc,
-	datePoint time.Time, creator string, requestTime, crawlerStartTime, crawlerEndTime time.Time, crawlerRespBody, resultNote string) int64 {
-	crawlerStartTime_ := sql.NullTime{Time: crawlerStartTime, Valid: !_utils.IsZeroTime(crawlerStartTime)}
-	crawlerEndTime_ := sql.NullTime{Time: crawlerEndTime, Valid: !_utils.IsZeroTime(crawlerEndTime)}
-	collectorType_ := sql.NullInt32{Int32: int32(collectorType), Valid: collectorType != _types.SrcUnknown}
-	if result, err := db.Exec(insertTrackingLog, clientId, carrierId, trackingNo, matchType, countryId, timing, host, resultStatus, statisticsDate, collectorType_, 1 /*status*/, datePoint, creator, datePoint, creator,
-		requestTime, crawlerStartTime_, crawlerEndTime_, crawlerRespBody, resultNote); err != nil {
-		panic(err)
-	} else {
-		if lastRowId, err := result.LastInsertId(); err != nil {
-			panic(err)
-		} else {
-			return lastRowId
+// 通过一条插入语句保存多条查询日志。
+// 没有匹配到运输商的查询日志也会被保存，运输商ID和国家ID是0。
+// ctx 上下文。
+// logs 待保存的查询日志。
+func SaveTrackingLogs(ctx context.Context, logs []*TrackingLogPo) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	carrierCodes := make([]string, 0, len(logs))
+	for _, l := range logs {
+		carrierCodes = append(carrierCodes, l.CarrierCode)
+	}
+	carriers := QueryCarriersByCodes(ctx, carrierCodes)
+
+	values := make([]string, 0, len(logs))
+	args := make([]interface{}, 0, len(logs)*20)
+	for _, l := range logs {
+		carrierId := int64(0)
+		countryId := 0
+		if carrierPo := carriers[l.CarrierCode]; carrierPo != nil {
+			carrierId = carrierPo.Id
+			countryId = carrierPo.CountryId
 		}
+
+		crawlerStartTime_ := sql.NullTime{Time: l.CrawlerStartTime, Valid: !_utils.IsZeroTime(l.CrawlerStartTime)}
+		crawlerEndTime_ := sql.NullTime{Time: l.CrawlerEndTime, Valid: !_utils.IsZeroTime(l.CrawlerEndTime)}
+		collectorType_ := sql.NullInt32{Int32: int32(l.CollectorType), Valid: l.CollectorType != _types.SrcUnknown}
+
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, l.ClientId, carrierId, l.TrackingNo, l.MatchType, countryId, l.Timing, l.Host, l.ResultStatus, l.StatisticsDate, collectorType_, 1 /*status*/, l.DatePoint, l.Creator, l.DatePoint, l.Creator,
+			l.RequestTime, crawlerStartTime_, crawlerEndTime_, l.CrawlerRespBody, l.ResultNote)
 	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(insertTrackingLogs, strings.Join(values, ", ")), args...)
+	return err
 }
@@ -4,15 +4,20 @@
 package db
 
 import (
-	"crypto/md5"
-	"database/sql"
-	"errors"
+	"context"
 	"fmt"
 	"time"
 
 	_types "com.cne/ai-tracking-search/types"
 )
 
+// 表示跟踪结果的唯一标识。
+type TrackingResultKey struct {
+	CarrierCode string        // 运输商编号。
+	Language    _types.LangId // 需要爬取的语言。
+	TrackingNo  string        // 运单号。
+}
+
 // 保存在数据库中的跟踪结果。
 type TrackingResultPo struct {
 	CarrierCode string        // 运输商编号。
@@ -24,70 +29,86 @@ type TrackingResultPo struct {
 }
 
 const (
-	selectTrackingResultByTrackingNo string = `select tr.events_json, tr.update_time, coalesce(tr.tracking_status, -1) = 4 from tracking_result tr
+	selectTrackingResultByTrackingNos string = `select ci.carrier_code, tr.language, tr.tracking_no, tr.events_json, tr.update_time, coalesce(tr.tracking_status, -1) = 4 from tracking_result tr
 	inner join carrier_info ci on ci.id = tr.carrier_id
 	where ci.status = 1
 	  and tr.status = 1
 	  and tr.v2 = 1
-	  and ci.carrier_code = ?
-	  and tr.tracking_no = ?
-	  and tr.language = ?
+	  and ci.carrier_code in (%s)
+	  and tr.tracking_no in (%s)
+	  and tr.language in (%s)
 	  and tr.events_json <> ''
 	  order by tr.update_time
-	  limit 1
 	`
 
-	existsByTrackingNoAndMd5 string = `select exists(select 1 from tracking_result tr where carrier_id = ? and language = ? and tracking_no = ? and md5 = ?)`
-
-	insertTrackingResult string = `insert into tracking_result (carrier_id, language, tracking_no, events_json, md5, status, create_time, update_time, tracking_status, v2)
+	// 相同运输商、语言、运单号和事件JSON的跟踪结果只保存一次，再次保存时只刷新更新时间和妥投状态。
+	// 插入新记录时影响的行数是1，更新已有记录时影响的行数是2。
+	upsertTrackingResult string = `insert into tracking_result (carrier_id, language, tracking_no, events_json, md5, status, create_time, update_time, tracking_status, v2)
 	values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	on duplicate key update update_time = values(update_time), tracking_status = values(tracking_status)
 	`
 )
 
-/*
-ALTER TABLE `aitrack`.`tracking_result`
-ADD COLUMN `v2` tinyint(4) NOT NULL DEFAULT 0 COMMENT '是否v2版本的记录' AFTER `tracking_status`;
-*/
+// 依赖的表结构见迁移脚本`migrations/0002_tracking_result_v2.sql`和`migrations/0006_unique_keys.sql`。
 
-// 根据运输商号码，运单号和查询语言从数据库中查询已存在的爬取结果。
-// 如果不存在符合条件的记录则返回nil。
-func QueryTrackingResultByTrackingNo(carrierCode string, language _types.LangId, trackingNo string) *TrackingResultPo {
-	result := TrackingResultPo{CarrierCode: carrierCode, TrackingNo: trackingNo, Language: language}
-	if err := db.QueryRow(selectTrackingResultByTrackingNo, carrierCode, trackingNo, language).Scan(&result.EventsJson, &result.UpdateTime, &result.Done); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil
-		} else {
-			panic(err)
-		}
-	} else {
-		return &result
+// 批量查询已存在的爬取结果，所有的运单号只需要一次查询。
+// 每个运输商号码，运单号和查询语言的组合只返回一条记录，即按照更新时间排序后的第一条记录。
+// ctx 上下文。
+// keys 待查询的跟踪结果的标识。
+// 返回找到的爬取结果，不存在符合条件的记录的标识不会出现在结果中。
+func QueryTrackingResultsByTrackingNos(ctx context.Context, keys []TrackingResultKey) map[TrackingResultKey]*TrackingResultPo {
+	result := make(map[TrackingResultKey]*TrackingResultPo, len(keys))
+	if len(keys) == 0 {
+		return result
 	}
-}
 
-func SaveTrackingResult(carrierId int64, language _types.LangId, trackingNo, eventsJson string, datePoint time.Time, done bool) int64 {
-	eventsJsonMd5 := fmt.Sprintf("%x", md5.Sum([]byte(eventsJson)))
-	var trackingStatus int
-	if done {
-		trackingStatus = 4 // 已投递。
-	} else {
-		trackingStatus = 1 // 在途。
-	}
-	exists := false
-	if err := db.QueryRow(existsByTrackingNoAndMd5, carrierId, language, trackingNo, eventsJsonMd5).Scan(&exists); err != nil {
-		panic(err)
-	}
-	if exists {
-		// 如果已存在同样的记录，那么放弃保存。
-		return -1
+	wanted := make(map[TrackingResultKey]bool, len(keys))
+	carrierCodes := make([]string, 0, len(keys))
+	trackingNos := make([]string, 0, len(keys))
+	languageArgs := make([]interface{}, 0, 2)
+	for _, key := range keys {
+		wanted[key] = true
+		carrierCodes = append(carrierCodes, key.CarrierCode)
+		trackingNos = append(trackingNos, key.TrackingNo)
+		if !containsLanguage(languageArgs, key.Language) {
+			languageArgs = append(languageArgs, key.Language)
+		}
 	}
 
-	if result, err := db.Exec(insertTrackingResult, carrierId, language, trackingNo, eventsJson, eventsJsonMd5, 1 /*status*/, datePoint, datePoint, trackingStatus, 1 /*v2*/); err != nil {
+	carrierArgs, trackingNoArgs := distinctArgs(carrierCodes), distinctArgs(trackingNos)
+	query := fmt.Sprintf(selectTrackingResultByTrackingNos, placeholders(len(carrierArgs)), placeholders(len(trackingNoArgs)), placeholders(len(languageArgs)))
+	args := append(append(carrierArgs, trackingNoArgs...), languageArgs...)
+	if rows, err := readQuery(ctx, query, args...); err != nil {
 		panic(err)
 	} else {
-		if lastRowId, err := result.LastInsertId(); err != nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			tr := TrackingResultPo{}
+			if err := rows.Scan(&tr.CarrierCode, &tr.Language, &tr.TrackingNo, &tr.EventsJson, &tr.UpdateTime, &tr.Done); err != nil {
+				panic(err)
+			}
+
+			// 运输商号码和运单号分别匹配的记录可能属于其它组合，需要过滤。按照更新时间排序，只保留第一条记录。
+			key := TrackingResultKey{CarrierCode: tr.CarrierCode, Language: tr.Language, TrackingNo: tr.TrackingNo}
+			if _, ok := result[key]; wanted[key] && !ok {
+				result[key] = &tr
+			}
+		}
+		if err := rows.Err(); err != nil {
 			panic(err)
-		} else {
-			return lastRowId
+		}
+
+		return result
+	}
+}
+
+func containsLanguage(args []interface{}, language _types.LangId) bool {
+	for _, arg := range args {
+		if arg == language {
+			return true
 		}
 	}
+
+	return false
 }
@@ -0,0 +1,197 @@
+// 该模块实现了数据库中的跟踪记录的有效期策略。
+// 有效期可以按照运输商、运输商类别、运单状态和优先级配置，决定跟踪记录是直接返回给客户端，还是需要调用查询代理刷新。
+// @Author: agent
+// @Created: 2026-10-18
+package freshness
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_config "com.cne/ai-tracking-search/config"
+	_types "com.cne/ai-tracking-search/types"
+)
+
+// 运单状态。
+type State string
+
+const (
+	StateNone       State = ""           // 数据库中没有跟踪记录。
+	StatePreTransit State = "PreTransit" // 尚无事件。
+	StateInTransit  State = "InTransit"  // 运输途中。
+	StateException  State = "Exception"  // 投递异常。
+	StateDelivered  State = "Delivered"  // 已妥投。
+)
+
+// 表示有效期策略的决定。
+type Decision struct {
+	State       State         // 运单状态。
+	Fresh       bool          // 跟踪记录是否仍然有效，有效时直接返回给客户端。
+	TTL         time.Duration // 生效的有效期，负数表示永不过期。
+	NextRefresh time.Time     // 允许再次调用查询代理的时间，零值表示永不刷新。
+	Rule        string        // 生效的规则，比如`rule#1`或者`default`。
+}
+
+// 表示已解析的有效期规则。
+type rule struct {
+	name        string
+	carrier     string
+	carrierType _types.CarrierType
+	state       State
+	priority    _types.Priority
+	anyPriority bool
+	ttl         time.Duration
+}
+
+// 表示有效期策略。
+// 策略创建之后不再修改，重新加载配置时整体替换。
+type policy struct {
+	defaults map[State]time.Duration // 每种运单状态的默认有效期。
+	rules    []*rule                 // 有效期规则。
+}
+
+var (
+	current atomic.Value // 当前生效的有效期策略，类型是`*policy`。
+)
+
+func init() {
+	current.Store(&policy{
+		defaults: map[State]time.Duration{
+			StatePreTransit: seconds(_config.DefaultFreshnessPreTransit),
+			StateInTransit:  seconds(_config.DefaultFreshnessInTransit),
+			StateException:  seconds(_config.DefaultFreshnessException),
+			StateDelivered:  seconds(_config.DefaultFreshnessDelivered),
+		},
+		rules: make([]*rule, 0),
+	})
+}
+
+// 初始化有效期策略。
+// 可以在运行时再次调用以替换有效期策略，正在进行的决定使用旧的策略。配置有误时不替换。
+// configuration 有效期配置。
+func InitFreshness(configuration *_config.FreshnessConfiguration) error {
+	defaults_ := map[State]time.Duration{
+		StatePreTransit: seconds(configuration.Default.PreTransit),
+		StateInTransit:  seconds(configuration.Default.InTransit),
+		StateException:  seconds(configuration.Default.Exception),
+		StateDelivered:  seconds(configuration.Default.Delivered),
+	}
+
+	rules_ := make([]*rule, 0, len(configuration.Rules))
+	for i, rc := range configuration.Rules {
+		r := rule{name: fmt.Sprintf("rule#%d", i+1), carrier: strings.ToLower(strings.TrimSpace(rc.Carrier)), ttl: seconds(rc.TTL), anyPriority: true}
+
+		if s := strings.TrimSpace(rc.CarrierType); s != "" {
+			if ct, err := _types.ParseCarrierType(s); err != nil {
+				return fmt.Errorf("illegal carrier type of freshness %s: %w", r.name, err)
+			} else {
+				r.carrierType = ct
+			}
+		}
+
+		if s := strings.TrimSpace(rc.State); s != "" {
+			if st, err := ParseState(s); err != nil {
+				return fmt.Errorf("illegal state of freshness %s: %w", r.name, err)
+			} else {
+				r.state = st
+			}
+		}
+
+		if s := strings.TrimSpace(rc.Priority); s != "" {
+			if p, err := parsePriority(s); err != nil {
+				return fmt.Errorf("illegal priority of freshness %s: %w", r.name, err)
+			} else {
+				r.priority = p
+				r.anyPriority = false
+			}
+		}
+
+		rules_ = append(rules_, &r)
+	}
+
+	current.Store(&policy{defaults: defaults_, rules: rules_})
+
+	return nil
+}
+
+// 决定数据库中的跟踪记录是否仍然有效。
+// carrierCode 运输商编号。
+// carrierType 运输商类别，未知时是0。
+// priority 查询的优先级。
+// state 运单状态。如果是`StateNone`，那么总是需要调用查询代理。
+// updateTime 跟踪记录的更新时间。
+// now 当前时间。
+// 返回有效期策略的决定。
+func Decide(carrierCode string, carrierType _types.CarrierType, priority _types.Priority, state State, updateTime time.Time, now time.Time) *Decision {
+	if state == StateNone {
+		return &Decision{State: state, Fresh: false, NextRefresh: now, Rule: "none"}
+	}
+
+	p := current.Load().(*policy)
+	ttl, ruleName := p.defaults[state], "default"
+	for _, r := range p.rules {
+		if r.match(carrierCode, carrierType, priority, state) {
+			ttl, ruleName = r.ttl, r.name
+			break
+		}
+	}
+
+	result := Decision{State: state, TTL: ttl, Rule: ruleName}
+	if ttl < 0 {
+		result.Fresh = true
+	} else {
+		result.NextRefresh = updateTime.Add(ttl)
+		result.Fresh = now.Before(result.NextRefresh)
+	}
+
+	return &result
+}
+
+func (r *rule) match(carrierCode string, carrierType _types.CarrierType, priority _types.Priority, state State) bool {
+	if r.carrier != "" && r.carrier != strings.ToLower(carrierCode) {
+		return false
+	}
+	if r.carrierType != 0 && r.carrierType != carrierType {
+		return false
+	}
+	if r.state != StateNone && r.state != state {
+		return false
+	}
+	if !r.anyPriority && r.priority != priority {
+		return false
+	}
+
+	return true
+}
+
+// 将字符串解析为运单状态。
+// s 待解析的字符串，不区分大小写。
+// 返回解析结果。
+func ParseState(s string) (State, error) {
+	for _, st := range []State{StatePreTransit, StateInTransit, StateException, StateDelivered} {
+		if strings.EqualFold(s, string(st)) {
+			return st, nil
+		}
+	}
+
+	return StateNone, fmt.Errorf("unknown state: %s", s)
+}
+
+func parsePriority(s string) (_types.Priority, error) {
+	for _, p := range []_types.Priority{_types.PriorityHighest, _types.PriorityHigh, _types.PriorityLow} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown priority: %s", s)
+}
+
+func seconds(v int) time.Duration {
+	if v < 0 {
+		return -1
+	}
+	return time.Duration(v) * time.Second
+}
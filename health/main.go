@@ -0,0 +1,150 @@
+// 该模块定义了检查依赖（数据库、Redis、队列、查询代理等）是否可用的方法。
+// 每次检查记录延迟和错误，诊断时可以看到每个依赖最近一次出错的时间和原因，即使当前已经恢复。
+// 检查的结果可以缓存一段时间，频繁的就绪检查和诊断不会给依赖带来额外的压力。
+// @Author: agent
+// @Created: 2026-10-18
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultProbeTimeout time.Duration = 2 * time.Second // 每项检查默认的超时时间。
+	DefaultProbeTTL     time.Duration = 2 * time.Second // 检查结果默认的缓存时间。
+)
+
+// 表示一项检查。
+type Check struct {
+	Name  string                          // 检查的名称，比如`mysql`、`redis`。
+	Probe func(ctx context.Context) error // 执行检查，返回nil表示依赖可用。
+	TTL   time.Duration                   // 检查结果的缓存时间，在此时间内再次检查时返回上一次的结果。0表示不缓存，有副作用的检查不能缓存。
+}
+
+// 表示一项检查的结果。
+type Status struct {
+	Name        string     `json:"name"`                  // 检查的名称。
+	Ok          bool       `json:"ok"`                    // 依赖是否可用。
+	LatencyMs   float64    `json:"latencyMs"`             // 检查的耗时（毫秒）。
+	Error       string     `json:"error,omitempty"`       // 本次检查的错误。
+	CheckedAt   time.Time  `json:"checkedAt"`             // 本次检查的时间。
+	LastError   string     `json:"lastError,omitempty"`   // 最近一次出错的原因，包括之前的检查。
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"` // 最近一次出错的时间。
+}
+
+// 表示一个依赖最近一次出错的记录。
+type lastError struct {
+	err string
+	at  time.Time
+}
+
+// 表示一项检查缓存的结果。
+type cachedStatus struct {
+	lock   sync.Mutex // 同一项检查同时只执行一次，其它调用方等待并使用它的结果。
+	status *Status
+}
+
+var (
+	lastErrorsLock sync.Mutex            // 保护 lastErrors 的同步锁。
+	lastErrors     map[string]*lastError // 每项检查最近一次出错的记录，键是检查的名称。
+
+	cacheLock sync.Mutex               // 保护 cache 的同步锁。
+	cache     map[string]*cachedStatus // 每项检查缓存的结果，键是检查的名称。
+)
+
+func init() {
+	lastErrors = make(map[string]*lastError)
+	cache = make(map[string]*cachedStatus)
+}
+
+// 执行一项检查并记录结果。
+// ctx 上下文。
+// check 检查。
+// timeout 检查的超时时间。
+// 返回检查的结果。检查发生panic时被认为是出错。检查设置了缓存时间时可能返回之前的结果，调用方不能修改。
+func Probe(ctx context.Context, check *Check, timeout time.Duration) *Status {
+	if check.TTL <= 0 {
+		return probe(ctx, check, timeout)
+	}
+
+	cacheLock.Lock()
+	cs, ok := cache[check.Name]
+	if !ok {
+		cs = &cachedStatus{}
+		cache[check.Name] = cs
+	}
+	cacheLock.Unlock()
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if cs.status == nil || time.Since(cs.status.CheckedAt) >= check.TTL {
+		cs.status = probe(ctx, check, timeout)
+	}
+	return cs.status
+}
+
+func probe(ctx context.Context, check *Check, timeout time.Duration) *Status {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := safeProbe(probeCtx, check.Probe)
+	result := &Status{Name: check.Name, Ok: err == nil, LatencyMs: float64(time.Since(start).Microseconds()) / 1000, CheckedAt: start}
+
+	lastErrorsLock.Lock()
+	defer lastErrorsLock.Unlock()
+
+	if err != nil {
+		result.Error = err.Error()
+		lastErrors[check.Name] = &lastError{err: result.Error, at: start}
+	}
+	if le, ok := lastErrors[check.Name]; ok {
+		at := le.at
+		result.LastError, result.LastErrorAt = le.err, &at
+	}
+
+	return result
+}
+
+func safeProbe(ctx context.Context, probe func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return probe(ctx)
+}
+
+// 并发执行多项检查。
+// ctx 上下文。
+// checks 待执行的检查。
+// timeout 每项检查的超时时间。
+// 返回按照名称排序的检查结果，以及是否所有的依赖都可用。
+func ProbeAll(ctx context.Context, checks []*Check, timeout time.Duration) ([]*Status, bool) {
+	result := make([]*Status, len(checks))
+
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *Check) {
+			defer wg.Done()
+			result[i] = Probe(ctx, check, timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	ok := true
+	for _, s := range result {
+		ok = ok && s.Ok
+	}
+
+	return result, ok
+}
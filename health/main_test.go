@@ -0,0 +1,98 @@
+// @Author: agent
+// @Created: 2026-10-18
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeCachesWithinTTL(t *testing.T) {
+	var calls int32
+	check := &Check{Name: "cached", TTL: 50 * time.Millisecond, Probe: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}}
+
+	first := Probe(context.Background(), check, DefaultProbeTimeout)
+	second := Probe(context.Background(), check, DefaultProbeTimeout)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("probe called %d times within TTL, want 1", n)
+	}
+	if first != second {
+		t.Errorf("Probe() within TTL returned a new status")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	Probe(context.Background(), check, DefaultProbeTimeout)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("probe called %d times after TTL, want 2", n)
+	}
+}
+
+func TestProbeWithoutTTL(t *testing.T) {
+	var calls int32
+	check := &Check{Name: "uncached", Probe: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}}
+
+	Probe(context.Background(), check, DefaultProbeTimeout)
+	Probe(context.Background(), check, DefaultProbeTimeout)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("probe called %d times, want 2", n)
+	}
+}
+
+func TestProbeAllCoalescesConcurrentProbes(t *testing.T) {
+	var calls int32
+	probe := func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	checks := make([]*Check, 0)
+	for i := 0; i < 10; i++ {
+		checks = append(checks, &Check{Name: "concurrent", TTL: time.Second, Probe: probe})
+	}
+	if _, ok := ProbeAll(context.Background(), checks, DefaultProbeTimeout); !ok {
+		t.Errorf("ProbeAll() = false, want true")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("probe called %d times, want 1", n)
+	}
+}
+
+func TestProbeKeepsLastError(t *testing.T) {
+	fail := true
+	check := &Check{Name: "flaky", Probe: func(ctx context.Context) error {
+		if fail {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}
+
+	if s := Probe(context.Background(), check, DefaultProbeTimeout); s.Ok || s.Error != "connection refused" {
+		t.Errorf("Probe() = %+v, want failure", s)
+	}
+
+	fail = false
+	s := Probe(context.Background(), check, DefaultProbeTimeout)
+	if !s.Ok || s.Error != "" || s.LastError != "connection refused" || s.LastErrorAt == nil {
+		t.Errorf("Probe() = %+v, want ok with last error", s)
+	}
+}
+
+func TestProbePanic(t *testing.T) {
+	check := &Check{Name: "panicking", Probe: func(ctx context.Context) error {
+		panic("boom")
+	}}
+
+	if s := Probe(context.Background(), check, DefaultProbeTimeout); s.Ok || s.Error != "boom" {
+		t.Errorf("Probe() = %+v, want failure from panic", s)
+	}
+}
@@ -0,0 +1,51 @@
+// 该模块实现了并发数和每秒请求数限制。
+// 限制的后端可以是Redis或者进程内的内存，由配置选择。
+// @Author: agent
+// @Created: 2026-10-18
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// 表示一个限制。
+type Limit struct {
+	Key            string // 限制的键，比如运输商或者查询代理。
+	MaxConcurrency int    // 最大并发数，0表示不限制。
+	MaxRps         int    // 每秒最大请求数，0表示不限制。
+}
+
+// 表示限制的后端。
+type Limiter interface {
+	Acquire(ctx context.Context, token string, lease time.Duration, limits ...Limit) (bool, error)
+	Release(ctx context.Context, token string, limits ...Limit) error
+}
+
+var (
+	limiter Limiter // 当前使用的限制后端。
+)
+
+// 使用指定的限制后端。
+// l 限制后端。
+func Use(l Limiter) {
+	limiter = l
+}
+
+// 尝试获取限制。
+// ctx 上下文。
+// token 本次获取的唯一标记，释放时使用。
+// lease 并发数的租期。如果获取者在租期内没有释放（比如进程崩溃），那么租期结束后自动释放。
+// limits 需要同时获取的限制，忽略所有上限都是0的限制。
+// 返回是否获取成功。如果任一限制达到上限，那么返回false，并且不占用任何限制。
+func Acquire(ctx context.Context, token string, lease time.Duration, limits ...Limit) (bool, error) {
+	return limiter.Acquire(ctx, token, lease, limits...)
+}
+
+// 释放已获取的限制。
+// ctx 上下文。
+// token 获取时使用的唯一标记。
+// limits 获取时使用的限制。
+func Release(ctx context.Context, token string, limits ...Limit) error {
+	return limiter.Release(ctx, token, limits...)
+}
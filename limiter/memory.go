@@ -0,0 +1,92 @@
+// 该模块实现了进程内的内存限制后端。
+// 内存限制只在当前进程中有效，适用于在同一个进程中运行查询接口服务和查询代理工作进程的开发和集成测试环境。
+// @Author: agent
+// @Created: 2026-10-18
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 表示一个限制的当前状态。
+type memoryLimit struct {
+	leases map[string]time.Time // 占用并发数的令牌和租期结束时间。
+	second int64                // 当前秒。
+	count  int                  // 当前秒的请求数。
+}
+
+type memoryLimiter struct {
+	lock   sync.Mutex
+	limits map[string]*memoryLimit
+}
+
+// 初始化内存限制，并作为当前的限制后端。
+func InitMemoryLimiter() error {
+	Use(&memoryLimiter{limits: make(map[string]*memoryLimit)})
+	return nil
+}
+
+// 获取限制的当前状态，并清除过期的租期和计数。调用者必须持有锁。
+func (l *memoryLimiter) limit(key string, now time.Time) *memoryLimit {
+	ml, ok := l.limits[key]
+	if !ok {
+		ml = &memoryLimit{leases: make(map[string]time.Time)}
+		l.limits[key] = ml
+	}
+
+	for token, expireAt := range ml.leases {
+		if !now.Before(expireAt) {
+			delete(ml.leases, token)
+		}
+	}
+	if ml.second != now.Unix() {
+		ml.second = now.Unix()
+		ml.count = 0
+	}
+
+	return ml
+}
+
+func (l *memoryLimiter) Acquire(ctx context.Context, token string, lease time.Duration, limits ...Limit) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	for _, limit := range limits {
+		ml := l.limit(limit.Key, now)
+		if limit.MaxConcurrency > 0 && len(ml.leases) >= limit.MaxConcurrency {
+			return false, nil
+		}
+		if limit.MaxRps > 0 && ml.count >= limit.MaxRps {
+			return false, nil
+		}
+	}
+
+	for _, limit := range limits {
+		ml := l.limit(limit.Key, now)
+		if limit.MaxConcurrency > 0 {
+			ml.leases[token] = now.Add(lease)
+		}
+		if limit.MaxRps > 0 {
+			ml.count++
+		}
+	}
+
+	return true, nil
+}
+
+func (l *memoryLimiter) Release(ctx context.Context, token string, limits ...Limit) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, limit := range limits {
+		if ml, ok := l.limits[limit.Key]; ok {
+			delete(ml.leases, token)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+// 该模块实现了基于Redis的限制后端。
+// 限制保存在Redis中，所以对所有的查询代理工作进程都有效。
+// @Author: agent
+// @Created: 2026-10-18
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	_redisclient "com.cne/ai-tracking-search/redisclient"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	limitKeyPrefix string = "TRACKING_LIMIT" // 缓存中的限制的Key的前缀。
+)
+
+type redisLimiter struct {
+	client redis.UniversalClient
+}
+
+var (
+	// 尝试同时获取多个限制。只有所有的限制都没有达到上限时才会获取成功。
+	// KEYS 每个限制对应两个键：并发数有序集合和当前秒的请求计数。
+	// ARGV[1] 当前时间（毫秒）。ARGV[2] 并发数的租期（毫秒）。ARGV[3] 令牌。之后每个限制对应两个参数：最大并发数和每秒最大请求数。
+	acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local token = ARGV[3]
+local n = #KEYS / 2
+for i = 1, n do
+	local maxConcurrency = tonumber(ARGV[2 + i * 2])
+	local maxRps = tonumber(ARGV[3 + i * 2])
+	if maxConcurrency > 0 then
+		redis.call('ZREMRANGEBYSCORE', KEYS[i * 2 - 1], '-inf', now)
+		if redis.call('ZCARD', KEYS[i * 2 - 1]) >= maxConcurrency then
+			return 0
+		end
+	end
+	if maxRps > 0 then
+		local c = tonumber(redis.call('GET', KEYS[i * 2]) or '0')
+		if c >= maxRps then
+			return 0
+		end
+	end
+end
+for i = 1, n do
+	if tonumber(ARGV[2 + i * 2]) > 0 then
+		redis.call('ZADD', KEYS[i * 2 - 1], now + lease, token)
+		redis.call('PEXPIRE', KEYS[i * 2 - 1], lease)
+	end
+	if tonumber(ARGV[3 + i * 2]) > 0 then
+		redis.call('INCR', KEYS[i * 2])
+		redis.call('PEXPIRE', KEYS[i * 2], 2000)
+	end
+end
+return 1
+`)
+)
+
+// 初始化Redis限制，并作为当前的限制后端。
+// 使用共享的Redis客户端，必须首先初始化共享的Redis客户端。
+func InitRedisLimiter() error {
+	client := _redisclient.Client()
+	if client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	Use(&redisLimiter{client: client})
+	return nil
+}
+
+// 返回限制在Redis中的键。
+// 同时获取多个限制的脚本需要访问所有限制的键，所以集群模式下所有的限制都使用同一个散列标签。
+func limitKey(suffix string) string {
+	return _redisclient.Key(limitKeyPrefix, suffix)
+}
+
+func (l *redisLimiter) Acquire(ctx context.Context, token string, lease time.Duration, limits ...Limit) (bool, error) {
+	now := time.Now()
+
+	keys := make([]string, 0, len(limits)*2)
+	args := []interface{}{now.UnixMilli(), lease.Milliseconds(), token}
+	for _, limit := range limits {
+		if limit.MaxConcurrency <= 0 && limit.MaxRps <= 0 {
+			continue
+		}
+		keys = append(keys, limitKey("$"+limit.Key+"$C"), limitKey("$"+limit.Key+"$R$"+strconv.FormatInt(now.Unix(), 10)))
+		args = append(args, limit.MaxConcurrency, limit.MaxRps)
+	}
+
+	if len(keys) == 0 {
+		return true, nil
+	}
+
+	if r, err := acquireScript.Run(ctx, l.client, keys, args...).Int(); err != nil {
+		return false, err
+	} else {
+		return r == 1, nil
+	}
+}
+
+func (l *redisLimiter) Release(ctx context.Context, token string, limits ...Limit) error {
+	p := l.client.Pipeline()
+
+	for _, limit := range limits {
+		if limit.MaxConcurrency > 0 {
+			p.ZRem(ctx, limitKey("$"+limit.Key+"$C"), token)
+		}
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
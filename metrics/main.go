@@ -0,0 +1,394 @@
+// 该模块实现了简单的运行指标，包括计数器、计量器和直方图。
+// 指标可以按照Prometheus文本格式输出。
+// 没有引入prometheus/client_golang：构建环境只能使用已有的依赖，而且工作协程的利用率等指标需要在接入/metrics之前就开始采集。
+// 这里只实现了用到的指标类型和文本格式，输出和client_golang兼容，以后可以直接替换为client_golang而不影响采集端。
+// @Author: agent
+// @Created: 2026-10-18
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 表示一个可以输出的指标。
+type collector interface {
+	write(w io.Writer, name string)
+}
+
+type metricDesc struct {
+	name   string
+	help   string
+	kind   string // counter、gauge或者histogram。
+	metric collector
+}
+
+var (
+	registryLock sync.Mutex
+	registry     map[string]*metricDesc
+
+	// Prometheus文本格式的标签值只支持转义反斜杠、双引号和换行，其它字符（包括非ASCII字符）原样输出。
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func init() {
+	registry = make(map[string]*metricDesc)
+}
+
+func register(name, help, kind string, metric collector) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Errorf("duplicated metric: %s", name))
+	}
+
+	registry[name] = &metricDesc{name: name, help: help, kind: kind, metric: metric}
+}
+
+// 按照Prometheus文本格式输出所有的指标。
+// w 输出的目标。
+func WriteText(w io.Writer) {
+	registryLock.Lock()
+	descs := make([]*metricDesc, 0, len(registry))
+	for _, d := range registry {
+		descs = append(descs, d)
+	}
+	registryLock.Unlock()
+
+	sort.Slice(descs, func(i, j int) bool { return descs[i].name < descs[j].name })
+
+	for _, d := range descs {
+		fmt.Fprintf(w, "# HELP %s %s\n", d.name, d.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+		d.metric.write(w, d.name)
+	}
+}
+
+// 返回按照Prometheus文本格式输出所有指标的HTTP处理器。
+// collect 输出之前调用，用于更新需要实时查询的指标，比如队列的长度。可以是nil。
+func Handler(collect func(ctx context.Context)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if collect != nil {
+			collect(r.Context())
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// 表示一个浮点数，可以原子地修改。
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		o := atomic.LoadUint64(&f.bits)
+		n := math.Float64bits(math.Float64frombits(o) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, o, n) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// 计数器，只能增加。
+type Counter struct {
+	v atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic(fmt.Errorf("counter cannot decrease: %v", v))
+	}
+	c.v.add(v)
+}
+
+func (c *Counter) Value() float64 {
+	return c.v.get()
+}
+
+func (c *Counter) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(c.Value()))
+}
+
+// 创建并注册计数器。
+func NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	register(name, help, "counter", c)
+	return c
+}
+
+// 计量器，可以增加或者减少。
+type Gauge struct {
+	v atomicFloat
+}
+
+func (g *Gauge) Inc() {
+	g.v.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.v.add(-1)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.v.add(v)
+}
+
+func (g *Gauge) Set(v float64) {
+	g.v.set(v)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.v.get()
+}
+
+func (g *Gauge) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(g.Value()))
+}
+
+// 创建并注册计量器。
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	register(name, help, "gauge", g)
+	return g
+}
+
+// 创建并注册计量器，计量器的值在输出时通过回调函数获取。
+func NewGaugeFunc(name, help string, f func() float64) {
+	register(name, help, "gauge", gaugeFunc(f))
+}
+
+type gaugeFunc func() float64
+
+func (f gaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(f()))
+}
+
+// 直方图，记录观测值的分布。
+type Histogram struct {
+	buckets []float64 // 各个桶的上界，不包含+Inf。
+	counts  []uint64  // 各个桶的计数，最后一个是+Inf。
+	sum     atomicFloat
+	count   uint64
+}
+
+// 默认的直方图桶，适用于以秒为单位的耗时。
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	h.sum.add(v)
+}
+
+// 返回观测值的个数和总和。
+func (h *Histogram) Value() (uint64, float64) {
+	return atomic.LoadUint64(&h.count), h.sum.get()
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.writeWithLabels(w, name, "")
+}
+
+func (h *Histogram) writeWithLabels(w io.Writer, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	cumulative := uint64(0)
+	for i, b := range h.buckets {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(b), cumulative)
+	}
+	cumulative += atomic.LoadUint64(&h.counts[len(h.buckets)])
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, cumulative)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(h.sum.get()))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, atomic.LoadUint64(&h.count))
+}
+
+// 创建并注册直方图。
+// buckets 桶的上界，必须是升序的。为空时使用默认的桶。
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(buckets)
+	register(name, help, "histogram", h)
+	return h
+}
+
+// 带标签的指标集合。
+type vec struct {
+	labelNames []string
+	lock       sync.RWMutex
+	children   map[string]interface{}
+	keys       map[string][]string
+	create     func() interface{}
+}
+
+func newVec(labelNames []string, create func() interface{}) *vec {
+	return &vec{labelNames: labelNames, children: make(map[string]interface{}), keys: make(map[string][]string), create: create}
+}
+
+func (v *vec) with(labelValues []string) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Errorf("expected %d label values, but %d", len(v.labelNames), len(labelValues)))
+	}
+
+	k := strings.Join(labelValues, "\xff")
+
+	v.lock.RLock()
+	c, ok := v.children[k]
+	v.lock.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if c, ok = v.children[k]; !ok {
+		c = v.create()
+		v.children[k] = c
+		v.keys[k] = append([]string{}, labelValues...)
+	}
+
+	return c
+}
+
+func (v *vec) each(f func(labels string, c interface{})) {
+	v.lock.RLock()
+	kk := make([]string, 0, len(v.children))
+	for k := range v.children {
+		kk = append(kk, k)
+	}
+	v.lock.RUnlock()
+
+	sort.Strings(kk)
+
+	for _, k := range kk {
+		v.lock.RLock()
+		c := v.children[k]
+		values := v.keys[k]
+		v.lock.RUnlock()
+
+		pairs := make([]string, 0, len(values))
+		for i, lv := range values {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", v.labelNames[i], labelValueEscaper.Replace(lv)))
+		}
+		f(strings.Join(pairs, ","), c)
+	}
+}
+
+// 带标签的计数器集合。
+type CounterVec struct {
+	v *vec
+}
+
+// 获取标签值对应的计数器，如果不存在则创建。
+func (cv *CounterVec) With(labelValues ...string) *Counter {
+	return cv.v.with(labelValues).(*Counter)
+}
+
+func (cv *CounterVec) write(w io.Writer, name string) {
+	cv.v.each(func(labels string, c interface{}) {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(c.(*Counter).Value()))
+	})
+}
+
+// 创建并注册带标签的计数器集合。
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	cv := &CounterVec{v: newVec(labelNames, func() interface{} { return &Counter{} })}
+	register(name, help, "counter", cv)
+	return cv
+}
+
+// 带标签的计量器集合。
+type GaugeVec struct {
+	v *vec
+}
+
+// 获取标签值对应的计量器，如果不存在则创建。
+func (gv *GaugeVec) With(labelValues ...string) *Gauge {
+	return gv.v.with(labelValues).(*Gauge)
+}
+
+func (gv *GaugeVec) write(w io.Writer, name string) {
+	gv.v.each(func(labels string, c interface{}) {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(c.(*Gauge).Value()))
+	})
+}
+
+// 创建并注册带标签的计量器集合。
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	gv := &GaugeVec{v: newVec(labelNames, func() interface{} { return &Gauge{} })}
+	register(name, help, "gauge", gv)
+	return gv
+}
+
+// 带标签的直方图集合。
+type HistogramVec struct {
+	v *vec
+}
+
+// 获取标签值对应的直方图，如果不存在则创建。
+func (hv *HistogramVec) With(labelValues ...string) *Histogram {
+	return hv.v.with(labelValues).(*Histogram)
+}
+
+func (hv *HistogramVec) write(w io.Writer, name string) {
+	hv.v.each(func(labels string, c interface{}) {
+		c.(*Histogram).writeWithLabels(w, name, labels)
+	})
+}
+
+// 创建并注册带标签的直方图集合。
+// buckets 桶的上界，必须是升序的。为空时使用默认的桶。
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	hv := &HistogramVec{v: newVec(labelNames, func() interface{} { return newHistogram(buckets) })}
+	register(name, help, "histogram", hv)
+	return hv
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	} else if math.IsInf(v, -1) {
+		return "-Inf"
+	} else if math.IsNaN(v) {
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
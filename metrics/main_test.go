@@ -0,0 +1,90 @@
+// @Author: agent
+// @Created: 2026-10-18
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func writeText(t *testing.T) string {
+	t.Helper()
+
+	buf := bytes.Buffer{}
+	WriteText(&buf)
+	return buf.String()
+}
+
+func assertLines(t *testing.T, text string, lines ...string) {
+	t.Helper()
+
+	all := make(map[string]bool)
+	for _, l := range strings.Split(text, "\n") {
+		all[l] = true
+	}
+	for _, l := range lines {
+		if !all[l] {
+			t.Errorf("missing line: %s\n%s", l, text)
+		}
+	}
+}
+
+func TestWriteTextScalars(t *testing.T) {
+	c := NewCounter("test_scalar_total", "Test counter.")
+	c.Inc()
+	c.Add(1.5)
+	g := NewGauge("test_scalar_gauge", "Test gauge.")
+	g.Set(-3)
+	NewGaugeFunc("test_scalar_func", "Test gauge func.", func() float64 { return 42 })
+
+	assertLines(t, writeText(t),
+		"# HELP test_scalar_total Test counter.",
+		"# TYPE test_scalar_total counter",
+		"test_scalar_total 2.5",
+		"# TYPE test_scalar_gauge gauge",
+		"test_scalar_gauge -3",
+		"test_scalar_func 42",
+	)
+}
+
+func TestWriteTextLabels(t *testing.T) {
+	cv := NewCounterVec("test_labels_total", "Test counter vec.", "carrier", "agent")
+	cv.With("C1", "crawler").Inc()
+	cv.With(`a\b"c`+"\nd", "中文").Add(2)
+
+	// 只转义反斜杠、双引号和换行，其它字符（包括非ASCII字符）原样输出。
+	assertLines(t, writeText(t),
+		`test_labels_total{carrier="C1",agent="crawler"} 1`,
+		`test_labels_total{carrier="a\\b\"c\nd",agent="中文"} 2`,
+	)
+
+	gv := NewGaugeVec("test_labels_gauge", "Test gauge vec.", "priority")
+	gv.With("Low").Set(3)
+	assertLines(t, writeText(t), `test_labels_gauge{priority="Low"} 3`)
+}
+
+func TestWriteTextHistogram(t *testing.T) {
+	h := NewHistogram("test_histogram_seconds", "Test histogram.", []float64{.5, 1})
+	h.Observe(.2)
+	h.Observe(.5)
+	h.Observe(3)
+
+	hv := NewHistogramVec("test_histogram_vec_seconds", "Test histogram vec.", []float64{1}, "carrier")
+	hv.With(`x"y`).Observe(.5)
+	hv.With(`x"y`).Observe(2)
+
+	assertLines(t, writeText(t),
+		"# TYPE test_histogram_seconds histogram",
+		`test_histogram_seconds_bucket{le="0.5"} 2`,
+		`test_histogram_seconds_bucket{le="1"} 2`,
+		`test_histogram_seconds_bucket{le="+Inf"} 3`,
+		"test_histogram_seconds_sum 3.7",
+		"test_histogram_seconds_count 3",
+		"# TYPE test_histogram_vec_seconds histogram",
+		`test_histogram_vec_seconds_bucket{carrier="x\"y",le="1"} 1`,
+		`test_histogram_vec_seconds_bucket{carrier="x\"y",le="+Inf"} 2`,
+		`test_histogram_vec_seconds_sum{carrier="x\"y"} 2.5`,
+		`test_histogram_vec_seconds_count{carrier="x\"y"} 2`,
+	)
+}
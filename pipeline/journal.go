@@ -0,0 +1,184 @@
+// 该模块实现了持久化管道的磁盘日志。
+// 日志文件中每行是一条JSON格式的记录。重放时首先将日志文件改名，之后溢出的记录写入新的日志文件，互不影响。
+// @Author: agent
+// @Created: 2026-10-18
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type journal struct {
+	lock       sync.Mutex
+	path       string // 正在追加的日志文件。
+	replayPath string // 正在重放的日志文件。
+}
+
+// 打开日志文件所在的目录，目录不存在时创建。
+// dir 日志文件所在的目录，相对路径相对于进程的工作目录，被解析为绝对路径，之后改变工作目录不会影响日志文件的位置。
+// name 日志文件的名字。
+func openJournal(dir, name string) (*journal, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, name+".journal")
+	return &journal{path: path, replayPath: path + ".replay"}, nil
+}
+
+// 追加记录到日志文件。
+// lines 待追加的记录。
+// maxBytes 日志文件的最大字节数，0表示不限制。
+// 返回追加之后日志文件的总字节数。
+func (j *journal) append(lines [][]byte, maxBytes int64) (int64, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	size := j.size()
+	if maxBytes > 0 && size+int64(buf.Len()) > maxBytes {
+		return size, fmt.Errorf("journal is full(%d bytes)", size)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return size, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return size, err
+	}
+	if err := f.Sync(); err != nil {
+		return size, err
+	}
+
+	return size + int64(buf.Len()), nil
+}
+
+// 返回日志文件的总字节数，包括正在重放的日志文件。
+func (j *journal) size() int64 {
+	var result int64
+	for _, path := range []string{j.path, j.replayPath} {
+		if fi, err := os.Stat(path); err == nil {
+			result += fi.Size()
+		}
+	}
+
+	return result
+}
+
+// 重放日志文件中的记录。
+// 写入失败时，尚未写入的记录保留在正在重放的日志文件中，下次继续重放。
+// batchSize 每批写入的最多记录数。
+// write 批量写入记录的方法。
+// 返回已重放的记录数。
+func (j *journal) replay(batchSize int, write func(lines [][]byte) error) (int, error) {
+	j.lock.Lock()
+	if _, err := os.Stat(j.replayPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(j.path, j.replayPath); err != nil {
+			j.lock.Unlock()
+			if errors.Is(err, os.ErrNotExist) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+	j.lock.Unlock()
+
+	f, err := os.Open(j.replayPath)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	r := bufio.NewReader(f)
+	batch := make([][]byte, 0, batchSize)
+	for eof := false; !eof; {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			f.Close()
+			return replayed, err
+		}
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			batch = append(batch, line)
+		}
+
+		if len(batch) == 0 || (len(batch) < batchSize && !eof) {
+			continue
+		}
+
+		if err := write(batch); err != nil {
+			// 保留尚未写入的记录。
+			keepErr := j.keep(batch, r)
+			f.Close()
+			if keepErr != nil {
+				return replayed, keepErr
+			}
+			return replayed, err
+		}
+
+		replayed += len(batch)
+		batch = make([][]byte, 0, batchSize)
+	}
+
+	f.Close()
+	return replayed, os.Remove(j.replayPath)
+}
+
+// 将尚未写入的记录写回正在重放的日志文件。
+// 剩余的内容直接从正在重放的日志文件复制到临时文件，不需要全部读入内存，完成后替换正在重放的日志文件。
+// batch 写入失败的一批记录。
+// rest 正在重放的日志文件中剩余的内容。
+func (j *journal) keep(batch [][]byte, rest io.Reader) error {
+	tmpPath := j.replayPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, line := range batch {
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if _, err := io.Copy(w, rest); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, j.replayPath)
+}
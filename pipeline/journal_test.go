@@ -0,0 +1,142 @@
+// @Author: agent
+// @Created: 2026-10-18
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func testLines(from, to int) [][]byte {
+	result := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		result = append(result, []byte(strconv.Itoa(i)))
+	}
+
+	return result
+}
+
+func TestJournalAppend(t *testing.T) {
+	dir := t.TempDir()
+	j, err := openJournal(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size, err := j.append(testLines(0, 3), 0); err != nil || size != 6 {
+		t.Fatalf("append = %d, %v, want 6", size, err)
+	}
+
+	// 超过最大字节数时不追加任何记录。
+	if size, err := j.append(testLines(3, 6), 10); err == nil || size != 6 {
+		t.Errorf("append to full journal = %d, %v, want error", size, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "test.journal")); string(data) != "0\n1\n2\n" {
+		t.Errorf("journal = %q", data)
+	}
+}
+
+func TestJournalRelativeDir(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	dir := t.TempDir()
+	os.Chdir(dir)
+	j, err := openJournal("journal", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 相对路径被解析为绝对路径，之后改变工作目录不影响日志文件的位置。
+	os.Chdir(wd)
+	if _, err := j.append(testLines(0, 1), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "journal", "test.journal")); err != nil {
+		t.Errorf("journal not in %s: %v", dir, err)
+	}
+}
+
+func TestJournalReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openJournal(dir, "test")
+	j.append(testLines(0, 5), 0)
+
+	// 重新打开日志文件，模拟进程重启。
+	j, err := openJournal(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := make([][]byte, 0)
+	replayed, err := j.replay(2, func(lines [][]byte) error {
+		// 重放期间溢出的记录写入新的日志文件，不影响正在重放的日志文件。
+		j.append(testLines(5, 6), 0)
+		written = append(written, lines...)
+		return nil
+	})
+	if err != nil || replayed != 5 {
+		t.Fatalf("replay = %d, %v, want 5", replayed, err)
+	}
+	if !reflect.DeepEqual(written, testLines(0, 5)) {
+		t.Errorf("written = %q", written)
+	}
+	if _, err := os.Stat(j.replayPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("replay file still exists: %v", err)
+	}
+	if data, _ := os.ReadFile(j.path); string(data) != "5\n5\n5\n" {
+		t.Errorf("journal = %q", data)
+	}
+}
+
+func TestJournalPartialReplayFailure(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openJournal(dir, "test")
+	j.append(testLines(0, 7), 0)
+
+	// 第二批写入失败，这一批和之后的记录保留在正在重放的日志文件中。
+	failure := errors.New("db down")
+	calls := 0
+	replayed, err := j.replay(2, func(lines [][]byte) error {
+		calls++
+		if calls == 2 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) || replayed != 2 {
+		t.Fatalf("replay = %d, %v, want 2 and failure", replayed, err)
+	}
+	if data, _ := os.ReadFile(j.replayPath); string(data) != "2\n3\n4\n5\n6\n" {
+		t.Errorf("replay file = %q", data)
+	}
+	if _, err := os.Stat(j.replayPath + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file still exists: %v", err)
+	}
+
+	// 之后溢出的记录写入新的日志文件，下次重放首先写完正在重放的日志文件。
+	j.append(testLines(7, 8), 0)
+	if size := j.size(); size != 12 {
+		t.Errorf("size = %d, want 12", size)
+	}
+
+	written := make([][]byte, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := j.replay(2, func(lines [][]byte) error {
+			written = append(written, lines...)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(written, testLines(2, 8)) {
+		t.Errorf("written = %q", written)
+	}
+	if size := j.size(); size != 0 {
+		t.Errorf("size after replay = %d, want 0", size)
+	}
+}
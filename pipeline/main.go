@@ -0,0 +1,315 @@
+// 该模块实现了有界的异步持久化管道。
+// 待保存的记录首先进入有界的内存队列，由固定数量的写入协程批量写入数据库。
+// 数据库不可用或者内存队列已满时，记录被溢出到磁盘上的日志文件，数据库恢复后重放。
+// @Author: agent
+// @Created: 2026-10-18
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_metrics "com.cne/ai-tracking-search/metrics"
+)
+
+// 批量写入记录的方法。返回错误表示这一批记录都没有写入，会被溢出到磁盘。
+type WriteFunc func(items []interface{}) error
+
+// 从日志文件中解析一条记录的方法。
+type DecodeFunc func(data []byte) (interface{}, error)
+
+// 表示持久化管道的选项。
+type Options struct {
+	QueueSize       int           // 内存队列的容量。
+	BatchSize       int           // 每批写入的最多记录数。
+	FlushInterval   time.Duration // 不足一批的记录最多等待此时间后写入。
+	Writers         int           // 写入协程数。
+	JournalDir      string        // 日志文件所在的目录，相对路径相对于进程的工作目录。空字符串表示不溢出到磁盘，无法写入的记录被丢弃。
+	JournalMaxBytes int64         // 日志文件的最大字节数，超过后溢出的记录被丢弃。
+	ReplayInterval  time.Duration // 重放日志文件的间隔。
+}
+
+// 表示一个持久化管道。
+type Pipeline struct {
+	name    string
+	options Options
+	write   WriteFunc
+	decode  DecodeFunc
+	items   chan interface{}
+	journal *journal
+
+	degraded int32 // 数据库是否不可用。不可用时写入协程直接溢出到磁盘，直到重放成功。
+
+	closed  int32          // 是否已关闭。关闭之后提交的记录直接溢出到磁盘。
+	stop    chan struct{}  // 关闭时被关闭的通道，通知写入协程写完内存队列中的记录后结束。
+	writers sync.WaitGroup // 所有的写入协程。
+}
+
+var (
+	persistQueueDepth   *_metrics.GaugeVec   // 内存队列中等待写入的记录数。
+	persistWritten      *_metrics.CounterVec // 已写入数据库的记录数。
+	persistSpilled      *_metrics.CounterVec // 溢出到磁盘的记录数。
+	persistReplayed     *_metrics.CounterVec // 从磁盘重放并写入数据库的记录数。
+	persistDropped      *_metrics.CounterVec // 被丢弃的记录数。
+	persistJournalBytes *_metrics.GaugeVec   // 日志文件的字节数。
+)
+
+func init() {
+	persistQueueDepth = _metrics.NewGaugeVec("tracking_persist_queue_depth", "Number of records waiting in the persistence queue.", "pipeline")
+	persistWritten = _metrics.NewCounterVec("tracking_persist_written_total", "Number of records written to the database.", "pipeline")
+	persistSpilled = _metrics.NewCounterVec("tracking_persist_spilled_total", "Number of records spilled to the on-disk journal.", "pipeline")
+	persistReplayed = _metrics.NewCounterVec("tracking_persist_replayed_total", "Number of records replayed from the on-disk journal.", "pipeline")
+	persistDropped = _metrics.NewCounterVec("tracking_persist_dropped_total", "Number of records dropped without being written.", "pipeline", "reason")
+	persistJournalBytes = _metrics.NewGaugeVec("tracking_persist_journal_bytes", "Size in bytes of the on-disk journal.", "pipeline")
+}
+
+// 创建并启动持久化管道。如果日志文件中有上次未写入的记录，那么在后台重放。
+// name 管道的名字，也是日志文件的名字。
+// options 管道的选项。
+// write 批量写入记录的方法。
+// decode 从日志文件中解析记录的方法。
+func New(name string, options Options, write WriteFunc, decode DecodeFunc) (*Pipeline, error) {
+	if options.QueueSize <= 0 {
+		return nil, fmt.Errorf("queue size should be positive, but %d", options.QueueSize)
+	} else if options.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size should be positive, but %d", options.BatchSize)
+	} else if options.FlushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval should be positive, but %s", options.FlushInterval)
+	} else if options.Writers <= 0 {
+		return nil, fmt.Errorf("writers should be positive, but %d", options.Writers)
+	} else if options.JournalDir != "" && options.ReplayInterval <= 0 {
+		return nil, fmt.Errorf("replay interval should be positive, but %s", options.ReplayInterval)
+	}
+
+	p := &Pipeline{name: name, options: options, write: write, decode: decode, items: make(chan interface{}, options.QueueSize), stop: make(chan struct{})}
+	if options.JournalDir != "" {
+		if j, err := openJournal(options.JournalDir, name); err != nil {
+			return nil, err
+		} else {
+			p.journal = j
+			log.Printf("[INFO] Journal of pipeline %s: %s\n", name, j.path)
+		}
+
+		go p.replayForEver()
+	}
+
+	for i := 0; i < options.Writers; i++ {
+		p.writers.Add(1)
+		go p.writeForEver()
+	}
+
+	return p, nil
+}
+
+// 提交待保存的记录，不会阻塞。
+// 内存队列已满时记录被溢出到磁盘，如果无法溢出那么被丢弃。
+// item 待保存的记录，必须可以序列化为JSON。
+func (p *Pipeline) Submit(item interface{}) {
+	if atomic.LoadInt32(&p.closed) != 0 {
+		p.spill([]interface{}{item}, "closed")
+		return
+	}
+
+	select {
+	case p.items <- item:
+		persistQueueDepth.With(p.name).Set(float64(len(p.items)))
+	default:
+		p.spill([]interface{}{item}, "queue full")
+	}
+}
+
+// 返回内存队列中等待写入的记录数。
+func (p *Pipeline) Depth() int {
+	return len(p.items)
+}
+
+// 关闭管道，等待内存队列中的记录写入完毕。
+// ctx 上下文，决定了等待的截止时间。
+// 超过截止时间仍未写入的记录被溢出到磁盘，下次启动时重放。
+func (p *Pipeline) Close(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		return nil
+	}
+	close(p.stop)
+
+	stopped := make(chan struct{})
+	go func() {
+		p.writers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		// 写入协程没有及时结束，内存队列中剩余的记录溢出到磁盘。正在写入的记录无法挽回。
+		if remaining := p.drain(); len(remaining) != 0 {
+			p.spill(remaining, "closed")
+		}
+		return fmt.Errorf("cannot flush pipeline %s: %w", p.name, ctx.Err())
+	}
+}
+
+// 取出内存队列中所有的记录，不会阻塞。
+func (p *Pipeline) drain() []interface{} {
+	result := make([]interface{}, 0, len(p.items))
+	for {
+		select {
+		case item := <-p.items:
+			result = append(result, item)
+		default:
+			persistQueueDepth.With(p.name).Set(0)
+			return result
+		}
+	}
+}
+
+func (p *Pipeline) writeForEver() {
+	defer p.writers.Done()
+
+	batch := make([]interface{}, 0, p.options.BatchSize)
+	timer := time.NewTimer(p.options.FlushInterval)
+
+	for {
+		select {
+		case <-p.stop:
+			// 写完当前的批量和内存队列中剩余的记录后结束。
+			timer.Stop()
+			batch = append(batch, p.drain()...)
+			for len(batch) > 0 {
+				n := p.options.BatchSize
+				if n > len(batch) {
+					n = len(batch)
+				}
+				p.writeBatch(batch[:n])
+				batch = batch[n:]
+			}
+			return
+		case item := <-p.items:
+			batch = append(batch, item)
+			if len(batch) < p.options.BatchSize {
+				continue
+			}
+		case <-timer.C:
+			timer.Reset(p.options.FlushInterval)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		persistQueueDepth.With(p.name).Set(float64(len(p.items)))
+		p.writeBatch(batch)
+		batch = make([]interface{}, 0, p.options.BatchSize)
+	}
+}
+
+// 写入一批记录，失败时溢出到磁盘。
+func (p *Pipeline) writeBatch(batch []interface{}) {
+	if atomic.LoadInt32(&p.degraded) != 0 {
+		p.spill(batch, "degraded")
+		return
+	}
+
+	if err := p.safeWrite(batch); err != nil {
+		log.Printf("[WARN] Cannot write %d records of pipeline %s. cause=%s\n", len(batch), p.name, err)
+		if p.journal != nil {
+			atomic.StoreInt32(&p.degraded, 1)
+		}
+		p.spill(batch, "write failed")
+	} else {
+		persistWritten.With(p.name).Add(float64(len(batch)))
+	}
+}
+
+// 调用写入方法，将写入方法中的panic转换为错误。
+func (p *Pipeline) safeWrite(batch []interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return p.write(batch)
+}
+
+// 将记录溢出到磁盘，无法溢出时丢弃。
+// reason 溢出的原因，用于记录被丢弃的原因。
+func (p *Pipeline) spill(batch []interface{}, reason string) {
+	if p.journal == nil {
+		persistDropped.With(p.name, reason).Add(float64(len(batch)))
+		return
+	}
+
+	lines := make([][]byte, 0, len(batch))
+	for _, item := range batch {
+		if data, err := json.Marshal(item); err != nil {
+			log.Printf("[WARN] Cannot serialize record of pipeline %s. cause=%s\n", p.name, err)
+			persistDropped.With(p.name, "serialize failed").Inc()
+		} else {
+			lines = append(lines, data)
+		}
+	}
+
+	if size, err := p.journal.append(lines, p.options.JournalMaxBytes); err != nil {
+		log.Printf("[ERROR] Cannot spill %d records of pipeline %s. cause=%s\n", len(lines), p.name, err)
+		persistDropped.With(p.name, "journal failed").Add(float64(len(lines)))
+	} else {
+		persistSpilled.With(p.name).Add(float64(len(lines)))
+		persistJournalBytes.With(p.name).Set(float64(size))
+	}
+}
+
+func (p *Pipeline) replayForEver() {
+	for {
+		p.replay()
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(p.options.ReplayInterval):
+		}
+	}
+}
+
+// 重放日志文件中的记录。全部写入成功后恢复正常写入。
+func (p *Pipeline) replay() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] Cannot replay journal of pipeline %s. cause=%v\n", p.name, r)
+		}
+	}()
+
+	replayed, err := p.journal.replay(p.options.BatchSize, func(lines [][]byte) error {
+		batch := make([]interface{}, 0, len(lines))
+		for _, line := range lines {
+			if item, err := p.decode(line); err != nil {
+				log.Printf("[WARN] Cannot parse journal record of pipeline %s. cause=%s\n", p.name, err)
+				persistDropped.With(p.name, "decode failed").Inc()
+			} else {
+				batch = append(batch, item)
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		return p.safeWrite(batch)
+	})
+
+	persistReplayed.With(p.name).Add(float64(replayed))
+	persistJournalBytes.With(p.name).Set(float64(p.journal.size()))
+
+	if err != nil {
+		log.Printf("[WARN] Cannot replay journal of pipeline %s, %d records replayed. cause=%s\n", p.name, replayed, err)
+	} else {
+		if replayed > 0 {
+			log.Printf("[INFO] Replayed %d records of pipeline %s\n", replayed, p.name)
+		}
+		atomic.StoreInt32(&p.degraded, 0)
+	}
+}
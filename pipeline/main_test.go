@@ -0,0 +1,125 @@
+// @Author: agent
+// @Created: 2026-10-18
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟数据库的写入方法，可以切换为失败。
+type testStore struct {
+	lock    sync.Mutex
+	failing int32
+	written []int
+}
+
+func (s *testStore) write(items []interface{}) error {
+	if atomic.LoadInt32(&s.failing) != 0 {
+		return errors.New("db down")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, item := range items {
+		s.written = append(s.written, item.(int))
+	}
+	return nil
+}
+
+func (s *testStore) sorted() []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := append([]int{}, s.written...)
+	sort.Ints(result)
+	return result
+}
+
+func decodeInt(data []byte) (interface{}, error) {
+	var v int
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func newTestPipeline(t *testing.T, dir string, store *testStore) *Pipeline {
+	t.Helper()
+
+	options := Options{QueueSize: 10, BatchSize: 2, FlushInterval: 5 * time.Millisecond, Writers: 1, JournalDir: dir, ReplayInterval: 20 * time.Millisecond}
+	p, err := New("test", options, store.write, decodeInt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+func TestPipelineDegradedSpill(t *testing.T) {
+	store := &testStore{failing: 1}
+	p := newTestPipeline(t, t.TempDir(), store)
+	defer p.Close(context.Background())
+
+	// 写入失败后转为降级状态，之后的记录直接溢出到磁盘。
+	p.Submit(1)
+	p.Submit(2)
+	waitFor(t, "degraded", func() bool { return atomic.LoadInt32(&p.degraded) != 0 })
+	p.Submit(3)
+	p.Submit(4)
+	waitFor(t, "spill", func() bool { return p.journal.size() == 8 })
+
+	// 数据库恢复后重放磁盘日志，全部写入成功后恢复正常写入。
+	atomic.StoreInt32(&store.failing, 0)
+	waitFor(t, "replay", func() bool { return atomic.LoadInt32(&p.degraded) == 0 && p.journal.size() == 0 })
+	p.Submit(5)
+	p.Submit(6)
+	waitFor(t, "write", func() bool { return len(store.sorted()) == 6 })
+
+	if got := store.sorted(); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("written = %v", got)
+	}
+}
+
+func TestPipelineReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// 数据库一直不可用，关闭时记录都在磁盘日志中。
+	store := &testStore{failing: 1}
+	p := newTestPipeline(t, dir, store)
+	for i := 1; i <= 5; i++ {
+		p.Submit(i)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if size := p.journal.size(); size != 10 {
+		t.Fatalf("journal size = %d, want 10", size)
+	}
+
+	// 重启之后立刻重放上次未写入的记录。
+	store = &testStore{}
+	p = newTestPipeline(t, dir, store)
+	defer p.Close(context.Background())
+
+	waitFor(t, "replay", func() bool { return len(store.sorted()) == 5 })
+	if got := store.sorted(); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("written = %v", got)
+	}
+}
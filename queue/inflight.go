@@ -0,0 +1,98 @@
+// 该模块定义了正在处理的消息的集合。
+// 进程退出时首先停止出队，等待正在处理的消息处理完毕；超过截止时间仍未处理完毕的消息被放回队列，由其它进程立刻处理，不必等待可见性超时。
+// @Author: agent
+// @Created: 2026-10-18
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	inflightCheckInterval time.Duration = 100 * time.Millisecond // 等待消息处理完毕时检查的间隔。
+)
+
+// 表示正在处理的消息的集合。
+type Inflight struct {
+	lock     sync.Mutex
+	messages map[*Message]struct{}
+}
+
+// 创建正在处理的消息的集合。
+func NewInflight() *Inflight {
+	return &Inflight{messages: make(map[*Message]struct{})}
+}
+
+// 开始处理消息。
+// m 出队的消息。
+func (f *Inflight) Add(m *Message) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.messages[m] = struct{}{}
+}
+
+// 消息已经处理完毕，或者已经确认、放回队列。
+// m 出队的消息。
+func (f *Inflight) Done(m *Message) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.messages, m)
+}
+
+// 返回正在处理的消息数。
+func (f *Inflight) Len() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return len(f.messages)
+}
+
+// 等待所有的消息处理完毕。
+// ctx 上下文，决定了等待的截止时间。
+// 返回是否所有的消息都已处理完毕。
+func (f *Inflight) Wait(ctx context.Context) bool {
+	ticker := time.NewTicker(inflightCheckInterval)
+	defer ticker.Stop()
+
+	for f.Len() != 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	return true
+}
+
+// 将所有尚未处理完毕的消息放回队列，不增加投递次数。
+// 如果处理过程随后完成并确认了消息，那么放回队列的消息会被再次处理，所以消息的处理应当是幂等的。
+// ctx 上下文。
+// 返回放回队列的消息数，以及第一个放回失败的错误。放回失败的消息会在可见性超时之后被重新投递。
+func (f *Inflight) NackAll(ctx context.Context) (int, error) {
+	f.lock.Lock()
+	messages := make([]*Message, 0, len(f.messages))
+	for m := range f.messages {
+		messages = append(messages, m)
+	}
+	f.messages = make(map[*Message]struct{})
+	f.lock.Unlock()
+
+	var result error
+	nacked := 0
+	for _, m := range messages {
+		if err := Nack(ctx, m); err != nil {
+			if result == nil {
+				result = err
+			}
+		} else {
+			nacked++
+		}
+	}
+
+	return nacked, result
+}
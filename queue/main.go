@@ -1,83 +1,140 @@
-// 该模块实现了消息队列。
+// 该模块实现了可靠的消息队列。
+// 出队的消息在确认之前一直处于待处理状态，超过可见性超时仍未确认的消息会被重新投递，多次投递仍然失败的消息会被转移到死信队列。
+// 队列的后端可以是Redis或者进程内的内存，由配置选择。
+// 所有的方法都接受上下文，上下文被取消或者超时后，正在进行的操作会尽快返回上下文的错误。
 // @Author: Haart
 // @Created: 2021-10-27
 package queue
 
 import (
 	"context"
-	"fmt"
-	"strconv"
+	"errors"
+	"time"
+)
+
+// 表示一条出队的消息。
+type Message struct {
+	Topic      string    // 主题。
+	Id         string    // 消息在队列中的ID。
+	Value      string    // 消息的值。
+	Deliveries int       // 此前已经投递的次数，第一次出队时是0。
+	Time       time.Time // 消息第一次入队的时间，放回队列时保持不变。
+}
+
+// 表示队列的后端。
+type Queue interface {
+	Declare(ctx context.Context, topics ...string) error
+	Length(ctx context.Context, topic string) (int64, error)
+	Push(ctx context.Context, topic string, value string) (string, error)
+	Peek(ctx context.Context, topic string) (*Message, error)
+	BPop(ctx context.Context, timeout time.Duration, topics ...string) (*Message, error)
+	Ack(ctx context.Context, m *Message) error
+	Nack(ctx context.Context, m *Message) error
+	Fail(ctx context.Context, m *Message, reason string, maxDeliveries int) (bool, error)
+	Reclaim(ctx context.Context, topic string, visibilityTimeout time.Duration, maxDeliveries int) (int, int, error)
+	Leave(ctx context.Context) error
+}
 
-	"github.com/go-redis/redis/v8"
+const (
+	deadLetterTopic string = "TRACKING_QUEUE$DeadLetter" // 死信队列的主题。
 )
 
 var (
-	redisHost     string
-	redisPort     int
-	redisPassword string
-	redisDB       int
+	// 队列为空或者出队超时。
+	Nil = errors.New("queue: nil")
 
-	redisClient *redis.Client
-	redisCtx    context.Context
+	queue Queue // 当前使用的队列后端。
 )
 
-func init() {
-	redisCtx = context.Background()
+// 使用指定的队列后端。
+// q 队列后端。
+func Use(q Queue) {
+	queue = q
 }
 
-// 初始化Redis队列配置。
-// host Redis主机。
-// port Redis端口号。
-// password Redis口令。
-// db Redis队列使用的数据库。
-func InitRedisQueue(host string, port int, password string, db int) error {
-	redisHost = host
-	redisPort = port
-	redisPassword = password
-	redisDB = db
-
-	client := redis.NewClient(&redis.Options{
-		Addr:     redisHost + ":" + strconv.Itoa(redisPort),
-		Password: redisPassword,
-		DB:       redisDB,
-	})
-	if client == nil {
-		return fmt.Errorf("cannot create redis client")
-	}
-	if _, err := client.Ping(redisCtx).Result(); err != nil {
-		return err
-	} else {
-		redisClient = client
-		return nil
-	}
+// 声明主题，如果主题不存在则创建。
+// ctx 上下文。
+// topics 主题。
+func Declare(ctx context.Context, topics ...string) error {
+	return queue.Declare(ctx, topics...)
 }
 
-// 获取队列的长度。
+// 获取队列的长度，包括已出队但是尚未确认的消息。
+// ctx 上下文。
 // topic 主题。
 // 返回队列的当前长度。
-func Length(topic string) (int64, error) {
-	return redisClient.LLen(redisCtx, topic).Result()
+func Length(ctx context.Context, topic string) (int64, error) {
+	return queue.Length(ctx, topic)
 }
 
 // 将值入队。
+// ctx 上下文。
 // topic 主题。
-// 待入队的值。
-// 返回队列的新长度。
-func Push(topic string, value string) (int64, error) {
-	return redisClient.LPush(redisCtx, topic, value).Result()
+// value 待入队的值。
+// 返回消息的ID。
+func Push(ctx context.Context, topic string, value string) (string, error) {
+	return queue.Push(ctx, topic, value)
+}
+
+// 查看队列中下一个将要出队的消息，但是不出队。
+// ctx 上下文。
+// topic 主题。
+// 返回下一个将要出队的消息，如果队列为空则返回`Nil`。
+func Peek(ctx context.Context, topic string) (*Message, error) {
+	return queue.Peek(ctx, topic)
+}
+
+// 从多个主题中阻塞地出队。
+// 按照主题的顺序检查，从第一个不为空的主题中出队。如果所有主题都为空，那么阻塞直到任一主题有值或者超时。
+// 出队的消息必须调用`Ack`确认，或者调用`Nack`、`Fail`放回队列，否则超过可见性超时后会被重新投递。
+// ctx 上下文。
+// timeout 阻塞的超时时间。
+// topics 主题。
+// 返回出队的消息。如果超时则返回`Nil`，如果上下文被取消则返回上下文的错误。
+func BPop(ctx context.Context, timeout time.Duration, topics ...string) (*Message, error) {
+	return queue.BPop(ctx, timeout, topics...)
+}
+
+// 确认消息已经处理完毕，消息会从队列中删除。
+// ctx 上下文。
+// m 待确认的消息。
+func Ack(ctx context.Context, m *Message) error {
+	return queue.Ack(ctx, m)
+}
+
+// 将消息放回队列的末尾，不增加投递次数。
+// 用于消息暂时不能处理（比如达到限制）的情况。
+// ctx 上下文。
+// m 待放回的消息。
+func Nack(ctx context.Context, m *Message) error {
+	return queue.Nack(ctx, m)
 }
 
-// 将值出队。
+// 报告消息处理失败。
+// 如果投递次数没有超过上限，那么放回队列的末尾，否则转移到死信队列。
+// ctx 上下文。
+// m 处理失败的消息。
+// reason 失败的原因。
+// maxDeliveries 最大投递次数。
+// 返回消息是否被转移到死信队列。
+func Fail(ctx context.Context, m *Message, reason string, maxDeliveries int) (bool, error) {
+	return queue.Fail(ctx, m, reason, maxDeliveries)
+}
+
+// 重新投递超过可见性超时仍未确认的消息。
+// 这些消息的消费者可能已经崩溃。多个进程可以同时执行此方法，每条消息只会被其中一个进程重新投递。
+// ctx 上下文。
 // topic 主题。
-// 返回出队的值。
-func Pop(topic string) (string, error) {
-	return redisClient.RPop(redisCtx, topic).Result()
-
-	// if v, err := redisClient.BRPop(1*time.Second, topic).Result(); err != nil {
-	// 	return "", err
-	// } else if len(v) < 2 {
-	// 	return "", redis.Nil
-	// } else {
-	// 	return v[1], nil
-	// }
+// visibilityTimeout 可见性超时。
+// maxDeliveries 最大投递次数，超过此次数的消息被转移到死信队列。
+// 返回重新投递的消息数和转移到死信队列的消息数。
+func Reclaim(ctx context.Context, topic string, visibilityTimeout time.Duration, maxDeliveries int) (int, int, error) {
+	return queue.Reclaim(ctx, topic, visibilityTimeout, maxDeliveries)
+}
+
+// 当前进程不再作为消费者读取队列，在退出时所有的消息都已经确认或者放回队列之后调用。
+// 仍有待处理消息的主题保留当前进程的消费者，之后由其它进程重新投递并清理。
+// ctx 上下文。
+func Leave(ctx context.Context) error {
+	return queue.Leave(ctx)
 }
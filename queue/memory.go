@@ -0,0 +1,216 @@
+// 该模块实现了进程内的内存队列后端。
+// 内存队列只在当前进程中有效，适用于在同一个进程中运行查询接口服务和查询代理工作进程的开发和集成测试环境。
+// @Author: agent
+// @Created: 2026-10-18
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 表示内存队列中的一个主题。
+type memoryTopic struct {
+	ready   []*Message           // 等待出队的消息。
+	pending map[string]*Message  // 已出队但是尚未确认的消息，键是消息ID。
+	popTime map[string]time.Time // 已出队但是尚未确认的消息的出队时间，键是消息ID。
+}
+
+type memoryQueue struct {
+	lock   sync.Mutex
+	topics map[string]*memoryTopic
+	seq    uint64
+	notify chan struct{} // 有消息入队时关闭并替换，用于唤醒所有阻塞出队的协程。
+}
+
+// 初始化内存队列，并作为当前的队列后端。
+func InitMemoryQueue() error {
+	Use(&memoryQueue{topics: make(map[string]*memoryTopic), notify: make(chan struct{})})
+	return nil
+}
+
+// 获取主题，如果不存在则创建。调用者必须持有锁。
+func (q *memoryQueue) topic(name string) *memoryTopic {
+	t, ok := q.topics[name]
+	if !ok {
+		t = &memoryTopic{pending: make(map[string]*Message), popTime: make(map[string]time.Time)}
+		q.topics[name] = t
+	}
+
+	return t
+}
+
+// 将消息放入主题的末尾，并唤醒阻塞出队的协程。调用者必须持有锁。
+func (q *memoryQueue) push(topic, value string, deliveries int, t time.Time) string {
+	now := time.Now()
+	q.seq++
+	id := strconv.FormatInt(now.UnixMilli(), 10) + "-" + strconv.FormatUint(q.seq, 10)
+
+	q.topic(topic).ready = append(q.topic(topic).ready, &Message{Topic: topic, Id: id, Value: value, Deliveries: deliveries, Time: t})
+
+	close(q.notify)
+	q.notify = make(chan struct{})
+
+	return id
+}
+
+// 从主题中删除待处理的消息。调用者必须持有锁。
+func (q *memoryQueue) remove(m *Message) bool {
+	t := q.topic(m.Topic)
+	if _, ok := t.pending[m.Id]; !ok {
+		return false
+	}
+
+	delete(t.pending, m.Id)
+	delete(t.popTime, m.Id)
+	return true
+}
+
+func (q *memoryQueue) Declare(ctx context.Context, topics ...string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, topic := range topics {
+		q.topic(topic)
+	}
+
+	return nil
+}
+
+func (q *memoryQueue) Length(ctx context.Context, topic string) (int64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	t := q.topic(topic)
+	return int64(len(t.ready) + len(t.pending)), nil
+}
+
+func (q *memoryQueue) Push(ctx context.Context, topic string, value string) (string, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.push(topic, value, 0, time.Now()), nil
+}
+
+func (q *memoryQueue) Peek(ctx context.Context, topic string) (*Message, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if t := q.topic(topic); len(t.ready) == 0 {
+		return nil, Nil
+	} else {
+		m := *t.ready[0]
+		return &m, nil
+	}
+}
+
+func (q *memoryQueue) BPop(ctx context.Context, timeout time.Duration, topics ...string) (*Message, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		q.lock.Lock()
+		for _, topic := range topics {
+			t := q.topic(topic)
+			if len(t.ready) != 0 {
+				m := t.ready[0]
+				t.ready[0] = nil
+				t.ready = t.ready[1:]
+				t.pending[m.Id] = m
+				t.popTime[m.Id] = time.Now()
+				q.lock.Unlock()
+
+				r := *m
+				return &r, nil
+			}
+		}
+		notify := q.notify
+		q.lock.Unlock()
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, Nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+			return nil, Nil
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (q *memoryQueue) Ack(ctx context.Context, m *Message) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.remove(m)
+	return nil
+}
+
+func (q *memoryQueue) Nack(ctx context.Context, m *Message) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.remove(m) {
+		q.push(m.Topic, m.Value, m.Deliveries, m.Time)
+	}
+	return nil
+}
+
+func (q *memoryQueue) Fail(ctx context.Context, m *Message, reason string, maxDeliveries int) (bool, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.fail(m, reason, maxDeliveries), nil
+}
+
+// 报告消息处理失败。调用者必须持有锁。
+func (q *memoryQueue) fail(m *Message, reason string, maxDeliveries int) bool {
+	if !q.remove(m) {
+		return false
+	}
+
+	if m.Deliveries+1 >= maxDeliveries {
+		// 死信队列中的消息只用于检查，不会被出队。
+		q.push(deadLetterTopic, m.Value, m.Deliveries+1, m.Time)
+		return true
+	}
+
+	q.push(m.Topic, m.Value, m.Deliveries+1, m.Time)
+	return false
+}
+
+func (q *memoryQueue) Reclaim(ctx context.Context, topic string, visibilityTimeout time.Duration, maxDeliveries int) (int, int, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	t := q.topic(topic)
+	now := time.Now()
+
+	requeued, dead := 0, 0
+	for id, m := range t.pending {
+		if now.Sub(t.popTime[id]) < visibilityTimeout {
+			continue
+		}
+
+		if q.fail(m, "visibility timeout", maxDeliveries) {
+			dead++
+		} else {
+			requeued++
+		}
+	}
+
+	return requeued, dead, nil
+}
+
+func (q *memoryQueue) Leave(ctx context.Context) error {
+	// 内存队列没有消费者。
+	return nil
+}
@@ -0,0 +1,258 @@
+// @Author: agent
+// @Created: 2026-10-18
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func initTestQueue(t *testing.T) context.Context {
+	if err := InitMemoryQueue(); err != nil {
+		t.Fatal(err)
+	}
+
+	return context.Background()
+}
+
+func mustPop(t *testing.T, ctx context.Context, topics ...string) *Message {
+	t.Helper()
+
+	m, err := BPop(ctx, 100*time.Millisecond, topics...)
+	if err != nil {
+		t.Fatalf("BPop(%v) failed: %s", topics, err)
+	}
+
+	return m
+}
+
+func assertLength(t *testing.T, ctx context.Context, topic string, want int64) {
+	t.Helper()
+
+	if n, err := Length(ctx, topic); err != nil || n != want {
+		t.Errorf("Length(%s) = %d, %v, want %d", topic, n, err, want)
+	}
+}
+
+func TestMemoryPushAndPop(t *testing.T) {
+	ctx := initTestQueue(t)
+
+	before := time.Now()
+	for _, v := range []string{"a", "b"} {
+		if id, err := Push(ctx, "T$Low", v); err != nil || id == "" {
+			t.Fatalf("Push = %q, %v", id, err)
+		}
+	}
+	Push(ctx, "T$High", "h")
+
+	// Peek不出队。
+	if m, err := Peek(ctx, "T$Low"); err != nil || m.Value != "a" {
+		t.Errorf("Peek = %v, %v, want a", m, err)
+	}
+	if _, err := Peek(ctx, "T$Empty"); !errors.Is(err, Nil) {
+		t.Errorf("Peek of empty topic error = %v, want Nil", err)
+	}
+
+	// 按照主题的顺序出队，同一个主题先进先出。
+	m := mustPop(t, ctx, "T$High", "T$Low")
+	if m.Value != "h" || m.Topic != "T$High" || m.Deliveries != 0 || m.Time.Before(before) {
+		t.Errorf("BPop = %+v, want h from T$High", m)
+	}
+	m = mustPop(t, ctx, "T$High", "T$Low")
+	if m.Value != "a" || m.Topic != "T$Low" {
+		t.Errorf("BPop = %+v, want a from T$Low", m)
+	}
+
+	// 和XLEN一样，长度包括已出队但是尚未确认的消息。Peek只返回尚未出队的消息。
+	assertLength(t, ctx, "T$Low", 2)
+	if m, err := Peek(ctx, "T$Low"); err != nil || m.Value != "b" {
+		t.Errorf("Peek = %v, %v, want b", m, err)
+	}
+
+	Ack(ctx, m)
+	assertLength(t, ctx, "T$Low", 1)
+	assertLength(t, ctx, "T$Undeclared", 0)
+}
+
+func TestMemoryBPopTimeout(t *testing.T) {
+	ctx := initTestQueue(t)
+
+	start := time.Now()
+	if _, err := BPop(ctx, 50*time.Millisecond, "T$Low"); !errors.Is(err, Nil) {
+		t.Errorf("BPop of empty topic error = %v, want Nil", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("BPop blocked %s, want about 50ms", elapsed)
+	}
+
+	// 阻塞期间入队的消息立刻被取出，入队到其它主题不影响。
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		Push(ctx, "T$Other", "x")
+		time.Sleep(20 * time.Millisecond)
+		Push(ctx, "T$High", "h")
+	}()
+	start = time.Now()
+	if m, err := BPop(ctx, 5*time.Second, "T$Low", "T$High"); err != nil || m.Value != "h" {
+		t.Errorf("BPop = %v, %v, want h", m, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("BPop blocked %s after push", elapsed)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := BPop(cctx, 5*time.Second, "T$Low"); !errors.Is(err, context.Canceled) {
+		t.Errorf("BPop error = %v, want context.Canceled", err)
+	}
+}
+
+func TestMemoryNack(t *testing.T) {
+	ctx := initTestQueue(t)
+
+	Push(ctx, "T$Low", "a")
+	Push(ctx, "T$Low", "b")
+	a := mustPop(t, ctx, "T$Low")
+
+	// 放回队列的末尾，不增加投递次数，保留第一次入队的时间。
+	if err := Nack(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, ctx, "T$Low", 2)
+	if m := mustPop(t, ctx, "T$Low"); m.Value != "b" {
+		t.Errorf("BPop = %q, want b", m.Value)
+	}
+	m := mustPop(t, ctx, "T$Low")
+	if m.Value != "a" || m.Deliveries != 0 || !m.Time.Equal(a.Time) || m.Id == a.Id {
+		t.Errorf("BPop after Nack = %+v, want a with new id, deliveries 0 and time %s", m, a.Time)
+	}
+
+	// 已经确认的消息不能再放回队列。
+	Ack(ctx, m)
+	Nack(ctx, m)
+	assertLength(t, ctx, "T$Low", 1)
+}
+
+func TestMemoryFailAndDeadLetter(t *testing.T) {
+	ctx := initTestQueue(t)
+	const maxDeliveries = 3
+
+	Push(ctx, "T$Low", "a")
+	first := mustPop(t, ctx, "T$Low")
+
+	m := first
+	for i := 0; i < maxDeliveries-1; i++ {
+		if dead, err := Fail(ctx, m, "boom", maxDeliveries); err != nil || dead {
+			t.Fatalf("Fail #%d = %v, %v, want requeued", i, dead, err)
+		}
+		m = mustPop(t, ctx, "T$Low")
+		if m.Deliveries != i+1 || !m.Time.Equal(first.Time) {
+			t.Errorf("redelivered message = %+v, want deliveries %d", m, i+1)
+		}
+	}
+
+	if dead, err := Fail(ctx, m, "boom", maxDeliveries); err != nil || !dead {
+		t.Fatalf("last Fail = %v, %v, want dead", dead, err)
+	}
+	assertLength(t, ctx, "T$Low", 0)
+	assertLength(t, ctx, deadLetterTopic, 1)
+
+	if d, err := Peek(ctx, deadLetterTopic); err != nil || d.Value != "a" || d.Deliveries != maxDeliveries || !d.Time.Equal(first.Time) {
+		t.Errorf("dead letter = %+v, %v", d, err)
+	}
+
+	// 重复报告失败不会产生重复的消息。
+	if dead, _ := Fail(ctx, m, "boom", maxDeliveries); dead {
+		t.Errorf("Fail of removed message should be ignored")
+	}
+	assertLength(t, ctx, deadLetterTopic, 1)
+}
+
+func TestMemoryReclaim(t *testing.T) {
+	ctx := initTestQueue(t)
+	const visibilityTimeout = 30 * time.Millisecond
+
+	Push(ctx, "T$Low", "a")
+	Push(ctx, "T$Low", "b")
+	a := mustPop(t, ctx, "T$Low")
+
+	// 未超过可见性超时的消息不会被重新投递。
+	if requeued, dead, err := Reclaim(ctx, "T$Low", visibilityTimeout, 2); err != nil || requeued != 0 || dead != 0 {
+		t.Errorf("Reclaim = %d, %d, %v, want nothing", requeued, dead, err)
+	}
+
+	time.Sleep(2 * visibilityTimeout)
+	b := mustPop(t, ctx, "T$Low")
+	if requeued, dead, err := Reclaim(ctx, "T$Low", visibilityTimeout, 2); err != nil || requeued != 1 || dead != 0 {
+		t.Errorf("Reclaim = %d, %d, %v, want 1 requeued", requeued, dead, err)
+	}
+
+	// 消费者崩溃之后才确认的消息被忽略，重新投递的消息仍然有效。
+	Ack(ctx, a)
+	Ack(ctx, b)
+	assertLength(t, ctx, "T$Low", 1)
+
+	m := mustPop(t, ctx, "T$Low")
+	if m.Value != "a" || m.Deliveries != 1 {
+		t.Errorf("reclaimed message = %+v, want a with deliveries 1", m)
+	}
+
+	// 达到最大投递次数的消息被转移到死信队列。
+	time.Sleep(2 * visibilityTimeout)
+	if requeued, dead, err := Reclaim(ctx, "T$Low", visibilityTimeout, 2); err != nil || requeued != 0 || dead != 1 {
+		t.Errorf("Reclaim = %d, %d, %v, want 1 dead", requeued, dead, err)
+	}
+	assertLength(t, ctx, "T$Low", 0)
+	assertLength(t, ctx, deadLetterTopic, 1)
+}
+
+func TestMemoryConcurrentConsumers(t *testing.T) {
+	ctx := initTestQueue(t)
+
+	// 每条消息只被一个消费者取出。
+	const n = 100
+	got := make(chan string, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, err := BPop(ctx, 100*time.Millisecond, "T$High", "T$Low")
+				if err != nil {
+					return
+				}
+				got <- m.Value
+				Ack(ctx, m)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		topic := "T$Low"
+		if i%2 == 0 {
+			topic = "T$High"
+		}
+		Push(ctx, topic, string(rune('A'+i)))
+	}
+	wg.Wait()
+	close(got)
+
+	seen := make(map[string]bool)
+	for v := range got {
+		if seen[v] {
+			t.Errorf("message %q delivered twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != n {
+		t.Errorf("delivered %d messages, want %d", len(seen), n)
+	}
+	assertLength(t, ctx, "T$High", 0)
+	assertLength(t, ctx, "T$Low", 0)
+}
@@ -0,0 +1,413 @@
+// 该模块实现了基于Redis Streams的队列后端。
+// 每个主题对应一个Stream，所有的消费者属于同一个消费者组：出队的消息在确认之前一直处于待处理状态，
+// 超过可见性超时仍未确认的消息可以被其它消费者重新投递。
+// 消费者的名字是主机名和进程号，进程退出时从消费者组中删除自己，崩溃的进程留下的消费者在重新投递时被清理。
+// 重新投递使用`XPENDING`的`IDLE`参数，需要Redis 6.2及以上版本，初始化时检查。
+// @Author: agent
+// @Created: 2026-10-18
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_redisclient "com.cne/ai-tracking-search/redisclient"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	consumerGroup string = "TRACKING_WORKER" // 消费者组的名字。
+	minRedisMajor int    = 6                 // 需要的Redis的最低主版本。
+	minRedisMinor int    = 2                 // 需要的Redis的最低次版本。
+
+	fieldValue      string = "value"      // 消息中保存值的字段。
+	fieldDeliveries string = "deliveries" // 消息中保存投递次数的字段。
+	fieldTime       string = "time"       // 消息中保存第一次入队时间（毫秒）的字段。
+	fieldTopic      string = "topic"      // 死信中保存原始主题的字段。
+	fieldReason     string = "reason"     // 死信中保存失败原因的字段。
+)
+
+type redisQueue struct {
+	client   redis.UniversalClient
+	consumed sync.Map // 当前进程作为消费者读取过的Stream的键。
+}
+
+var (
+	consumerName string // 当前进程作为消费者的名字。
+)
+
+func init() {
+	hostname, _ := os.Hostname()
+	consumerName = hostname + "-" + strconv.Itoa(os.Getpid())
+}
+
+// 初始化Redis队列，并作为当前的队列后端。
+// 使用共享的Redis客户端，必须首先初始化共享的Redis客户端。共享的Redis客户端的连接池应当为阻塞出队的工作协程预留连接。
+func InitRedisQueue() error {
+	client := _redisclient.Client()
+	if client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	if err := checkRedisVersion(client); err != nil {
+		return err
+	}
+
+	Use(&redisQueue{client: client})
+	return nil
+}
+
+// 检查Redis的版本是否支持重新投递使用的命令。
+func checkRedisVersion(client redis.UniversalClient) error {
+	info, err := client.Info(context.Background(), "server").Result()
+	if err != nil {
+		return fmt.Errorf("cannot get redis version. cause=%w", err)
+	}
+
+	for _, line := range strings.Split(info, "\n") {
+		if v := strings.TrimPrefix(strings.TrimSpace(line), "redis_version:"); v != strings.TrimSpace(line) {
+			pp := strings.SplitN(v, ".", 3)
+			major, _ := strconv.Atoi(pp[0])
+			minor := 0
+			if len(pp) > 1 {
+				minor, _ = strconv.Atoi(pp[1])
+			}
+			if major < minRedisMajor || (major == minRedisMajor && minor < minRedisMinor) {
+				return fmt.Errorf("redis queue requires redis %d.%d or later, but %s", minRedisMajor, minRedisMinor, v)
+			}
+			return nil
+		}
+	}
+
+	// 某些兼容Redis协议的服务不返回版本，不阻止启动。
+	return nil
+}
+
+// 返回主题对应的Stream的键。
+// 集群模式下主题中第一个`$`之前的部分作为散列标签，所以同一个队列的所有主题（比如各个优先级和死信队列）在同一个槽中，可以同时阻塞读取和在事务中转移消息。
+func (q *redisQueue) key(topic string) string {
+	if i := strings.Index(topic, "$"); i >= 0 {
+		return _redisclient.Key(topic[:i], topic[i:])
+	}
+
+	return _redisclient.Key(topic, "")
+}
+
+func (q *redisQueue) Declare(ctx context.Context, topics ...string) error {
+	for _, topic := range topics {
+		if err := q.client.XGroupCreateMkStream(ctx, q.key(topic), consumerGroup, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *redisQueue) Length(ctx context.Context, topic string) (int64, error) {
+	return q.client.XLen(ctx, q.key(topic)).Result()
+}
+
+func (q *redisQueue) Push(ctx context.Context, topic string, value string) (string, error) {
+	return q.push(ctx, q.client, topic, value, 0, time.Now())
+}
+
+func (q *redisQueue) push(ctx context.Context, c redis.Cmdable, topic string, value string, deliveries int, t time.Time) (string, error) {
+	return c.XAdd(ctx, &redis.XAddArgs{Stream: q.key(topic), Values: map[string]interface{}{fieldValue: value, fieldDeliveries: deliveries, fieldTime: t.UnixMilli()}}).Result()
+}
+
+func (q *redisQueue) Peek(ctx context.Context, topic string) (*Message, error) {
+	if l, err := q.client.XLen(ctx, q.key(topic)).Result(); err != nil {
+		return nil, err
+	} else if l == 0 {
+		return nil, Nil
+	}
+
+	lastId := "0-0"
+	if groups, err := q.client.Do(ctx, "XINFO", "GROUPS", q.key(topic)).Slice(); err != nil {
+		return nil, err
+	} else {
+		// 不同版本的Redis返回的字段不同，所以按照名字查找。
+		for _, g := range groups {
+			if kv, ok := g.([]interface{}); ok && xinfoField(kv, "name") == consumerGroup {
+				lastId = xinfoField(kv, "last-delivered-id")
+			}
+		}
+	}
+
+	if mm, err := q.client.XRangeN(ctx, q.key(topic), nextId(lastId), "+", 1).Result(); err != nil {
+		return nil, err
+	} else if len(mm) == 0 {
+		return nil, Nil
+	} else {
+		return toMessage(topic, mm[0]), nil
+	}
+}
+
+func xinfoField(kv []interface{}, name string) string {
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok && k == name {
+			if v, ok := kv[i+1].(string); ok {
+				return v
+			}
+		}
+	}
+
+	return ""
+}
+
+// 计算紧跟在指定ID之后的ID。
+func nextId(id string) string {
+	pp := strings.SplitN(id, "-", 2)
+	if len(pp) != 2 {
+		return "0-1"
+	}
+
+	if seq, err := strconv.ParseUint(pp[1], 10, 64); err != nil {
+		return "0-1"
+	} else {
+		return pp[0] + "-" + strconv.FormatUint(seq+1, 10)
+	}
+}
+
+func (q *redisQueue) BPop(ctx context.Context, timeout time.Duration, topics ...string) (*Message, error) {
+	// 首先依次检查每个主题，保证主题的顺序。
+	for _, topic := range topics {
+		if mm, err := q.readGroup(ctx, -1, topic); err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, err
+		} else if len(mm) != 0 {
+			return mm[0], nil
+		}
+	}
+
+	// 所有主题都为空，阻塞等待。
+	mm, err := q.readGroup(ctx, timeout, topics...)
+	if err == redis.Nil {
+		return nil, Nil
+	} else if err != nil {
+		return nil, err
+	} else if len(mm) == 0 {
+		return nil, Nil
+	}
+
+	// 阻塞期间多个主题同时有值的情况很少见，只处理第一个，其它的放回队列。
+	for _, m := range mm[1:] {
+		if err := q.Nack(ctx, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return mm[0], nil
+}
+
+// 以消费者组的方式读取多个主题。
+// block 阻塞的超时时间，负数表示不阻塞。
+func (q *redisQueue) readGroup(ctx context.Context, block time.Duration, topics ...string) ([]*Message, error) {
+	// 返回的Stream的键需要转换为主题。
+	keyTopics := make(map[string]string, len(topics))
+	streams := make([]string, 0, len(topics)*2)
+	for _, topic := range topics {
+		key := q.key(topic)
+		keyTopics[key] = topic
+		streams = append(streams, key)
+	}
+	for range topics {
+		streams = append(streams, ">")
+	}
+
+	for key := range keyTopics {
+		q.consumed.Store(key, true)
+	}
+
+	args := &redis.XReadGroupArgs{Group: consumerGroup, Consumer: consumerName, Streams: streams, Count: 1, Block: block}
+	ss, err := q.client.XReadGroup(ctx, args).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		// 主题尚未声明，声明之后重试。
+		if err := q.Declare(ctx, topics...); err != nil {
+			return nil, err
+		}
+		ss, err = q.client.XReadGroup(ctx, args).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Message, 0, len(ss))
+	for _, s := range ss {
+		for _, m := range s.Messages {
+			result = append(result, toMessage(keyTopics[s.Stream], m))
+		}
+	}
+
+	return result, nil
+}
+
+func toMessage(topic string, m redis.XMessage) *Message {
+	result := Message{Topic: topic, Id: m.ID}
+	if v, ok := m.Values[fieldValue].(string); ok {
+		result.Value = v
+	}
+	if v, ok := m.Values[fieldDeliveries].(string); ok {
+		result.Deliveries, _ = strconv.Atoi(v)
+	}
+	if v, ok := m.Values[fieldTime].(string); ok {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			result.Time = time.UnixMilli(ms)
+		}
+	}
+	if result.Time.IsZero() {
+		// 没有入队时间的消息，使用ID中的时间。
+		if ms, err := strconv.ParseInt(strings.SplitN(m.ID, "-", 2)[0], 10, 64); err == nil {
+			result.Time = time.UnixMilli(ms)
+		}
+	}
+
+	return &result
+}
+
+func (q *redisQueue) Ack(ctx context.Context, m *Message) error {
+	p := q.client.TxPipeline()
+
+	p.XAck(ctx, q.key(m.Topic), consumerGroup, m.Id)
+	p.XDel(ctx, q.key(m.Topic), m.Id)
+
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (q *redisQueue) Nack(ctx context.Context, m *Message) error {
+	return q.requeue(ctx, m, m.Deliveries)
+}
+
+func (q *redisQueue) Fail(ctx context.Context, m *Message, reason string, maxDeliveries int) (bool, error) {
+	if m.Deliveries+1 >= maxDeliveries {
+		return true, q.toDeadLetter(ctx, m, reason)
+	}
+
+	return false, q.requeue(ctx, m, m.Deliveries+1)
+}
+
+func (q *redisQueue) requeue(ctx context.Context, m *Message, deliveries int) error {
+	p := q.client.TxPipeline()
+
+	q.push(ctx, p, m.Topic, m.Value, deliveries, m.Time)
+	p.XAck(ctx, q.key(m.Topic), consumerGroup, m.Id)
+	p.XDel(ctx, q.key(m.Topic), m.Id)
+
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (q *redisQueue) toDeadLetter(ctx context.Context, m *Message, reason string) error {
+	p := q.client.TxPipeline()
+
+	p.XAdd(ctx, &redis.XAddArgs{Stream: q.key(deadLetterTopic), Values: map[string]interface{}{fieldValue: m.Value, fieldDeliveries: m.Deliveries + 1, fieldTime: m.Time.UnixMilli(), fieldTopic: m.Topic, fieldReason: reason}})
+	p.XAck(ctx, q.key(m.Topic), consumerGroup, m.Id)
+	p.XDel(ctx, q.key(m.Topic), m.Id)
+
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (q *redisQueue) Reclaim(ctx context.Context, topic string, visibilityTimeout time.Duration, maxDeliveries int) (int, int, error) {
+	requeued, dead, err := q.reclaim(ctx, topic, visibilityTimeout, maxDeliveries)
+	if err != nil {
+		return requeued, dead, err
+	}
+
+	// 已经退出的进程留下的消费者的待处理消息已经被转移，可以清理这些消费者。
+	return requeued, dead, q.removeIdleConsumers(ctx, topic, visibilityTimeout)
+}
+
+func (q *redisQueue) reclaim(ctx context.Context, topic string, visibilityTimeout time.Duration, maxDeliveries int) (int, int, error) {
+	pp, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: q.key(topic), Group: consumerGroup, Idle: visibilityTimeout, Start: "-", End: "+", Count: 100}).Result()
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	} else if len(pp) == 0 {
+		return 0, 0, nil
+	}
+
+	ids := make([]string, 0, len(pp))
+	for _, p := range pp {
+		ids = append(ids, p.ID)
+	}
+
+	// 通过XCLAIM获得消息的所有权，XCLAIM会再次检查空闲时间，所以同一条消息不会被多个进程重新投递。
+	q.consumed.Store(q.key(topic), true)
+	mm, err := q.client.XClaim(ctx, &redis.XClaimArgs{Stream: q.key(topic), Group: consumerGroup, Consumer: consumerName, MinIdle: visibilityTimeout, Messages: ids}).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	requeued, dead := 0, 0
+	for _, xm := range mm {
+		m := toMessage(topic, xm)
+		if d, err := q.Fail(ctx, m, "visibility timeout", maxDeliveries); err != nil {
+			return requeued, dead, err
+		} else if d {
+			dead++
+		} else {
+			requeued++
+		}
+	}
+
+	return requeued, dead, nil
+}
+
+// 删除空闲时间超过可见性超时、并且没有待处理消息的其它消费者。
+// 正在工作的消费者每次阻塞出队都会刷新空闲时间，所以只有已经退出的进程留下的消费者会被删除。
+func (q *redisQueue) removeIdleConsumers(ctx context.Context, topic string, minIdle time.Duration) error {
+	cc, err := q.client.XInfoConsumers(ctx, q.key(topic), consumerGroup).Result()
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOGROUP") || strings.HasPrefix(err.Error(), "ERR no such key") {
+			return nil
+		}
+		return err
+	}
+
+	for _, c := range cc {
+		if c.Name == consumerName || c.Pending != 0 || time.Duration(c.Idle)*time.Millisecond < minIdle {
+			continue
+		}
+		if err := q.client.XGroupDelConsumer(ctx, q.key(topic), consumerGroup, c.Name).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *redisQueue) Leave(ctx context.Context) error {
+	var result error
+	q.consumed.Range(func(k, _ interface{}) bool {
+		key := k.(string)
+
+		// 删除消费者会丢弃它的待处理消息，所以仍有待处理消息时保留消费者，由其它进程重新投递之后清理。
+		cc, err := q.client.XInfoConsumers(ctx, key, consumerGroup).Result()
+		if err != nil {
+			result = err
+			return true
+		}
+		for _, c := range cc {
+			if c.Name == consumerName && c.Pending == 0 {
+				if err := q.client.XGroupDelConsumer(ctx, key, consumerGroup, consumerName).Err(); err != nil {
+					result = err
+				}
+			}
+		}
+
+		return true
+	})
+
+	return result
+}
@@ -0,0 +1,163 @@
+// 该模块定义了队列、缓存和限制共享的Redis客户端。
+// 支持单个Redis实例、哨兵和集群三种部署方式，以及TLS和键的前缀。
+// 集群模式下需要同时访问的键（比如同一个查询的跟随者列表，所有优先级的队列）通过散列标签（hash tag）分配到同一个槽。
+// @Author: agent
+// @Created: 2026-10-18
+package redisclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"time"
+
+	_config "com.cne/ai-tracking-search/config"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	client    redis.UniversalClient // 共享的Redis客户端。
+	keyPrefix string                // 所有的键的前缀。
+	cluster   bool                  // 是否连接Redis集群。
+)
+
+// 根据配置创建共享的Redis客户端并且Ping。
+// redisConfig Redis配置。
+// blockingConns 阻塞出队的协程数。每个阻塞出队的协程会占用一个连接，没有配置连接池的大小时，连接池在默认大小之外为它们预留连接。
+func Init(redisConfig *_config.RedisConfiguration, blockingConns int) error {
+	tlsConfig, err := newTLSConfig(&redisConfig.TLS)
+	if err != nil {
+		return err
+	}
+
+	poolSize := redisConfig.PoolSize
+	if poolSize == 0 {
+		poolSize = 10*runtime.GOMAXPROCS(0) + blockingConns
+	}
+	dialTimeout := time.Duration(redisConfig.DialTimeout) * time.Millisecond
+	readTimeout := time.Duration(redisConfig.ReadTimeout) * time.Millisecond
+	writeTimeout := time.Duration(redisConfig.WriteTimeout) * time.Millisecond
+	poolTimeout := time.Duration(redisConfig.PoolTimeout) * time.Millisecond
+
+	var c redis.UniversalClient
+	switch redisConfig.Mode {
+	case _config.RedisModeSentinel:
+		c = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       redisConfig.MasterName,
+			SentinelAddrs:    redisConfig.Addrs,
+			SentinelPassword: redisConfig.SentinelPassword,
+			Username:         redisConfig.Username,
+			Password:         redisConfig.Password,
+			DB:               redisConfig.DB,
+			PoolSize:         poolSize,
+			MinIdleConns:     redisConfig.MinIdleConns,
+			DialTimeout:      dialTimeout,
+			ReadTimeout:      readTimeout,
+			WriteTimeout:     writeTimeout,
+			PoolTimeout:      poolTimeout,
+			TLSConfig:        tlsConfig,
+		})
+	case _config.RedisModeCluster:
+		// 集群客户端为每个节点创建一个连接池，连接池的大小是每个节点的连接数。
+		c = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        redisConfig.Addrs,
+			Username:     redisConfig.Username,
+			Password:     redisConfig.Password,
+			PoolSize:     poolSize,
+			MinIdleConns: redisConfig.MinIdleConns,
+			DialTimeout:  dialTimeout,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			PoolTimeout:  poolTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	default:
+		c = redis.NewClient(&redis.Options{
+			Addr:         redisConfig.Host + ":" + strconv.Itoa(redisConfig.Port),
+			Username:     redisConfig.Username,
+			Password:     redisConfig.Password,
+			DB:           redisConfig.DB,
+			PoolSize:     poolSize,
+			MinIdleConns: redisConfig.MinIdleConns,
+			DialTimeout:  dialTimeout,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			PoolTimeout:  poolTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	}
+
+	if _, err := c.Ping(context.Background()).Result(); err != nil {
+		c.Close()
+		return fmt.Errorf("cannot connect to redis(%s): %w", redisConfig.Mode, err)
+	}
+
+	client = c
+	keyPrefix = redisConfig.KeyPrefix
+	cluster = redisConfig.Mode == _config.RedisModeCluster
+	return nil
+}
+
+func newTLSConfig(tlsConfig *_config.RedisTLSConfiguration) (*tls.Config, error) {
+	if !tlsConfig.Enabled {
+		return nil, nil
+	}
+
+	result := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tlsConfig.ServerName,
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+
+	if tlsConfig.CAFile != "" {
+		if pem, err := ioutil.ReadFile(tlsConfig.CAFile); err != nil {
+			return nil, err
+		} else {
+			result.RootCAs = x509.NewCertPool()
+			if !result.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in %s", tlsConfig.CAFile)
+			}
+		}
+	}
+
+	if tlsConfig.CertFile != "" {
+		if cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile); err != nil {
+			return nil, err
+		} else {
+			result.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return result, nil
+}
+
+// 检查Redis是否可用。
+// ctx 上下文。
+func Ping(ctx context.Context) error {
+	if client == nil {
+		return fmt.Errorf("redis is not initialized")
+	}
+
+	return client.Ping(ctx).Err()
+}
+
+// 返回共享的Redis客户端，尚未初始化时返回nil。
+func Client() redis.UniversalClient {
+	return client
+}
+
+// 返回Redis中实际使用的键。
+// tag 键中决定所在的槽的部分，集群模式下被包含在散列标签中，具有相同`tag`的键总是在同一个槽中。
+// suffix 键的其余部分。
+// 返回加上前缀的键。非集群模式下不使用散列标签，键和`tag + suffix`相同。
+func Key(tag string, suffix string) string {
+	if cluster {
+		return keyPrefix + "{" + tag + "}" + suffix
+	}
+
+	return keyPrefix + tag + suffix
+}
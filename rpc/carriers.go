@@ -56,7 +56,7 @@ func Carriers(ctx *gin.Context) {
 		panic(fmt.Errorf("illegal request: %w", err))
 	}
 
-	ctx.JSON(http.StatusOK, buildCarriersRsp(_db.QueryAllCarrier()))
+	ctx.JSON(http.StatusOK, buildCarriersRsp(_db.QueryAllCarrier(ctx.Request.Context())))
 }
 
 // 尝试匹配运输商。
@@ -76,7 +76,7 @@ func MatchCarriers(ctx *gin.Context) {
 		matchResults = append(matchResults, make([]*_db.CarrierPo, 0))
 	}
 
-	allCarrierPo := _db.QueryAllCarrier()
+	allCarrierPo := _db.QueryAllCarrier(ctx.Request.Context())
 
 	// 逐个匹配运输商。
 	for _, carrierPo := range allCarrierPo {
@@ -93,6 +93,14 @@ func MatchCarriers(ctx *gin.Context) {
 		}
 	}
 
+	for _, mr := range matchResults {
+		if len(mr) != 0 {
+			carrierMatches.With("matched").Inc()
+		} else {
+			carrierMatches.With("unmatched").Inc()
+		}
+	}
+
 	ctx.JSON(http.StatusOK, buildMatchCarriersRsp(matchResults))
 }
 
@@ -0,0 +1,179 @@
+// 该模块定义了存活检查、就绪检查和依赖诊断接口。
+// 负载均衡器根据就绪检查决定是否向实例发送请求，依赖不可用的实例返回503，不再接收新的请求。
+// @Author: agent
+// @Created: 2026-10-18
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	_url "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	_db "com.cne/ai-tracking-search/db"
+	_health "com.cne/ai-tracking-search/health"
+	_redisclient "com.cne/ai-tracking-search/redisclient"
+	_rpcclient "com.cne/ai-tracking-search/rpcclient"
+	_types "com.cne/ai-tracking-search/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthOk          string = "ok"          // 表示所有的依赖都可用。
+	healthUnavailable string = "unavailable" // 表示存在不可用的依赖。
+)
+
+// 表示检查结果的响应。
+type healthRsp struct {
+	Status string            `json:"status"`           // 检查结果，可以是`ok`或者`unavailable`。
+	Checks []*_health.Status `json:"checks,omitempty"` // 每项检查的结果。
+}
+
+var (
+	agentProbeClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }} // 检查查询代理时使用的HTTP客户端，不跟随重定向。
+)
+
+// 存活检查。只要进程可以处理HTTP请求就返回200，不检查依赖，以免依赖故障时所有的实例被重启。
+func Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, healthRsp{Status: healthOk})
+}
+
+// 就绪检查。检查主库、Redis、查询队列和运输商表，任何一项不可用时返回503。
+// 检查的结果缓存一小段时间，负载均衡器频繁检查时不会给依赖带来额外的压力。
+func Readyz(ctx *gin.Context) {
+	statuses, ok := _health.ProbeAll(ctx.Request.Context(), readinessChecks(), _health.DefaultProbeTimeout)
+	writeHealth(ctx, statuses, ok)
+}
+
+// 依赖诊断。除了就绪检查的各项之外，还检查只读副本和所有生效的查询代理，返回每项检查的延迟和最近一次出错的原因。
+// 只读副本和查询代理不可用时不影响服务，因此不影响响应的状态码。
+func Diagnostics(ctx *gin.Context) {
+	reqCtx := ctx.Request.Context()
+
+	statuses, ok := _health.ProbeAll(reqCtx, readinessChecks(), _health.DefaultProbeTimeout)
+
+	// 查询所有生效的查询代理本身也是一项检查，它生成其它检查，因此不缓存。
+	optional := make([]*_health.Check, 0)
+	listing := &_health.Check{Name: "agents", Probe: func(ctx context.Context) error {
+		checks, err := agentChecks(ctx)
+		optional = append(optional, checks...)
+		return err
+	}}
+	statuses = append(statuses, _health.Probe(reqCtx, listing, _health.DefaultProbeTimeout))
+
+	if _db.HasReplica() {
+		optional = append(optional, &_health.Check{Name: "mysql-replica", Probe: _db.PingReplica, TTL: _health.DefaultProbeTTL})
+	}
+
+	optionalStatuses, _ := _health.ProbeAll(reqCtx, optional, _health.DefaultProbeTimeout)
+	writeHealth(ctx, append(statuses, optionalStatuses...), ok)
+}
+
+func writeHealth(ctx *gin.Context, statuses []*_health.Status, ok bool) {
+	if ok {
+		ctx.JSON(http.StatusOK, healthRsp{Status: healthOk, Checks: statuses})
+	} else {
+		ctx.JSON(http.StatusServiceUnavailable, healthRsp{Status: healthUnavailable, Checks: statuses})
+	}
+}
+
+// 返回就绪检查的各项。使用内存后端时没有Redis，不检查Redis。
+func readinessChecks() []*_health.Check {
+	result := []*_health.Check{
+		{Name: "mysql", Probe: _db.Ping, TTL: _health.DefaultProbeTTL},
+		{Name: "carrier-table", Probe: probeCarrierTable, TTL: _health.DefaultProbeTTL},
+		{Name: "queue", Probe: probeQueue, TTL: _health.DefaultProbeTTL},
+	}
+	if _redisclient.Client() != nil {
+		result = append(result, &_health.Check{Name: "redis", Probe: _redisclient.Ping, TTL: _health.DefaultProbeTTL})
+	}
+
+	return result
+}
+
+// 检查运输商表中是否有可用的运输商。没有可用的运输商时无法处理任何查询。
+// 运输商信息没有缓存，每次查询都从数据库读取，所以检查的是数据库中的运输商表。
+func probeCarrierTable(ctx context.Context) error {
+	if n, err := _db.CountCarriers(ctx); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("no carrier available")
+	}
+
+	return nil
+}
+
+// 检查每个优先级的查询队列是否已满。队列已满时新的查询对象会被拒绝。
+func probeQueue(ctx context.Context) error {
+	maxLength := _rpcclient.MaxQueueLength()
+	full := make([]string, 0)
+	for _, p := range []_types.Priority{_types.PriorityHighest, _types.PriorityHigh, _types.PriorityLow} {
+		if n, err := _rpcclient.QueueLength(ctx, p); err != nil {
+			return err
+		} else if n >= maxLength {
+			full = append(full, fmt.Sprintf("%s=%d", p.String(), n))
+		}
+	}
+
+	if len(full) != 0 {
+		return fmt.Errorf("queue is full (max %d): %s", maxLength, strings.Join(full, ", "))
+	}
+
+	return nil
+}
+
+// 返回检查所有生效的查询代理的各项。
+// 相同地址（协议、主机和端口）的查询代理只检查一次，收到任何HTTP响应即认为可以访问，除非是5xx。
+func agentChecks(ctx context.Context) ([]*_health.Check, error) {
+	agentUrls, err := _db.QueryAgentUrls(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string][]string)
+	for _, au := range agentUrls {
+		u, err := _url.Parse(strings.TrimSpace(au.Url))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+
+		baseUrl := u.Scheme + "://" + u.Host
+		names[baseUrl] = append(names[baseUrl], au.Kind+":"+au.Name)
+	}
+
+	result := make([]*_health.Check, 0, len(names))
+	for baseUrl, ns := range names {
+		sort.Strings(ns)
+		baseUrl := baseUrl
+		result = append(result, &_health.Check{
+			Name:  fmt.Sprintf("agent %s (%s)", baseUrl, strings.Join(ns, ", ")),
+			Probe: func(ctx context.Context) error { return probeAgent(ctx, baseUrl) },
+			TTL:   _health.DefaultProbeTTL,
+		})
+	}
+
+	return result, nil
+}
+
+func probeAgent(ctx context.Context, baseUrl string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := agentProbeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("http status %d", rsp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// 该模块定义了查询接口服务的指标：每个接口和客户端的请求数和延迟、跟踪结果的来源、运输商匹配的覆盖率和未能返回结果的运单数。
+// @Author: agent
+// @Created: 2026-10-18
+package rpc
+
+import (
+	"strconv"
+	"time"
+
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_types "com.cne/ai-tracking-search/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	clientIdKey string = "clientId" // 请求上下文中保存已校验的客户端ID的键。
+
+	anonymousClient string = "anonymous" // 没有提供客户端ID的请求的客户端标签。
+	unknownClient   string = "-"         // 没有校验客户端ID的请求（包括非法的请求）的客户端标签。
+	unmatchedRoute  string = "unmatched" // 没有匹配到路由的请求的接口标签。
+)
+
+var (
+	httpRequests       *_metrics.CounterVec   // 每个接口和客户端的请求数。
+	httpRequestSeconds *_metrics.HistogramVec // 每个接口和客户端的请求延迟。
+	orderResults       *_metrics.CounterVec   // 每个来源返回的运单跟踪结果数，用于计算数据库命中率。
+	orderEmptyResults  *_metrics.Counter      // 既不能从数据库，也不能从查询代理获取跟踪结果的运单数。
+	carrierMatches     *_metrics.CounterVec   // 匹配运输商时匹配到和没有匹配到运单规则的运单数。
+)
+
+func init() {
+	httpRequests = _metrics.NewCounterVec("tracking_http_requests_total", "Number of HTTP requests by endpoint, client and status code.", "endpoint", "client", "code")
+	httpRequestSeconds = _metrics.NewHistogramVec("tracking_http_request_seconds", "Seconds spent serving HTTP requests by endpoint and client.", []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 15, 30}, "endpoint", "client")
+	orderResults = _metrics.NewCounterVec("tracking_order_result_total", "Number of tracking results returned by source.", "source")
+	orderEmptyResults = _metrics.NewCounter("tracking_order_empty_result_total", "Number of orders answered with an empty timeout result.")
+	carrierMatches = _metrics.NewCounterVec("tracking_carrier_match_total", "Number of tracking numbers matched or unmatched by carrier rules.", "result")
+}
+
+// 记录每个请求的接口、客户端、状态码和延迟。
+// 接口使用路由的路径，避免路径参数或者非法的路径产生过多的标签值。
+func withMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		endpoint := ctx.FullPath()
+		if endpoint == "" {
+			endpoint = unmatchedRoute
+		}
+
+		client := unknownClient
+		if v, ok := ctx.Get(clientIdKey); ok {
+			if client = v.(string); client == "" {
+				client = anonymousClient
+			}
+		}
+
+		httpRequests.With(endpoint, client, strconv.Itoa(ctx.Writer.Status())).Inc()
+		httpRequestSeconds.With(endpoint, client).Observe(time.Since(start).Seconds())
+	}
+}
+
+// 返回跟踪结果来源的标签值。
+func sourceLabel(src _types.TrackingResultSrc) string {
+	switch src {
+	case _types.SrcDB:
+		return "db"
+	case _types.SrcAPI:
+		return "api"
+	case _types.SrcCrawler:
+		return "crawler"
+	default:
+		return "unknown"
+	}
+}
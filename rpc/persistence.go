@@ -0,0 +1,76 @@
+// 该模块定义了查询接口服务的异步持久化管道。
+// @Author: agent
+// @Created: 2026-10-18
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	_config "com.cne/ai-tracking-search/config"
+	_db "com.cne/ai-tracking-search/db"
+	_pipeline "com.cne/ai-tracking-search/pipeline"
+)
+
+const (
+	trackingLogPipeline string = "tracking-log" // 查询日志的持久化管道的名字。
+
+	writeTimeout time.Duration = 30 * time.Second // 每批写入的超时时间，超时的记录被溢出到磁盘。
+)
+
+var (
+	logPipeline *_pipeline.Pipeline // 保存查询日志的持久化管道。
+)
+
+// 初始化查询接口服务的持久化管道。
+// persistence 持久化配置。
+func InitPersistence(persistence *_config.PersistenceConfiguration) error {
+	options := _pipeline.Options{
+		QueueSize:       persistence.QueueSize,
+		BatchSize:       persistence.BatchSize,
+		FlushInterval:   time.Duration(persistence.FlushInterval) * time.Millisecond,
+		Writers:         persistence.Writers,
+		JournalDir:      persistence.JournalDir,
+		JournalMaxBytes: persistence.JournalMaxBytes,
+		ReplayInterval:  time.Duration(persistence.ReplayInterval) * time.Second,
+	}
+
+	if p, err := _pipeline.New(trackingLogPipeline, options, writeTrackingLogs, decodeTrackingLog); err != nil {
+		return err
+	} else {
+		logPipeline = p
+		return nil
+	}
+}
+
+// 关闭查询接口服务的持久化管道，等待内存队列中的查询日志写入完毕。
+// ctx 上下文，决定了等待的截止时间。超过截止时间仍未写入的查询日志被溢出到磁盘。
+func ClosePersistence(ctx context.Context) error {
+	if logPipeline == nil {
+		return nil
+	}
+
+	return logPipeline.Close(ctx)
+}
+
+func writeTrackingLogs(items []interface{}) error {
+	logs := make([]*_db.TrackingLogPo, 0, len(items))
+	for _, item := range items {
+		logs = append(logs, item.(*_db.TrackingLogPo))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	return _db.SaveTrackingLogs(ctx, logs)
+}
+
+func decodeTrackingLog(data []byte) (interface{}, error) {
+	result := _db.TrackingLogPo{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
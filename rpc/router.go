@@ -0,0 +1,95 @@
+// 该模块定义了查询接口服务的路由表。
+// @Author: agent
+// @Created: 2026-10-18
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	_agent "com.cne/ai-tracking-search/agent"
+	_config "com.cne/ai-tracking-search/config"
+	_metrics "com.cne/ai-tracking-search/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	writeTimeoutMargin time.Duration = 5 * time.Second // 写入超时比请求的截止时间多出的时间，超过截止时间的请求仍然可以写入响应。
+)
+
+// 创建查询接口服务的HTTP服务器。
+// server 查询接口服务配置，其中的超时时间作为读取请求的超时时间和每个请求的截止时间，写入超时再加上 writeTimeoutMargin。
+func NewServer(server *_config.ServerConfiguration) *http.Server {
+	timeout := time.Duration(server.Timeout) * time.Second
+
+	return &http.Server{
+		Addr:         server.Listen,
+		Handler:      NewRouter(server),
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout + writeTimeoutMargin,
+	}
+}
+
+// 创建查询接口服务的路由。
+// server 查询接口服务配置，其中的超时时间作为每个请求的截止时间。
+func NewRouter(server *_config.ServerConfiguration) *gin.Engine {
+	router := gin.Default()
+	router.Use(withMetrics())
+	router.Use(withTimeout(time.Duration(server.Timeout) * time.Second))
+
+	// 路由表
+	router.POST("/carriers", Carriers)
+	router.POST("/match-carriers", MatchCarriers)
+	router.POST("/trackings", Trackings)
+
+	router.POST("/carrierlist", Carriers)
+	router.POST("/matchcarrier", MatchCarriers)
+	router.POST("/trackinglist", Trackings)
+
+	router.GET("/healthz", Healthz)
+	router.GET("/readyz", Readyz)
+
+	return router
+}
+
+// 创建输出指标和依赖诊断的内部HTTP服务器。
+// 依赖诊断会暴露数据库、Redis和查询代理的地址和错误，指标会暴露运输商和查询代理的名字，所以不在对外的端口上提供。
+// server 查询接口服务配置，没有配置内部绑定地址时返回nil。
+func NewInternalServer(server *_config.ServerConfiguration) *http.Server {
+	if server.InternalListen == "" {
+		return nil
+	}
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/healthz", Healthz)
+	router.GET("/diagnostics", Diagnostics)
+	router.GET("/metrics", gin.WrapH(_metrics.Handler(_agent.CollectQueueDepth)))
+
+	timeout := time.Duration(server.Timeout) * time.Second
+	return &http.Server{
+		Addr:         server.InternalListen,
+		Handler:      router,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout + writeTimeoutMargin,
+	}
+}
+
+// 为每个请求的上下文设置截止时间。客户端断开连接或者超过截止时间后，请求引起的数据库、缓存和队列操作会被取消。
+// timeout 请求的超时时间，0表示不设置截止时间。
+func withTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
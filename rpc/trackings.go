@@ -4,16 +4,19 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	_agent "com.cne/ai-tracking-search/agent"
+	_config "com.cne/ai-tracking-search/config"
 	_db "com.cne/ai-tracking-search/db"
+	_freshness "com.cne/ai-tracking-search/freshness"
 	_rpcclient "com.cne/ai-tracking-search/rpcclient"
 	_types "com.cne/ai-tracking-search/types"
 	_utils "com.cne/ai-tracking-search/utils"
@@ -24,9 +27,21 @@ import (
 )
 
 const (
-	maxBatchSize int = 30 // 每个原始请求中允许包含的最多运单号。
+	modeDefault              string = ""                       // 等待查询代理返回最新的结果。
+	modeStaleWhileRevalidate string = "stale-while-revalidate" // 立刻返回数据库中已过期的跟踪记录，并在后台刷新。
 )
 
+var (
+	maxOrders int64 = int64(_config.DefaultSearchMaxOrders) // 每个原始请求中允许包含的最多运单号，使用原子操作访问。
+)
+
+// 修改处理查询请求的参数，可以在运行时调用，只影响之后收到的请求。
+// search 查询配置。
+func ConfigureSearch(search *_config.SearchConfiguration) {
+	atomic.StoreInt64(&maxOrders, int64(search.MaxOrders))
+	_rpcclient.ConfigureSearch(search)
+}
+
 // 表示查询请求。
 type trackingsReq struct {
 	CarrierCode string              `json:"carrierCode" binding:"required"` // 运输商代号。
@@ -35,6 +50,7 @@ type trackingsReq struct {
 	Language    _types.LangId       `json:"language"`                       // 期望返回的语言。
 	Priority    _types.Priority     `json:"priority"`                       // 优先级(0-2)。
 	Token       string              `json:"token"`                          // 和客户端ID对应的鉴权标记。
+	Mode        string              `json:"mode"`                           // 请求模式，可以为空或者`stale-while-revalidate`。
 	Orders      []*trackingOrderReq `json:"orders"`                         // 请求包含的所有待查询运单。
 }
 
@@ -54,16 +70,26 @@ type trackingsRsp struct {
 
 // 表示查询响应中的一条运单。
 type trackingOrderRsp struct {
-	TrackingNo   string              `json:"trackingNo"`   // 运单号。
-	SeqNo        string              `json:"seqNo"`        // 查询流水号。
-	State        int                 `json:"state"`        // 查询状态，即查询代理是否返回了能够解析的查询结果（即使查询结果为空）。
-	Message      string              `json:"message"`      // 运单状态对应的文本。
-	Delivered    bool                `json:"delivered"`    // 是否已妥投。1表示已妥投，0表示未妥投。
-	DeliveryDate string              `json:"deliveryDate"` // 妥投的时间。
-	Destination  string              `json:"destination"`  // 妥投的目的地。
-	Cached       bool                `json:"cached"`       // 此响应是否来自于缓存。
-	CachedTime   string              `json:"cachedTime"`   // 此响应的缓存时间（UTC）。
-	Events       []*trackingEventRsp `json:"events"`       // 运单包含的事件。
+	TrackingNo   string              `json:"trackingNo"`          // 运单号。
+	SeqNo        string              `json:"seqNo"`               // 查询流水号。
+	State        int                 `json:"state"`               // 查询状态，即查询代理是否返回了能够解析的查询结果（即使查询结果为空）。
+	Message      string              `json:"message"`             // 运单状态对应的文本。
+	Delivered    bool                `json:"delivered"`           // 是否已妥投。1表示已妥投，0表示未妥投。
+	DeliveryDate string              `json:"deliveryDate"`        // 妥投的时间。
+	Destination  string              `json:"destination"`         // 妥投的目的地。
+	Cached       bool                `json:"cached"`              // 此响应是否来自于缓存。
+	CachedTime   string              `json:"cachedTime"`          // 此响应的缓存时间（UTC）。
+	Stale        bool                `json:"stale"`               // 此响应是否来自于已过期的缓存，此时后台正在刷新。
+	Events       []*trackingEventRsp `json:"events"`              // 运单包含的事件。
+	Freshness    *freshnessRsp       `json:"freshness,omitempty"` // 有效期策略的决定。
+}
+
+// 表示查询响应中的有效期策略的决定。
+type freshnessRsp struct {
+	Decision    string `json:"decision"`    // `db`表示直接返回数据库中的跟踪记录，`refresh`表示调用了查询代理刷新。
+	State       string `json:"state"`       // 数据库中的跟踪记录的运单状态，没有跟踪记录时为空。
+	Rule        string `json:"rule"`        // 生效的规则。
+	NextRefresh string `json:"nextRefresh"` // 允许再次调用查询代理的时间（UTC），为空表示永不刷新。
 }
 
 // 表示查询响应中的事件。
@@ -86,6 +112,10 @@ func Trackings(ctx *gin.Context) {
 	}
 
 	validateReq(&req)
+	ctx.Set(clientIdKey, req.ClientId)
+
+	// 客户端断开连接或者超时后，取消请求引起的数据库、缓存和队列操作。
+	reqCtx := ctx.Request.Context()
 
 	// 为每个运单号构造一个查询对象。
 	trackingSearchList1 := make([]*_rpcclient.TrackingSearch, 0, len(req.Orders))
@@ -100,31 +130,44 @@ func Trackings(ctx *gin.Context) {
 	}
 
 	// 从数据库中加载。
-	loadTrackingResultFromDb(trackingSearchList1)
+	loadTrackingResultFromDb(reqCtx, trackingSearchList1)
 
 	// t1 := time.Time{}
 	// 未妥投的记录（包含数据库中查不到的记录），都需要通过查询代理爬取。
 	// 将需要调用查询代理的记录推送到任务队列。
+	// 如果允许返回已过期的跟踪记录，那么数据库中已有跟踪记录的查询对象在后台刷新，不再等待。
+	waitList := trackingSearchList1
+	if req.Mode == modeStaleWhileRevalidate {
+		staleList := make([]*_rpcclient.TrackingSearch, 0, len(trackingSearchList1))
+		waitList = make([]*_rpcclient.TrackingSearch, 0, len(trackingSearchList1))
+		for _, ts := range trackingSearchList1 {
+			if ts.Src == _types.SrcDB {
+				staleList = append(staleList, ts)
+			} else {
+				waitList = append(waitList, ts)
+			}
+		}
+
+		revalidate(reqCtx, _types.Priority(req.Priority), staleList)
+	}
+
 	var trackingSearchList2 []*_rpcclient.TrackingSearch = make([]*_rpcclient.TrackingSearch, 0)
-	if keys, err := _rpcclient.PushTrackingSearchToQueue(_types.Priority(req.Priority), trackingSearchList1); err != nil {
+	if replyKey, keys, err := _rpcclient.PushTrackingSearchToQueue(reqCtx, _types.Priority(req.Priority), waitList); err != nil {
 		// 推送查询对象到任务队列失败，放弃轮询缓存和拉取查询对象。
 	} else {
 		// 从缓存拉取查询对象（以及查询结果）。
-		if trackingSearchList, err := _rpcclient.PullTrackingSearchFromCache(_types.Priority(req.Priority), keys); err != nil {
+		if trackingSearchList, err := _rpcclient.PullTrackingSearchFromCache(reqCtx, _types.Priority(req.Priority), replyKey, keys); err != nil {
+			if reqCtx.Err() != nil {
+				// 客户端已经断开连接或者请求已经超时，不再返回响应。
+				log.Printf("[INFO] Tracking request stopped. cause=%s\n", reqCtx.Err())
+				ctx.AbortWithStatus(http.StatusGatewayTimeout)
+				return
+			}
 			panic(err)
 		} else {
 			// 匹配跟踪结果中的事件。
-			matchAllEvents(trackingSearchList)
-
-			// t1 = time.Now()
-			// 来自查询代理的查询结果会被保存到数据库。
-			// saveTrackingResultToDb(trackingSearchList) // 同步保存到数据库。
-
-			go func() {
-				defer _utils.RecoverPanic()
-
-				saveTrackingResultToDb(trackingSearchList)
-			}()
+			// 来自查询代理的查询结果由查询代理工作进程保存到数据库，即使客户端已经超时也不会丢失。
+			_rpcclient.MatchAllEvents(reqCtx, trackingSearchList)
 
 			trackingSearchList2 = append(trackingSearchList2, trackingSearchList...)
 		}
@@ -135,6 +178,27 @@ func Trackings(ctx *gin.Context) {
 	// fmt.Printf("!!! %s\n", time.Now().Sub(t1))
 }
 
+// 在后台刷新数据库中的跟踪记录。
+// 仍然有效的跟踪记录不会被刷新，需要刷新的跟踪记录被标记为已过期。
+// 刷新的结果由查询代理工作进程保存到数据库，供之后的请求使用，所以此处只需要推送到任务队列，不需要等待。
+// ctx 请求的上下文。
+// priority 优先级。
+// trackingSearchList 已从数据库中读取跟踪记录的查询对象。
+func revalidate(ctx context.Context, priority _types.Priority, trackingSearchList []*_rpcclient.TrackingSearch) {
+	if len(trackingSearchList) == 0 {
+		return
+	}
+
+	if _, err := _rpcclient.PushTrackingSearchToQueueWithoutReply(ctx, priority, trackingSearchList); err != nil {
+		log.Printf("[WARN] Cannot revalidate tracking results. cause=%s\n", err)
+		return
+	}
+
+	for _, ts := range trackingSearchList {
+		ts.Stale = ts.Freshness != nil && !ts.Freshness.Fresh
+	}
+}
+
 // 验证请求参数是否合乎接口定义。
 // req 待验证的请求参数。
 func validateReq(req *trackingsReq) {
@@ -156,6 +220,12 @@ func validateReq(req *trackingsReq) {
 		}
 	}
 
+	// 校验请求模式。
+	req.Mode = strings.ToLower(strings.TrimSpace(req.Mode))
+	if req.Mode != modeDefault && req.Mode != modeStaleWhileRevalidate {
+		panic(fmt.Errorf("illegal mode: %s", req.Mode))
+	}
+
 	// 校验运输商编号。
 	req.CarrierCode = strings.ToLower(strings.TrimSpace(req.CarrierCode))
 	if req.CarrierCode == "" {
@@ -176,7 +246,7 @@ func validateReq(req *trackingsReq) {
 
 	if len(req.Orders) == 0 {
 		panic(fmt.Errorf("orders cannot be empty"))
-	} else if len(req.Orders) > maxBatchSize {
+	} else if int64(len(req.Orders)) > atomic.LoadInt64(&maxOrders) {
 		panic(fmt.Errorf("too many orders: [%d]", len(req.Orders)))
 	}
 }
@@ -190,15 +260,26 @@ func loadClientSecret(clientId string) (string, error) {
 }
 
 // 从数据库中读取跟踪记录。
-// trackingSearchList 待读取相应跟踪记录的查询对象。每个对象都要到数据库中查询一次。
-func loadTrackingResultFromDb(trackingSearchList []*_rpcclient.TrackingSearch) {
-	for i, ts := range trackingSearchList {
+// ctx 请求的上下文。
+// trackingSearchList 待读取相应跟踪记录的查询对象。所有对象的跟踪记录只需要一次查询。
+func loadTrackingResultFromDb(ctx context.Context, trackingSearchList []*_rpcclient.TrackingSearch) {
+	keys := make([]_db.TrackingResultKey, 0, len(trackingSearchList))
+	for _, ts := range trackingSearchList {
 		// 跳过空单号，这种查询请求是不合法的。
+		if ts.TrackingNo != "" {
+			keys = append(keys, _db.TrackingResultKey{CarrierCode: ts.CarrierCode, Language: ts.Language, TrackingNo: ts.TrackingNo})
+		}
+	}
+
+	// 所有运单的跟踪记录通过一次查询读取。
+	trs := _db.QueryTrackingResultsByTrackingNos(ctx, keys)
+
+	for i, ts := range trackingSearchList {
 		if ts.TrackingNo == "" {
 			continue
 		}
 
-		tr := _db.QueryTrackingResultByTrackingNo(ts.CarrierCode, ts.Language, ts.TrackingNo)
+		tr := trs[_db.TrackingResultKey{CarrierCode: ts.CarrierCode, Language: ts.Language, TrackingNo: ts.TrackingNo}]
 
 		if tr != nil {
 			ts.Src = _types.SrcDB
@@ -224,36 +305,8 @@ func loadTrackingResultFromDb(trackingSearchList []*_rpcclient.TrackingSearch) {
 	}
 }
 
-func saveTrackingResultToDb(trackingSearchList []*_rpcclient.TrackingSearch) {
-	now := time.Now()
-	for _, ts := range trackingSearchList {
-		var eventsJson string
-		if len(ts.Events) == 0 {
-			eventsJson = ""
-		} else {
-			if eventsJsonBytes, err := json.Marshal(ts.Events); err != nil {
-				panic(err)
-			} else {
-				eventsJson = string(eventsJsonBytes)
-			}
-		}
-
-		carrierPo := _db.QueryCarrierByCode(ts.CarrierCode)
-		if carrierPo != nil {
-			if _db.SaveTrackingResult(carrierPo.Id, ts.Language, ts.TrackingNo, eventsJson, now, ts.Done) <= 0 {
-				log.Printf("[INFO] Duplicated tracking result(carrier-code=%s, language=%s, tracking-no=%s\n", ts.CarrierCode, ts.Language.String(), ts.TrackingNo)
-				continue
-			}
-
-			_db.DeleteTracking(carrierPo.Id, ts.Language, ts.TrackingNo)
-			trackingId := _db.SaveTrackingToDb(carrierPo.Id, ts.Language, ts.TrackingNo, ts.DoneTime, ts.DonePlace, ts.Src, ts.AgentName, now, ts.Done)
-			for _, event := range ts.Events {
-				_db.SaveTrackingDetailToDb(trackingId, event.Date, event.Place, event.Details, event.State, now)
-			}
-		}
-	}
-}
-
+// 提交查询日志到持久化管道，由写入协程异步保存到数据库。
+// trackingSearchList 需要记录日志的查询对象。
 func saveLogToDb(trackingSearchList []*_rpcclient.TrackingSearch) {
 	now := time.Now()
 	operator := "auto"
@@ -295,80 +348,10 @@ func saveLogToDb(trackingSearchList []*_rpcclient.TrackingSearch) {
 
 		timing = endTime.Sub(ts.ReqTime).Milliseconds()
 
-		carrierPo := _db.QueryCarrierByCode(ts.CarrierCode)
-		carrierId := int64(0)
-		countryId := 0
-		if carrierPo != nil {
-			// 没有匹配到运输商。但是也应该记录日志。
-			carrierId = carrierPo.Id
-			countryId = carrierPo.CountryId
-		}
-		_db.SaveTrackingLog(ts.ClientId, carrierId, ts.TrackingNo, matchType, countryId, int(timing), ts.ClientAddr, resultStatus, now, ts.Src, now, operator,
-			ts.ReqTime, ts.AgentStartTime, ts.AgentEndTime, ts.AgentRawText, resultNote)
-	}
-}
-
-// 匹配查询对象集合中包含的事件。
-// trackingSearchList 待匹配的查询对象集合。
-func matchAllEvents(trackingSearchList []*_rpcclient.TrackingSearch) {
-	for i, ts := range trackingSearchList {
-		rules := _db.QueryMatchRuleByCarrierCode(ts.CarrierCode, ts.ReqTime)
-
-		matchEvents(rules, ts)
-		trackingSearchList[i] = ts
-	}
-}
-
-// 匹配查询对象中的事件。
-// rules 关联的匹配规则。
-// 待匹配的查询对象。
-func matchEvents(rules []*_db.MatchRulePo, ts *_rpcclient.TrackingSearch) {
-	// 按事件排序。
-	sort.Stable(ts.Events)
-
-	for i, evt := range ts.Events {
-		// 两次遍历，第一次针对查询代理类别的规则进行匹配。
-		matched := false
-		delivered := false
-		evt.State = 2
-		for _, rule := range rules {
-			if rule.TargetType != "4" && rule.Match(evt.Details) {
-				evt.State, delivered = matchRuleCodeToState(rule.Code)
-				matched = true
-				break
-			}
-		}
-
-		if !matched {
-			// 第二次针对运输商类别的规则进行匹配。
-			for _, rule := range rules {
-				if rule.TargetType == "4" && rule.Match(evt.Details) {
-					evt.State, delivered = matchRuleCodeToState(rule.Code)
-					break
-				}
-			}
-		}
-		ts.Events[i] = evt
-
-		// 如果某个事件匹配到了已妥投，那么设置整个查询对象的状态为已妥投，并设置妥投时间和妥投地点。
-		if delivered {
-			ts.Done = true
-			ts.DoneTime = evt.Date
-			ts.DonePlace = evt.Place
-		}
-	}
-}
-
-// 将匹配规则代码映射为响应结果中的状态码。
-// code 匹配规则代码。
-// 返回对应的响应结果状态码，以及是否映射成功。
-func matchRuleCodeToState(code string) (int, bool) {
-	if code == "Delivered" {
-		return 3, true // 表示已妥投。
-	} else if code == "Undelivered" {
-		return 8, false // 表示投递失败。
-	} else {
-		return 2, false // 表示状态未知。
+		// 没有匹配到运输商的查询也应该记录日志。
+		logPipeline.Submit(&_db.TrackingLogPo{ClientId: ts.ClientId, CarrierCode: ts.CarrierCode, TrackingNo: ts.TrackingNo, MatchType: matchType, Timing: int(timing), Host: ts.ClientAddr,
+			ResultStatus: resultStatus, StatisticsDate: now, CollectorType: ts.Src, DatePoint: now, Creator: operator,
+			RequestTime: ts.ReqTime, CrawlerStartTime: ts.AgentStartTime, CrawlerEndTime: ts.AgentEndTime, CrawlerRespBody: ts.AgentRawText, ResultNote: resultNote})
 	}
 }
 
@@ -406,17 +389,16 @@ func buildTrackingsRsp(orders []*trackingOrderReq, r1, r2 []*_rpcclient.Tracking
 				ts_ = ts2
 			}
 		}
-		data = append(data, buildTrackingOrderResult(ts_))
+		orderResults.With(sourceLabel(ts_.Src)).Inc()
+		orderRsp := buildTrackingOrderResult(ts_)
+		if ok1 {
+			orderRsp.Freshness = buildFreshnessRsp(ts1.Freshness)
+		}
+		data = append(data, orderRsp)
 		logList = append(logList, ts_)
 	}
 
-	// saveLogToDb(logList) // 同步保存到数据库。
-
-	go func() {
-		defer _utils.RecoverPanic()
-
-		saveLogToDb(logList)
-	}()
+	saveLogToDb(logList)
 
 	result := trackingsRsp{Data: data}
 	result.Status = rSuccess
@@ -467,6 +449,7 @@ func buildTrackingOrderResult(trackingSearch *_rpcclient.TrackingSearch) *tracki
 		Destination:  "",
 		Cached:       cached,
 		CachedTime:   cachedTime,
+		Stale:        cached && trackingSearch.Stale,
 		Events:       events,
 	}
 
@@ -485,11 +468,31 @@ func buildTrackingOrderResult(trackingSearch *_rpcclient.TrackingSearch) *tracki
 	return &result
 }
 
+// 根据有效期策略的决定构造响应对象。
+// decision 有效期策略的决定，如果是nil那么返回nil。
+func buildFreshnessRsp(decision *_freshness.Decision) *freshnessRsp {
+	if decision == nil {
+		return nil
+	}
+
+	result := freshnessRsp{Decision: "refresh", State: string(decision.State), Rule: decision.Rule}
+	if decision.Fresh {
+		result.Decision = "db"
+	}
+	if !decision.NextRefresh.IsZero() {
+		result.NextRefresh = _utils.FormatTime(decision.NextRefresh.In(time.UTC))
+	}
+
+	return &result
+}
+
 // 构造一个空的表示无效的跟踪记录。
 // TMS 调用端要求返回结果中的运单记录和请求中的运单记录数量、顺序保持一致。
 // 如果既不能从数据库，也不能从查询代理获取跟踪结果，那么调用此方法生成一个。
 // trackingNo 运单号。
 func buildEmptyTrackingOrderResult(trackingNo string) *trackingOrderRsp {
+	orderEmptyResults.Inc()
+
 	return &trackingOrderRsp{
 		TrackingNo:   trackingNo,
 		SeqNo:        "",
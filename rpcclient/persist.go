@@ -0,0 +1,335 @@
+// 该模块定义了保存查询代理结果的方法。
+// 查询代理工作进程完成查询对象后，将结果推送到完成队列，持久化协程从完成队列中获取结果，匹配事件并保存到数据库。
+// 这样即使查询接口服务已经不再等待（比如客户端超时），查询代理的结果仍然会被保存。
+// @Author: agent
+// @Created: 2026-10-18
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_agent "com.cne/ai-tracking-search/agent"
+	_config "com.cne/ai-tracking-search/config"
+	_db "com.cne/ai-tracking-search/db"
+	_metrics "com.cne/ai-tracking-search/metrics"
+	_queue "com.cne/ai-tracking-search/queue"
+	_utils "com.cne/ai-tracking-search/utils"
+)
+
+const (
+	trackingCompletedTopic string = trackingQueueKey + "$Completed" // 完成队列的主题。
+
+	persistPollTimeout  time.Duration = 5 * time.Second // 阻塞出队的超时时间。
+	persistErrorBackoff time.Duration = 1 * time.Second // 队列不可用时重试的间隔。
+	persistPushBack     time.Duration = 5 * time.Second // 退出时将未保存完毕的完成通知放回队列的超时时间。
+)
+
+var (
+	persistCtx     context.Context    // 持久化协程访问队列和数据库时使用的上下文。
+	persistPollCtx context.Context    // 持久化协程出队时使用的上下文，开始退出时被取消。
+	stopPersisting context.CancelFunc // 取消 persistPollCtx 的方法。
+
+	persisters       sync.WaitGroup   // 所有的持久化协程。
+	persistsInflight *_queue.Inflight // 已出队但是尚未保存完毕的完成通知。
+
+	completionsPersisted *_metrics.Counter // 已保存到数据库的查询代理结果数。
+	completionsSkipped   *_metrics.Counter // 因为查询代理没有返回有效结果而没有保存的结果数。
+	completionsUnknown   *_metrics.Counter // 因为运输商不存在而无法保存的结果数。
+)
+
+func init() {
+	persistCtx = context.Background()
+	persistPollCtx, stopPersisting = context.WithCancel(context.Background())
+	persistsInflight = _queue.NewInflight()
+
+	completionsPersisted = _metrics.NewCounter("tracking_completion_persisted_total", "Number of completed tracking searches persisted to the database.")
+	completionsSkipped = _metrics.NewCounter("tracking_completion_skipped_total", "Number of completed tracking searches skipped because the agent returned no valid result.")
+	completionsUnknown = _metrics.NewCounter("tracking_completion_unknown_carrier_total", "Number of completed tracking searches not persisted because the carrier is unknown.")
+}
+
+// 启动持久化协程。
+// 完成通知和查询对象使用相同的可见性超时和最大投递次数。
+// worker 查询代理工作进程配置。
+func StartPersisters(worker *_config.WorkerConfiguration) error {
+	concurrency, maxDeliveries := worker.Persisters, worker.MaxDeliveries
+	visibilityTimeout := time.Duration(worker.VisibilityTimeout) * time.Second
+	if concurrency <= 0 {
+		return fmt.Errorf("persisters should be positive, but %d", concurrency)
+	} else if maxDeliveries <= 0 {
+		return fmt.Errorf("max deliveries should be positive, but %d", maxDeliveries)
+	} else if visibilityTimeout <= 0 {
+		return fmt.Errorf("visibility timeout should be positive, but %s", visibilityTimeout)
+	}
+
+	if err := _queue.Declare(persistCtx, trackingCompletedTopic); err != nil {
+		log.Printf("[WARN] Cannot declare queue %s. cause=%s\n", trackingCompletedTopic, err)
+	}
+
+	go func() {
+		for {
+			time.Sleep(visibilityTimeout / 4)
+
+			if requeued, dead, err := _queue.Reclaim(persistCtx, trackingCompletedTopic, visibilityTimeout, maxDeliveries); err != nil {
+				log.Printf("[WARN] Cannot reclaim completions. cause=%s\n", err)
+			} else if requeued > 0 || dead > 0 {
+				log.Printf("[WARN] Reclaimed completions, %d redelivered, %d moved to dead-letter queue\n", requeued, dead)
+			}
+		}
+	}()
+
+	for i := 0; i < concurrency; i++ {
+		persisters.Add(1)
+		go persistWorker(maxDeliveries)
+	}
+
+	return nil
+}
+
+// 停止持久化协程，等待正在保存的完成通知保存完毕。
+// ctx 上下文，决定了等待的截止时间。
+// 超过截止时间仍未保存完毕的完成通知被放回队列，由其它进程保存。
+func StopPersisters(ctx context.Context) error {
+	stopPersisting()
+
+	stopped := make(chan struct{})
+	go func() {
+		persisters.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+
+	pushBackCtx, cancel := context.WithTimeout(context.Background(), persistPushBack)
+	defer cancel()
+
+	n, err := persistsInflight.NackAll(pushBackCtx)
+	log.Printf("[WARN] Pushed back %d completions still being persisted at shutdown\n", n)
+	if err != nil {
+		return fmt.Errorf("cannot push back completions: %w", err)
+	}
+
+	return nil
+}
+
+func persistWorker(maxDeliveries int) {
+	defer persisters.Done()
+
+	for persistPollCtx.Err() == nil {
+		func() {
+			defer _utils.RecoverPanic()
+
+			m, err := _queue.BPop(persistPollCtx, persistPollTimeout, trackingCompletedTopic)
+			if err != nil {
+				if persistPollCtx.Err() != nil {
+					// 正在退出。
+					return
+				}
+				if !errors.Is(err, _queue.Nil) {
+					log.Printf("[ERROR] Cannot poll completion from queue. cause=%s\n", err)
+					time.Sleep(persistErrorBackoff)
+				}
+				return
+			}
+			if persistPollCtx.Err() != nil {
+				// 开始退出时阻塞出队刚好返回了完成通知，放回队列由其它进程保存。
+				if err := _queue.Nack(persistCtx, m); err != nil {
+					log.Printf("[WARN] Cannot push back completion(id=%s). cause=%s\n", m.Id, err)
+				}
+				return
+			}
+
+			// 如果保存过程中发生panic（比如数据库不可用），那么报告失败，完成通知稍后会被重新投递。
+			persistsInflight.Add(m)
+			defer persistsInflight.Done(m)
+			done := false
+			defer func() {
+				if !done {
+					if _, err := _queue.Fail(persistCtx, m, "persist panicked", maxDeliveries); err != nil {
+						log.Printf("[WARN] Cannot report failure of completion(id=%s). cause=%s\n", m.Id, err)
+					}
+				}
+			}()
+
+			persistCompletion(m.Value)
+			done = true
+
+			if err := _queue.Ack(persistCtx, m); err != nil {
+				log.Printf("[WARN] Cannot ack completion(id=%s). cause=%s\n", m.Id, err)
+			}
+		}()
+	}
+}
+
+// 保存一个完成通知中的查询代理结果。
+// 只保存查询代理返回了有效结果的查询对象，避免用失败的结果覆盖数据库中的跟踪记录。
+// value 完成通知，是查询对象的字段组成的json对象。
+func persistCompletion(value string) {
+	fields := make(map[string]string)
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		log.Printf("[WARN] Cannot parse completion: %s. cause=%s\n", _utils.AbbrText(value, 255), err)
+		return
+	}
+
+	key := fields["key"]
+	if !strings.HasPrefix(key, trackingSearchKeyPrefix+"$") {
+		log.Printf("[WARN] Illegal key of completion: %s\n", key)
+		return
+	}
+
+	os := make([]interface{}, len(searchFields))
+	for i, f := range searchFields {
+		if v, ok := fields[f]; ok {
+			os[i] = v
+		}
+	}
+
+	ts := buildTrackingSearch(key, os)
+	if !_agent.IsSuccess(ts.AgentCode) {
+		completionsSkipped.Inc()
+		return
+	}
+
+	trackingSearchList := []*TrackingSearch{ts}
+	MatchAllEvents(persistCtx, trackingSearchList)
+	if saveTrackingResultToDb(persistCtx, trackingSearchList) > 0 {
+		completionsPersisted.Inc()
+	}
+}
+
+// 匹配查询对象集合中包含的事件。
+// 每个运输商的匹配规则只读取一次。查询对象集合来自同一个请求，所以使用第一个查询对象的请求时间选择生效的规则。
+// ctx 上下文。
+// trackingSearchList 待匹配的查询对象集合。
+func MatchAllEvents(ctx context.Context, trackingSearchList []*TrackingSearch) {
+	if len(trackingSearchList) == 0 {
+		return
+	}
+
+	rules := _db.QueryMatchRulesByCarrierCodes(ctx, CarrierCodes(trackingSearchList), trackingSearchList[0].ReqTime)
+
+	for i, ts := range trackingSearchList {
+		matchEvents(rules[ts.CarrierCode], ts)
+		trackingSearchList[i] = ts
+	}
+}
+
+// 匹配查询对象中的事件。
+// rules 关联的匹配规则。
+// 待匹配的查询对象。
+func matchEvents(rules []*_db.MatchRulePo, ts *TrackingSearch) {
+	// 按事件排序。
+	sort.Stable(ts.Events)
+
+	for i, evt := range ts.Events {
+		// 两次遍历，第一次针对查询代理类别的规则进行匹配。
+		matched := false
+		delivered := false
+		evt.State = 2
+		for _, rule := range rules {
+			if rule.TargetType != "4" && rule.Match(evt.Details) {
+				evt.State, delivered = matchRuleCodeToState(rule.Code)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			// 第二次针对运输商类别的规则进行匹配。
+			for _, rule := range rules {
+				if rule.TargetType == "4" && rule.Match(evt.Details) {
+					evt.State, delivered = matchRuleCodeToState(rule.Code)
+					break
+				}
+			}
+		}
+		ts.Events[i] = evt
+
+		// 如果某个事件匹配到了已妥投，那么设置整个查询对象的状态为已妥投，并设置妥投时间和妥投地点。
+		if delivered {
+			ts.Done = true
+			ts.DoneTime = evt.Date
+			ts.DonePlace = evt.Place
+		}
+	}
+}
+
+// 将匹配规则代码映射为响应结果中的状态码。
+// code 匹配规则代码。
+// 返回对应的响应结果状态码，以及是否映射成功。
+func matchRuleCodeToState(code string) (int, bool) {
+	if code == "Delivered" {
+		return 3, true // 表示已妥投。
+	} else if code == "Undelivered" {
+		return 8, false // 表示投递失败。
+	} else {
+		return 2, false // 表示状态未知。
+	}
+}
+
+// 返回查询对象集合中的所有运输商号码，重复的运输商号码只返回一次。
+// trackingSearchList 查询对象集合。
+func CarrierCodes(trackingSearchList []*TrackingSearch) []string {
+	result := make([]string, 0, len(trackingSearchList))
+	seen := make(map[string]bool, len(trackingSearchList))
+	for _, ts := range trackingSearchList {
+		if !seen[ts.CarrierCode] {
+			seen[ts.CarrierCode] = true
+			result = append(result, ts.CarrierCode)
+		}
+	}
+
+	return result
+}
+
+// 保存查询对象集合中的查询代理结果。
+// 运输商不存在的查询对象无法保存，记录日志后跳过。
+// ctx 上下文。
+// trackingSearchList 已匹配事件的查询对象集合。
+// 返回保存的查询对象数，包括重复的跟踪结果。
+func saveTrackingResultToDb(ctx context.Context, trackingSearchList []*TrackingSearch) int {
+	saved := 0
+	now := time.Now()
+	carriers := _db.QueryCarriersByCodes(ctx, CarrierCodes(trackingSearchList))
+	for _, ts := range trackingSearchList {
+		var eventsJson string
+		if len(ts.Events) == 0 {
+			eventsJson = ""
+		} else {
+			if eventsJsonBytes, err := json.Marshal(ts.Events); err != nil {
+				panic(err)
+			} else {
+				eventsJson = string(eventsJsonBytes)
+			}
+		}
+
+		if carrierPo := carriers[ts.CarrierCode]; carrierPo != nil {
+			snapshot := _db.TrackingSnapshotPo{CarrierId: carrierPo.Id, Language: ts.Language, TrackingNo: ts.TrackingNo, EventsJson: eventsJson, Done: ts.Done, DoneTime: ts.DoneTime, DonePlace: ts.DonePlace,
+				CollectorType: ts.Src, CollectorRealName: ts.AgentName, Details: make([]*_db.TrackingDetailPo, 0, len(ts.Events))}
+			for _, event := range ts.Events {
+				snapshot.Details = append(snapshot.Details, &_db.TrackingDetailPo{Date: event.Date, Place: event.Place, Details: event.Details, State: event.State})
+			}
+
+			if !_db.SaveTrackingSnapshot(ctx, &snapshot, now) {
+				log.Printf("[INFO] Duplicated tracking result(carrier-code=%s, language=%s, tracking-no=%s\n", ts.CarrierCode, ts.Language.String(), ts.TrackingNo)
+			}
+			saved++
+		} else {
+			log.Printf("[WARN] Cannot save tracking result of unknown carrier(seq-no=%s, carrier-code=%s, tracking-no=%s)\n", ts.SeqNo, ts.CarrierCode, ts.TrackingNo)
+			completionsUnknown.Inc()
+		}
+	}
+
+	return saved
+}
@@ -1,30 +1,90 @@
 package rpcclient
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_agent "com.cne/ai-tracking-search/agent"
 	_cache "com.cne/ai-tracking-search/cache"
+	_config "com.cne/ai-tracking-search/config"
+	_db "com.cne/ai-tracking-search/db"
+	_freshness "com.cne/ai-tracking-search/freshness"
+	_metrics "com.cne/ai-tracking-search/metrics"
 	_queue "com.cne/ai-tracking-search/queue"
 	_types "com.cne/ai-tracking-search/types"
 	_utils "com.cne/ai-tracking-search/utils"
-	"github.com/go-redis/redis/v8"
 )
 
 const (
 	trackingSearchKeyPrefix string = "TRACKING_SEARCH" // 缓存中的查询记录的Key的前缀。
 	trackingQueueKey        string = "TRACKING_QUEUE"  // 查询记录队列Key。
 
-	maxSearchQueueSize int64 = 10000 // 查询队列的最大长度。
-	maxPullCount       int   = 70    // 轮询缓存的最大次数。
+	trackingReplyKeyPrefix  string = "TRACKING_REPLY"  // 缓存中的完成通知列表的Key的前缀。
+	trackingFlightKeyPrefix string = "TRACKING_FLIGHT" // 缓存中的正在进行的查询的Key的前缀。
+
+	pullSlice      time.Duration = 1 * time.Second // 每次阻塞等待完成通知的最长时间。
+	pullMargin     time.Duration = 1 * time.Second // 在上下文的截止时间之前预留的时间，用于拉取尚未完成的查询对象并返回响应。
+	cleanupTimeout time.Duration = 2 * time.Second // 请求结束后清理缓存的超时时间。
+)
+
+// 表示可以在运行时修改的查询参数。
+// 参数创建之后不再修改，重新加载配置时整体替换。
+type searchSettings struct {
+	maxQueueLength int64         // 查询队列的最大长度。
+	pullTimeout    time.Duration // 等待查询代理返回结果的超时时间。
+	expiration     time.Duration // 查询对象在缓存中的过期时间，超过此时间尚未被查询代理执行则放弃。
+}
+
+var (
+	// 缓存中的查询对象的字段，读取时按照此顺序。
+	searchFields = []string{"status", "reqTime", "clientId", "carrierCode", "language", "trackingNo", "clientAddr", "agentSrc", "agentErr", "agentResult", "agentName", "agentStartTime", "agentEndTime"}
+
+	searchsAbandoned *_metrics.Counter // 因为请求被取消而放弃的查询对象数。
+	searchsCoalesced *_metrics.Counter // 合并到正在进行的查询的查询对象数。
+
+	settings atomic.Value // 当前生效的查询参数，类型是`*searchSettings`。
 )
 
+func init() {
+	ConfigureSearch(&_config.SearchConfiguration{
+		MaxQueueLength:   _config.DefaultSearchMaxQueueLength,
+		PullTimeout:      _config.DefaultSearchPullTimeout,
+		SearchExpiration: _config.DefaultSearchSearchExpiration,
+	})
+
+	searchsAbandoned = _metrics.NewCounter("tracking_search_abandoned_total", "Number of queued tracking searches abandoned because the request was cancelled.")
+	searchsCoalesced = _metrics.NewCounter("tracking_search_coalesced_total", "Number of tracking searches attached to an identical in-flight search.")
+}
+
+// 修改查询参数，可以在运行时调用，只影响之后推送的查询对象。
+// search 查询配置。
+func ConfigureSearch(search *_config.SearchConfiguration) {
+	settings.Store(&searchSettings{
+		maxQueueLength: int64(search.MaxQueueLength),
+		pullTimeout:    time.Duration(search.PullTimeout) * time.Second,
+		expiration:     time.Duration(search.SearchExpiration) * time.Second,
+	})
+}
+
+// 返回查询队列的最大长度。
+func MaxQueueLength() int64 {
+	return settings.Load().(*searchSettings).maxQueueLength
+}
+
+// 返回指定优先级的查询队列的长度。
+// ctx 上下文。
+// priority 优先级。
+func QueueLength(ctx context.Context, priority _types.Priority) (int64, error) {
+	return _queue.Length(ctx, trackingQueueKey+"$"+priority.String())
+}
+
 // 表示针对一个运单的查询，同时包含查询条件和查询结果。
 type TrackingSearch struct {
 	Src            _types.TrackingResultSrc // 来源。可以是 DB或者API或者CRAWLER
@@ -49,6 +109,8 @@ type TrackingSearch struct {
 	DoneTime       time.Time                // 妥投时间。
 	DonePlace      string                   // 妥投的地点。
 	Done           bool                     // 是否已经妥投。
+	Freshness      *_freshness.Decision     // 有效期策略对数据库中的跟踪记录的决定。
+	Stale          bool                     // 数据库中的跟踪记录是否已过期并且正在后台刷新。
 }
 
 // 表示跟踪结果的一个事件。
@@ -67,24 +129,55 @@ func (s TrackingEvents) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s TrackingEvents) Less(i, j int) bool { return s[i].Date.After(s[j].Date) } // 时间上越晚的事件越小。
 
 // 将查询对象推送到缓存和队列。
+// ctx 请求的上下文。
+// priority 优先级。
+// trackingSearchList 待推送到缓存和队列的查询对象。
+// 返回接收完成通知的列表的键，以及推送的查询对象的键集合。
+func PushTrackingSearchToQueue(ctx context.Context, priority _types.Priority, trackingSearchList []*TrackingSearch) (string, []string, error) {
+	// 查询代理工作进程完成查询对象后，将查询对象的键追加到此列表。
+	var replyKey string
+	if v, err := _utils.NewSeqNo(); err != nil {
+		return "", nil, err
+	} else {
+		replyKey = trackingReplyKeyPrefix + "$" + v
+	}
+
+	if keys, err := pushTrackingSearchs(ctx, priority, trackingSearchList, replyKey); err != nil {
+		return "", nil, err
+	} else {
+		return replyKey, keys, nil
+	}
+}
+
+// 将查询对象推送到缓存和队列，不等待结果。
+// 查询代理工作进程完成查询对象后不发出完成通知，结果只保存到数据库。
+// ctx 请求的上下文。
 // priority 优先级。
 // trackingSearchList 待推送到缓存和队列的查询对象。
-func PushTrackingSearchToQueue(priority _types.Priority, trackingSearchList []*TrackingSearch) ([]string, error) {
+// 返回推送的查询对象的键集合。
+func PushTrackingSearchToQueueWithoutReply(ctx context.Context, priority _types.Priority, trackingSearchList []*TrackingSearch) ([]string, error) {
+	return pushTrackingSearchs(ctx, priority, trackingSearchList, "")
+}
+
+// 将查询对象推送到缓存和队列。
+// replyKey 接收完成通知的列表的键，空字符串表示不需要完成通知。
+func pushTrackingSearchs(ctx context.Context, priority _types.Priority, trackingSearchList []*TrackingSearch, replyKey string) ([]string, error) {
 	keys := make([]string, 0)
+	searchExpiration := settings.Load().(*searchSettings).expiration
 
 	queueTopic := trackingQueueKey + "$" + priority.String()
 
 	// 检查查询队列是否已经超长。
-	if cl, err := _queue.Length(queueTopic); err != nil {
+	if cl, err := _queue.Length(ctx, queueTopic); err != nil {
 		return nil, err
 	} else {
-		if cl+int64(len(trackingSearchList)) > maxSearchQueueSize {
+		if cl+int64(len(trackingSearchList)) > settings.Load().(*searchSettings).maxQueueLength {
 			return nil, fmt.Errorf("too many searchs")
 		}
 	}
 
-	avaiableUpdateTime := time.Now().Add(time.Hour * -2)        // 有效更新时间。
-	avaiableUpdateTimeOfEmpty := time.Now().Add(time.Hour * -8) // 空单号有效更新时间。
+	now := time.Now()
+	carriers := _db.QueryCarriersByCodes(ctx, CarrierCodes(trackingSearchList))
 
 	for _, ts := range trackingSearchList {
 		// 跳过空单号，这种查询请求是不合法的。
@@ -92,182 +185,307 @@ func PushTrackingSearchToQueue(priority _types.Priority, trackingSearchList []*T
 			continue
 		}
 
-		// 数据库中存在跟踪记录，那么检查其它条件，判断是否可以直接使用数据库记录，而不再调用查询代理查询。
-		if len(ts.Events) != 0 {
-			// 优先级最高的情况下，必须调用查询代理。
-			if priority != _types.PriorityHighest {
-				if ts.Done {
-					// 已完成，这种查询对象不再需要执行。
-					continue
-				} else if ts.UpdateTime.After(avaiableUpdateTime) || (len(ts.Events) == 0 && ts.UpdateTime.After(avaiableUpdateTimeOfEmpty)) {
-					// 未完成，并且满足以下两个条件之一：
-					// 1. 更新时间晚于有效更新时间（即数据比较新）;
-					// 2. 更新时间晚于有效更新时间2，并且之前查询结果是空单号;
-					// 这种查询对象也不再需要执行。
-					continue
-				}
-			}
+		// 根据有效期策略判断是否可以直接使用数据库记录，而不再调用查询代理查询。
+		var carrierType _types.CarrierType
+		if carrierPo := carriers[ts.CarrierCode]; carrierPo != nil {
+			carrierType = carrierPo.CarrierType
+		}
+
+		ts.Freshness = _freshness.Decide(ts.CarrierCode, carrierType, priority, shipmentState(ts), ts.UpdateTime, now)
+		if ts.Freshness.Fresh {
+			continue
 		}
 
 		// 查询对象保存到缓存。
 		key := trackingSearchKeyPrefix + "$" + ts.SeqNo
 
-		// 如果120秒内该查询对象尚未被查询代理执行则放弃。
-		if err := _cache.SetAndExpire(key, map[string]interface{}{"reqTime": _utils.AsString(ts.ReqTime), "clientId": ts.ClientId, "carrierCode": ts.CarrierCode, "language": ts.Language.String(), "trackingNo": ts.TrackingNo, "postcode": ts.Postcode, "dest": ts.Dest, "date": ts.Date, "clientAddr": ts.ClientAddr, "status": -1}, 120*time.Second); err != nil {
+		// 相同运输商、语言和运单号的查询对象合并为一次查询，和优先级无关，查询结果对所有优先级都是一样的。
+		flightKey := trackingFlightKeyPrefix + "$" + ts.CarrierCode + "$" + ts.Language.String() + "$" + strings.ToUpper(ts.TrackingNo)
+
+		// 如果在过期时间内该查询对象尚未被查询代理执行则放弃。
+		if err := _cache.SetAndExpire(ctx, key, map[string]interface{}{"reqTime": _utils.AsString(ts.ReqTime), "clientId": ts.ClientId, "carrierCode": ts.CarrierCode, "language": ts.Language.String(), "trackingNo": ts.TrackingNo, "postcode": ts.Postcode, "dest": ts.Dest, "date": ts.Date, "clientAddr": ts.ClientAddr, "replyTo": replyKey, "flight": flightKey, "status": -1}, searchExpiration); err != nil {
 			panic(err)
 		}
+		keys = append(keys, key)
+
+		// 附带了邮编、目的地或者发件日期的查询对象只能单独查询。
+		if ts.Postcode == "" && ts.Dest == "" && ts.Date == "" {
+			if leader, err := _cache.JoinFlight(ctx, flightKey, key, searchExpiration); err != nil {
+				// 无法合并时单独查询。
+				log.Printf("[WARN] Cannot join in-flight search(flight-key=%s). cause=%s\n", flightKey, err)
+			} else if leader != "" {
+				// 已经存在正在进行的查询，等待其结果，不再推送到队列。
+				searchsCoalesced.Inc()
+				continue
+			}
+		}
 
 		// 推送到队列。
-		_queue.Push(queueTopic, key)
-		keys = append(keys, key)
+		if _, err := _queue.Push(ctx, queueTopic, key); err != nil {
+			// 此查询对象不会被执行，结束它领导的查询，避免之后相同的查询一直等待它。
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+			defer cancel()
+
+			if _, err := _cache.LandFlight(cleanupCtx, flightKey, key); err != nil {
+				log.Printf("[WARN] Cannot land in-flight search(flight-key=%s). cause=%s\n", flightKey, err)
+			}
+			_cache.Del(cleanupCtx, key)
+
+			// 请求失败，之前已经推送或者已经跟随其它查询的查询对象不会再被拉取，标记为已放弃。
+			// 查询代理工作进程跳过这些查询对象并结束它们领导的查询；如果有其它请求的查询对象在跟随，那么仍然执行。
+			created := make(map[string]bool, len(keys)-1)
+			for _, k := range keys[:len(keys)-1] {
+				created[k] = true
+			}
+			abandonTrackingSearchs(created)
+
+			return nil, fmt.Errorf("cannot push tracking-search(key=%s) to queue. cause=%w", key, err)
+		}
 	}
 
 	return keys, nil
 }
 
+// 根据数据库中的跟踪记录判断运单状态。
+// ts 已从数据库中读取跟踪记录的查询对象。
+func shipmentState(ts *TrackingSearch) _freshness.State {
+	if ts.Src != _types.SrcDB {
+		return _freshness.StateNone
+	} else if ts.Done {
+		return _freshness.StateDelivered
+	} else if len(ts.Events) == 0 {
+		return _freshness.StatePreTransit
+	}
+
+	// 最新的事件是投递失败，说明投递异常。
+	latest := ts.Events[0]
+	for _, evt := range ts.Events[1:] {
+		if evt.Date.After(latest.Date) {
+			latest = evt
+		}
+	}
+	if latest.State == 8 {
+		return _freshness.StateException
+	}
+
+	return _freshness.StateInTransit
+}
+
 // 从缓存中拉取已完成的查询对象。
-// 此方法会阻塞，并不断轮询查询对象。直到所有的查询对象状态都变为已有结果或者超时。
+// 此方法会阻塞，等待查询代理工作进程发出的完成通知，直到所有的查询对象都已有结果或者超时。
+// 上下文的截止时间早于默认的超时时间时，提前结束等待，保证在截止时间之前返回已有的结果。
+// 如果上下文被取消（比如客户端断开连接），那么停止等待，并将尚未完成的查询对象标记为已放弃。
+// ctx 请求的上下文。
 // priority 查询对象的优先级。
+// replyKey 接收完成通知的列表的键。
 // keys 查询对象的键集合。
 // 返回缓存中的查询对象。
-func PullTrackingSearchFromCache(priority _types.Priority, keys []string) ([]*TrackingSearch, error) {
+func PullTrackingSearchFromCache(ctx context.Context, priority _types.Priority, replyKey string, keys []string) ([]*TrackingSearch, error) {
 	result := make([]*TrackingSearch, 0, len(keys))
 
-	// 全部查询成功或者重试次数太多则停止重试。
-	c := 0
-	for {
-		// 收集已完成的响应。
-		pc := 0
-		for _, key := range keys {
-			if os, err := _cache.Get(key, "status", "reqTime", "clientId", "carrierCode", "language", "trackingNo", "clientAddr", "agentSrc", "agentErr", "agentResult", "agentName", "agentStartTime", "agentEndTime"); err != nil {
-				if errors.Is(err, redis.Nil) {
-					// 缓存已消失，说明查询超时。
-					continue
-				} else {
-					return nil, fmt.Errorf("cannot get tracking-search(key=%s) from cache. cause=%w", key, err)
-				}
-			} else {
-				// 查询代理执行状态，该值由查询代理调度程序写入，和数据库中的`status`字段无关。
-				status := _utils.AsInt(os[0], -1)
-				if status < 1 && c < maxPullCount {
-					// 如果返回码是-1或者0，说明查询代理尚未返回结果。
-					keys[pc] = key
-					pc++
-					continue
-				}
+	// 上下文被取消后仍然需要清理，所以使用独立的上下文。
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
 
-				_cache.Del(key)
-
-				reqTime := _utils.AsTime(os[1])
-				clientId := _utils.AsString(os[2])
-				carrierCode := _utils.AsString(os[3])
-				language, _ := _types.ParseLangId(_utils.AsString(os[4]))
-				trackingNo := _utils.AsString(os[5])
-				clientAddr := _utils.AsString(os[6])
-				agentSrc := _types.TrackingResultSrc(_utils.AsInt(os[7], int(_types.SrcUnknown)))
-				agentErr := _utils.AsString(os[8])
-				agentRspJson := strings.TrimSpace(_utils.AsString(os[9]))
-				agentName := _utils.AsString(os[10])
-				agentStartTime := _utils.AsTime(os[11])
-				agentEndTime := _utils.AsTime(os[12])
-
-				trackingResult := _agent.TrackingResult{Code: _agent.AcTimeout}
-				agentCode := _agent.AcTimeout
-				message := ""
-				events := make([]*TrackingEvent, 0)
-				if agentRspJson == "" {
-					log.Printf("[WARN] Cannot parse empty crawler result json\n")
-				} else {
-					crawlerRspJsonBytes := []byte(agentRspJson)
-					if err := json.Unmarshal(crawlerRspJsonBytes, &trackingResult); err != nil {
-						// 首先尝试将查询代理返回的json反序列化为跟踪结果对象。
-						// 如果失败，那么尝试反序列化为批量跟踪结果对象。
-						// 如果仍然失败则报错。
-						// 如果反序列化的批量跟踪结果对象包含的运单记录超过1个，也报错。
-						crawlerRsp := _agent.ResponseWrapper{}
-						if err := json.Unmarshal(crawlerRspJsonBytes, &crawlerRsp); err != nil {
-							agentCode = _agent.AcParseFailed
-							log.Printf("[WARN] Cannot parse crawler result json: %v. cause=%s\n", _utils.AbbrText(agentRspJson, 255), err)
-						} else if len(crawlerRsp.Items) != 1 {
-							agentCode = _agent.AcOther
-							log.Printf("[WARN] Length of crawler result should be just 1, but %#v\n", crawlerRsp)
-						} else {
-							trackingResult = crawlerRsp.Items[0]
-							if v, err := strconv.Atoi(crawlerRsp.Code); err != nil {
-								agentCode = _agent.AcParseFailed
-							} else {
-								agentCode = _agent.AgCode(v)
-							}
-							message = crawlerRsp.Message
-						}
-					} else {
-						agentCode = trackingResult.Code
-						message = trackingResult.CMess
-					}
-
-					// 将查询代理的事件列表映射为待匹配的事件。
-					for _, te := range trackingResult.TrackingEventList {
-						events = append(events, &TrackingEvent{
-							Date:    _utils.ParseTime(te.Date), // TODO: 此处是否应当使用ParseUTCTime。
-							Details: te.Details,
-							Place:   te.Place,
-							State:   0,
-						})
-					}
-				}
+		_cache.Del(cleanupCtx, replyKey)
+	}()
 
-				// 此处忽略trackingResult.CodeMg，该字段似乎已经弃用。
+	waiting := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		waiting[key] = true
+	}
 
-				if agentErr == "" {
-					// 如果调用代理时没有出现错误，那么从代理的响应结果中获取错误信息。
-					agentErr = message
-				}
+	// 等待完成通知，每个通知只需要读取一次缓存。
+	deadline := time.Now().Add(settings.Load().(*searchSettings).pullTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Add(-pullMargin).Before(deadline) {
+		deadline = d.Add(-pullMargin)
+	}
+	for len(waiting) != 0 {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			break
+		}
+		if wait > pullSlice {
+			// 分段等待，及时发现上下文被取消。
+			wait = pullSlice
+		}
 
-				trackingSearch := TrackingSearch{
-					SeqNo:          key[len(trackingSearchKeyPrefix)+1:],
-					ReqTime:        reqTime,
-					ClientId:       clientId,
-					Src:            agentSrc,
-					CarrierCode:    carrierCode,
-					Language:       language,
-					TrackingNo:     trackingNo,
-					ClientAddr:     clientAddr,
-					AgentName:      agentName,
-					AgentStartTime: agentStartTime,
-					AgentEndTime:   agentEndTime,
-					Events:         events,
-					AgentCode:      agentCode,
-					Err:            agentErr,
-					AgentRawText:   agentRspJson,
-				}
+		key, err := _cache.BPop(ctx, replyKey, wait)
+		if err != nil {
+			if ctx.Err() != nil {
+				abandonTrackingSearchs(waiting)
+				return nil, ctx.Err()
+			} else if errors.Is(err, _cache.Nil) {
+				continue
+			}
+			return nil, fmt.Errorf("cannot wait for tracking-searchs(reply-key=%s). cause=%w", replyKey, err)
+		}
 
-				result = append(result, &trackingSearch)
+		if !waiting[key] {
+			// 重复的通知。
+			continue
+		}
+
+		if ts, done, err := pullTrackingSearch(ctx, key, false); err != nil {
+			return nil, err
+		} else if done {
+			delete(waiting, key)
+			if ts != nil {
+				result = append(result, ts)
 			}
-		} // end of for-key
+		}
+	}
 
-		// 删除这些已完成的响应。
-		keys = keys[:pc]
+	// 超时仍未完成的查询对象，按照查询代理超时处理。
+	for _, key := range keys {
+		if !waiting[key] {
+			continue
+		}
 
-		if len(keys) == 0 || c >= maxPullCount {
-			break
+		if ts, _, err := pullTrackingSearch(ctx, key, true); err != nil {
+			return nil, err
+		} else if ts != nil {
+			result = append(result, ts)
 		}
+	}
 
-		c++
-		// if c <= 1 {
-		// 	time.Sleep(time.Second * 4)
-		// } else if c <= 3 {
-		// 	time.Sleep(time.Second * 3)
-		// } else if c <= 5 {
-		// 	time.Sleep(time.Second * 2)
-		// } else if c <= 7 {
-		if c == 1 {
-			time.Sleep(time.Millisecond * 500)
-		} else if c <= 10 {
-			time.Sleep(time.Millisecond * 300)
+	return result, nil
+}
+
+// 将尚未完成的查询对象标记为已放弃，查询代理工作进程会跳过已放弃的查询对象。
+// waiting 尚未完成的查询对象的键。
+func abandonTrackingSearchs(waiting map[string]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	for key := range waiting {
+		if err := _cache.Update(ctx, key, map[string]interface{}{"abandoned": 1}); err != nil {
+			log.Printf("[WARN] Cannot abandon tracking-search(key=%s). cause=%s\n", key, err)
+		}
+	}
+
+	searchsAbandoned.Add(float64(len(waiting)))
+}
+
+// 从缓存中拉取一个查询对象。
+// ctx 请求的上下文。
+// key 查询对象的键。
+// force 查询代理尚未返回结果时是否仍然拉取。
+// 返回拉取的查询对象，以及查询对象是否已经结束。如果缓存已消失，那么查询对象已经结束，但是返回的查询对象是nil。
+// 查询代理已经返回结果的查询对象被拉取后从缓存中删除。
+func pullTrackingSearch(ctx context.Context, key string, force bool) (*TrackingSearch, bool, error) {
+	os, err := _cache.Get(ctx, key, searchFields...)
+	if err != nil {
+		if errors.Is(err, _cache.Nil) {
+			// 缓存已消失，说明查询超时。
+			return nil, true, nil
 		} else {
-			time.Sleep(time.Millisecond * 200)
+			return nil, false, fmt.Errorf("cannot get tracking-search(key=%s) from cache. cause=%w", key, err)
 		}
 	}
 
-	return result, nil
+	// 查询代理执行状态，该值由查询代理调度程序写入，和数据库中的`status`字段无关。
+	status := _utils.AsInt(os[0], -1)
+	if status < 1 && !force {
+		// 如果返回码是-1或者0，说明查询代理尚未返回结果。
+		return nil, false, nil
+	}
+
+	// 查询代理尚未返回结果时保留查询对象，查询代理返回时需要其中的请求参数保存结果，之后查询对象自动过期。
+	if status >= 1 {
+		_cache.Del(ctx, key)
+	}
+
+	return buildTrackingSearch(key, os), true, nil
+}
+
+// 根据缓存中的查询对象构造查询对象。
+// key 查询对象的键。
+// os 查询对象的字段，顺序和`searchFields`一致。
+func buildTrackingSearch(key string, os []interface{}) *TrackingSearch {
+	reqTime := _utils.AsTime(os[1])
+	clientId := _utils.AsString(os[2])
+	carrierCode := _utils.AsString(os[3])
+	language, _ := _types.ParseLangId(_utils.AsString(os[4]))
+	trackingNo := _utils.AsString(os[5])
+	clientAddr := _utils.AsString(os[6])
+	agentSrc := _types.TrackingResultSrc(_utils.AsInt(os[7], int(_types.SrcUnknown)))
+	agentErr := _utils.AsString(os[8])
+	agentRspJson := strings.TrimSpace(_utils.AsString(os[9]))
+	agentName := _utils.AsString(os[10])
+	agentStartTime := _utils.AsTime(os[11])
+	agentEndTime := _utils.AsTime(os[12])
+
+	trackingResult := _agent.TrackingResult{Code: _agent.AcTimeout}
+	agentCode := _agent.AcTimeout
+	message := ""
+	events := make([]*TrackingEvent, 0)
+	if agentRspJson == "" {
+		log.Printf("[WARN] Cannot parse empty crawler result json\n")
+	} else {
+		crawlerRspJsonBytes := []byte(agentRspJson)
+		if err := json.Unmarshal(crawlerRspJsonBytes, &trackingResult); err != nil {
+			// 首先尝试将查询代理返回的json反序列化为跟踪结果对象。
+			// 如果失败，那么尝试反序列化为批量跟踪结果对象。
+			// 如果仍然失败则报错。
+			// 如果反序列化的批量跟踪结果对象包含的运单记录超过1个，也报错。
+			crawlerRsp := _agent.ResponseWrapper{}
+			if err := json.Unmarshal(crawlerRspJsonBytes, &crawlerRsp); err != nil {
+				agentCode = _agent.AcParseFailed
+				log.Printf("[WARN] Cannot parse crawler result json: %v. cause=%s\n", _utils.AbbrText(agentRspJson, 255), err)
+			} else if len(crawlerRsp.Items) != 1 {
+				agentCode = _agent.AcOther
+				log.Printf("[WARN] Length of crawler result should be just 1, but %#v\n", crawlerRsp)
+			} else {
+				trackingResult = crawlerRsp.Items[0]
+				if v, err := strconv.Atoi(crawlerRsp.Code); err != nil {
+					agentCode = _agent.AcParseFailed
+				} else {
+					agentCode = _agent.AgCode(v)
+				}
+				message = crawlerRsp.Message
+			}
+		} else {
+			agentCode = trackingResult.Code
+			message = trackingResult.CMess
+		}
+
+		// 将查询代理的事件列表映射为待匹配的事件。
+		for _, te := range trackingResult.TrackingEventList {
+			events = append(events, &TrackingEvent{
+				Date:    _utils.ParseTime(te.Date), // TODO: 此处是否应当使用ParseUTCTime。
+				Details: te.Details,
+				Place:   te.Place,
+				State:   0,
+			})
+		}
+	}
+
+	// 此处忽略trackingResult.CodeMg，该字段似乎已经弃用。
+
+	if agentErr == "" {
+		// 如果调用代理时没有出现错误，那么从代理的响应结果中获取错误信息。
+		agentErr = message
+	}
+
+	trackingSearch := TrackingSearch{
+		SeqNo:          key[len(trackingSearchKeyPrefix)+1:],
+		ReqTime:        reqTime,
+		ClientId:       clientId,
+		Src:            agentSrc,
+		CarrierCode:    carrierCode,
+		Language:       language,
+		TrackingNo:     trackingNo,
+		ClientAddr:     clientAddr,
+		AgentName:      agentName,
+		AgentStartTime: agentStartTime,
+		AgentEndTime:   agentEndTime,
+		Events:         events,
+		AgentCode:      agentCode,
+		Err:            agentErr,
+		AgentRawText:   agentRspJson,
+	}
+
+	return &trackingSearch
 }
@@ -0,0 +1,52 @@
+// @Author: agent
+// @Created: 2026-10-18
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	_cache "com.cne/ai-tracking-search/cache"
+)
+
+func TestPullTrackingSearch(t *testing.T) {
+	if err := _cache.InitMemoryCache(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	key := trackingSearchKeyPrefix + "$1"
+	if err := _cache.SetAndExpire(ctx, key, map[string]interface{}{"carrierCode": "C1", "language": "en", "trackingNo": "T1", "status": -1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// 查询代理尚未返回结果时不拉取。
+	if ts, done, err := pullTrackingSearch(ctx, key, false); err != nil || done || ts != nil {
+		t.Errorf("pull of pending search = %v, %v, %v, want not done", ts, done, err)
+	}
+
+	// 强制拉取时按照查询代理超时处理，但是保留查询对象，查询代理稍后返回的结果仍然可以保存。
+	ts, done, err := pullTrackingSearch(ctx, key, true)
+	if err != nil || !done || ts == nil || ts.CarrierCode != "C1" || ts.TrackingNo != "T1" {
+		t.Fatalf("forced pull = %+v, %v, %v", ts, done, err)
+	}
+	if os, err := _cache.Get(ctx, key, "carrierCode", "trackingNo"); err != nil || os[0] != "C1" || os[1] != "T1" {
+		t.Errorf("search after forced pull = %v, %v, want kept", os, err)
+	}
+
+	// 查询代理返回结果之后拉取并删除。
+	_cache.Update(ctx, key, map[string]interface{}{"status": 1, "agentResult": `{"code":200}`})
+	if ts, done, err := pullTrackingSearch(ctx, key, false); err != nil || !done || ts == nil {
+		t.Errorf("pull of completed search = %v, %v, %v", ts, done, err)
+	}
+	if _, err := _cache.Get(ctx, key, "carrierCode"); !errors.Is(err, _cache.Nil) {
+		t.Errorf("Get after pull error = %v, want Nil", err)
+	}
+
+	// 缓存已消失时查询对象已经结束。
+	if ts, done, err := pullTrackingSearch(ctx, key, true); err != nil || !done || ts != nil {
+		t.Errorf("pull of missing search = %v, %v, %v", ts, done, err)
+	}
+}
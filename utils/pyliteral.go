@@ -0,0 +1,461 @@
+// 该模块定义了解析Python字面量的方法。
+// Python查询代理返回的内容往往是`str(dict)`的结果而不是合规的JSON，比如使用单引号、`None`、`True`和`False`。
+// @Author: agent
+// @Created: 2026-10-18
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 将Python字面量或者JSON转换为合规的JSON。
+// s 待转换的文本。如果已经是合规的JSON，那么原样返回。
+// 返回转换后的JSON。
+func PyLiteralToJson(s string) (string, error) {
+	if json.Valid([]byte(s)) {
+		return s, nil
+	}
+
+	if v, err := ParsePyLiteral(s); err != nil {
+		return "", err
+	} else if b, err := json.Marshal(v); err != nil {
+		return "", err
+	} else {
+		return string(b), nil
+	}
+}
+
+// 解析Python的字典、列表、元组、字符串、数字以及`None`、`True`和`False`字面量。
+// 同时兼容JSON的`null`、`true`和`false`。
+// s 待解析的文本。
+// 返回解析结果，字典被解析为`map[string]interface{}`，列表和元组被解析为`[]interface{}`，数字被解析为`json.Number`，`None`被解析为nil。
+func ParsePyLiteral(s string) (interface{}, error) {
+	p := pyParser{s: s}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected trailing text")
+	}
+
+	return v, nil
+}
+
+type pyParser struct {
+	s   string // 待解析的文本。
+	pos int    // 当前位置。
+}
+
+func (p *pyParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cannot parse python literal at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pyParser) skipSpaces() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *pyParser) parseValue() (interface{}, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of text")
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case c == '{':
+		return p.parseDict()
+	case c == '[':
+		return p.parseList('[', ']')
+	case c == '(':
+		return p.parseList('(', ')')
+	case c == '\'' || c == '"':
+		return p.parseStrings()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	default:
+		return p.parseName()
+	}
+}
+
+func (p *pyParser) parseDict() (interface{}, error) {
+	p.pos++ // 跳过`{`。
+
+	result := make(map[string]interface{})
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("unclosed dict")
+		}
+		if p.s[p.pos] == '}' {
+			p.pos++
+			return result, nil
+		}
+
+		k, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != ':' {
+			return nil, p.errorf("expected colon after dict key")
+		}
+		p.pos++
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result[pyDictKey(k)] = v
+
+		p.skipSpaces()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.s) && p.s[p.pos] != '}' {
+			return nil, p.errorf("expected comma or closing brace in dict")
+		}
+	}
+}
+
+// 将字典的键转换为字符串。
+func pyDictKey(k interface{}) string {
+	switch v := k.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "True"
+		} else {
+			return "False"
+		}
+	case nil:
+		return "None"
+	default:
+		if b, err := json.Marshal(v); err != nil {
+			return fmt.Sprintf("%v", v)
+		} else {
+			return string(b)
+		}
+	}
+}
+
+func (p *pyParser) parseList(open, close byte) (interface{}, error) {
+	p.pos++ // 跳过开始的括号。
+
+	result := make([]interface{}, 0)
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("unclosed %c", open)
+		}
+		if p.s[p.pos] == close {
+			p.pos++
+			return result, nil
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+
+		p.skipSpaces()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.s) && p.s[p.pos] != close {
+			return nil, p.errorf("expected comma or %c", close)
+		}
+	}
+}
+
+// 解析一个或者多个相邻的字符串字面量，相邻的字符串会被拼接。
+func (p *pyParser) parseStrings() (interface{}, error) {
+	buf := strings.Builder{}
+	for {
+		if s, err := p.parseString(false); err != nil {
+			return nil, err
+		} else {
+			buf.WriteString(s)
+		}
+
+		// 检查是否还有相邻的字符串。
+		save := p.pos
+		p.skipSpaces()
+		if p.pos < len(p.s) && (p.s[p.pos] == '\'' || p.s[p.pos] == '"') {
+			continue
+		} else if raw, n := p.stringPrefix(); n > 0 {
+			p.pos += n
+			if s, err := p.parseString(raw); err != nil {
+				return nil, err
+			} else {
+				buf.WriteString(s)
+			}
+			continue
+		}
+		p.pos = save
+		return buf.String(), nil
+	}
+}
+
+// 检查当前位置是否是带前缀（u、b、r及其组合）的字符串。
+// 返回是否是原始字符串，以及前缀的长度。如果不是带前缀的字符串则前缀长度是0。
+func (p *pyParser) stringPrefix() (bool, int) {
+	raw := false
+	for n := 0; n < 2 && p.pos+n < len(p.s); n++ {
+		switch p.s[p.pos+n] {
+		case 'u', 'U', 'b', 'B':
+		case 'r', 'R':
+			raw = true
+		case '\'', '"':
+			if n > 0 {
+				return raw, n
+			}
+			return false, 0
+		default:
+			return false, 0
+		}
+	}
+	if p.pos+2 < len(p.s) && (p.s[p.pos+2] == '\'' || p.s[p.pos+2] == '"') {
+		return raw, 2
+	}
+
+	return false, 0
+}
+
+func (p *pyParser) parseString(raw bool) (string, error) {
+	quote := p.s[p.pos]
+	triple := strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3))
+	if triple {
+		p.pos += 3
+	} else {
+		p.pos++
+	}
+
+	buf := strings.Builder{}
+	for {
+		if p.pos >= len(p.s) {
+			return "", p.errorf("unclosed string")
+		}
+
+		c := p.s[p.pos]
+		if c == quote {
+			if !triple {
+				p.pos++
+				return buf.String(), nil
+			} else if strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3)) {
+				p.pos += 3
+				return buf.String(), nil
+			}
+		} else if (c == '\n' || c == '\r') && !triple {
+			return "", p.errorf("unexpected line break in string")
+		} else if c == '\\' {
+			if raw {
+				// 原始字符串中反斜杠保持原样，但是仍然不能结束字符串。
+				buf.WriteByte(c)
+				if p.pos+1 < len(p.s) {
+					buf.WriteByte(p.s[p.pos+1])
+				}
+				p.pos += 2
+				continue
+			}
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		buf.WriteByte(c)
+		p.pos++
+	}
+}
+
+// 解析转义字符，当前位置是反斜杠。
+func (p *pyParser) parseEscape(buf *strings.Builder) error {
+	p.pos++
+	if p.pos >= len(p.s) {
+		return p.errorf("unexpected end of text in escape")
+	}
+
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case '\n':
+		// 续行。
+	case '\\', '\'', '"':
+		buf.WriteByte(c)
+	case 'n':
+		buf.WriteByte('\n')
+	case 't':
+		buf.WriteByte('\t')
+	case 'r':
+		buf.WriteByte('\r')
+	case 'a':
+		buf.WriteByte('\a')
+	case 'b':
+		buf.WriteByte('\b')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'v':
+		buf.WriteByte('\v')
+	case '/':
+		buf.WriteByte('/')
+	case 'x', 'u', 'U':
+		n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+		if p.pos+n > len(p.s) {
+			return p.errorf("truncated \\%c escape", c)
+		}
+		v, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil {
+			return p.errorf("illegal \\%c escape %q", c, p.s[p.pos:p.pos+n])
+		}
+		p.pos += n
+		r := rune(v)
+		// JSON风格的代理对。
+		if c == 'u' && r >= 0xD800 && r < 0xDC00 && strings.HasPrefix(p.s[p.pos:], "\\u") && p.pos+6 <= len(p.s) {
+			if lo, err := strconv.ParseUint(p.s[p.pos+2:p.pos+6], 16, 32); err == nil && lo >= 0xDC00 && lo < 0xE000 {
+				r = (r-0xD800)<<10 + (rune(lo) - 0xDC00) + 0x10000
+				p.pos += 6
+			}
+		}
+		if !utf8.ValidRune(r) {
+			r = utf8.RuneError
+		}
+		buf.WriteRune(r)
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		end := p.pos - 1
+		for end < len(p.s) && end < p.pos+2 && p.s[end] >= '0' && p.s[end] <= '7' {
+			end++
+		}
+		v, _ := strconv.ParseUint(p.s[p.pos-1:end], 8, 32)
+		p.pos = end
+		buf.WriteRune(rune(v))
+	default:
+		// 未知的转义字符保持原样。
+		buf.WriteByte('\\')
+		buf.WriteByte(c)
+	}
+
+	return nil
+}
+
+func (p *pyParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '_' || c == 'x' || c == 'X' ||
+			(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') ||
+			((c == '-' || c == '+') && (p.pos == start || p.s[p.pos-1] == 'e' || p.s[p.pos-1] == 'E')) {
+			p.pos++
+		} else {
+			break
+		}
+	}
+
+	text := strings.ReplaceAll(p.s[start:p.pos], "_", "")
+	if strings.HasPrefix(strings.TrimLeft(text, "+-"), "0x") || strings.HasPrefix(strings.TrimLeft(text, "+-"), "0X") {
+		if v, err := strconv.ParseInt(text, 0, 64); err != nil {
+			return nil, p.errorf("illegal number %q", text)
+		} else {
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		}
+	}
+
+	text = strings.TrimPrefix(text, "+")
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return nil, p.errorf("illegal number %q", text)
+	}
+	if strings.HasPrefix(text, ".") {
+		text = "0" + text
+	} else if strings.HasPrefix(text, "-.") {
+		text = "-0" + text[1:]
+	}
+	if strings.HasSuffix(text, ".") {
+		text = text + "0"
+	}
+
+	return json.Number(text), nil
+}
+
+// 解析名字，可以是`None`、`True`、`False`以及JSON的`null`、`true`、`false`。也可能是带前缀的字符串。
+func (p *pyParser) parseName() (interface{}, error) {
+	if raw, n := p.stringPrefix(); n > 0 {
+		p.pos += n
+		if s, err := p.parseString(raw); err != nil {
+			return nil, err
+		} else {
+			buf := strings.Builder{}
+			buf.WriteString(s)
+			if rest, err := p.parseAdjacentStrings(); err != nil {
+				return nil, err
+			} else {
+				buf.WriteString(rest)
+			}
+			return buf.String(), nil
+		}
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' {
+			p.pos++
+		} else {
+			break
+		}
+	}
+
+	switch name := p.s[start:p.pos]; name {
+	case "None", "null":
+		return nil, nil
+	case "True", "true":
+		return true, nil
+	case "False", "false":
+		return false, nil
+	case "":
+		return nil, p.errorf("unexpected character %q", p.s[start])
+	default:
+		p.pos = start
+		return nil, p.errorf("unknown name %q", name)
+	}
+}
+
+// 解析带前缀的字符串之后相邻的字符串。
+func (p *pyParser) parseAdjacentStrings() (string, error) {
+	save := p.pos
+	p.skipSpaces()
+	if p.pos < len(p.s) && (p.s[p.pos] == '\'' || p.s[p.pos] == '"') {
+		if v, err := p.parseStrings(); err != nil {
+			return "", err
+		} else {
+			return v.(string), nil
+		}
+	} else if raw, n := p.stringPrefix(); n > 0 {
+		p.pos += n
+		s, err := p.parseString(raw)
+		if err != nil {
+			return "", err
+		}
+		rest, err := p.parseAdjacentStrings()
+		return s + rest, err
+	}
+
+	p.pos = save
+	return "", nil
+}
@@ -0,0 +1,124 @@
+// @Author: agent
+// @Created: 2026-10-18
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 解析testdata/pyliteral中的每个样本，和期望的JSON比较。
+func TestPyLiteralSamples(t *testing.T) {
+	samples, err := filepath.Glob(filepath.Join("testdata", "pyliteral", "*.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no samples found in testdata/pyliteral")
+	}
+
+	for _, sample := range samples {
+		name := strings.TrimSuffix(filepath.Base(sample), ".py")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := os.ReadFile(strings.TrimSuffix(sample, ".py") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := PyLiteralToJson(strings.TrimSpace(string(src)))
+			if err != nil {
+				t.Fatalf("PyLiteralToJson failed: %s", err)
+			}
+			assertJsonEqual(t, got, string(expected))
+		})
+	}
+}
+
+func TestPyLiteralToJson(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"json unchanged", `{"a": [1, 2.5, null, true]}`, `{"a": [1, 2.5, null, true]}`},
+		{"python constants", `{'a': None, 'b': True, 'c': False}`, `{"a": null, "b": true, "c": false}`},
+		{"trailing comma in list", `[1, 2,]`, `[1, 2]`},
+		{"trailing comma in dict", `{'a': 1, }`, `{"a": 1}`},
+		{"one-element tuple", `(1,)`, `[1]`},
+		{"empty tuple", `()`, `[]`},
+		{"nested tuples", `((1, 2), (3,), ((),))`, `[[1, 2], [3], [[]]]`},
+		{"tuple in dict", `{'p': ('a', ('b', 'c'))}`, `{"p": ["a", ["b", "c"]]}`},
+		{"adjacent strings", `'abc' "def"`, `"abcdef"`},
+		{"prefixed strings", `[u'x', b'y', r'\d']`, `["x", "y", "\\d"]`},
+		{"escapes", `'\t\n\\\'\x41é\101'`, `"\t\n\\'AéA"`},
+		{"non-string keys", `{1: 'a', None: 'b'}`, `{"1": "a", "None": "b"}`},
+		{"numbers", `[-1, +2, .5, 1e3, 1_000]`, `[-1, 2, 0.5, 1e3, 1000]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := PyLiteralToJson(c.src)
+			if err != nil {
+				t.Fatalf("PyLiteralToJson(%s) failed: %s", c.src, err)
+			}
+			assertJsonEqual(t, got, c.want)
+		})
+	}
+}
+
+func TestPyLiteralErrors(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"empty", ``, "unexpected end of text"},
+		{"unterminated string", `{'a': 'abc}`, "unclosed string"},
+		{"unterminated string at end", `'abc`, "unclosed string"},
+		{"truncated hex escape", `'\x4'`, "escape"},
+		{"illegal hex escape", `'\xZZ'`, "illegal \\x escape"},
+		{"illegal unicode escape", `'\u12G4'`, "illegal \\u escape"},
+		{"escape at end", `'\`, "escape"},
+		{"double comma in list", `[1,, 2]`, "offset 3: unexpected character ','"},
+		{"leading comma in tuple", `(, 1)`, "offset 1: unexpected character ','"},
+		{"comma only dict", `{,}`, "offset 1: unexpected character ','"},
+		{"missing colon", `{'a' 1}`, "expected colon"},
+		{"missing comma", `[1 2]`, "expected comma"},
+		{"unclosed list", `[1, 2`, "unclosed"},
+		{"unclosed nested tuple", `((1, 2), (3,)`, "unclosed"},
+		{"mismatched brackets", `(1, 2]`, "expected comma"},
+		{"unknown name", `{'a': undefined}`, "unknown name \"undefined\""},
+		{"trailing text", `{'a': 1} x`, "unexpected trailing text"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := PyLiteralToJson(c.src)
+			if err == nil {
+				t.Fatalf("PyLiteralToJson(%s) = %s, want error", c.src, got)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("PyLiteralToJson(%s) error = %q, want %q", c.src, err, c.want)
+			}
+		})
+	}
+}
+
+func assertJsonEqual(t *testing.T, got, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Fatalf("output is not valid json: %s\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expected value is not valid json: %s\n%s", err, want)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
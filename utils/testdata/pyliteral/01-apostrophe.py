@@ -0,0 +1 @@
+{'code': 1, 'codeMg': 'success', 'returnValue': '', 'trackingEventList': [{'date': '2021-10-28 09:12:00', 'place': 'LONDON', 'details': "Driver's note: left with neighbour"}], 'cMess': None, 'trackingNo': 'LX123456789GB'}
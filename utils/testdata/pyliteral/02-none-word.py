@@ -0,0 +1 @@
+{'code': 1, 'trackingEventList': [{'date': '2021-10-27 18:40:11', 'place': None, 'details': 'None of the items were damaged'}, {'date': '2021-10-26 07:02:53', 'place': 'Noneville', 'details': 'Accepted'}], 'cMess': '', 'trackingNo': 'RR000000001CN'}
@@ -0,0 +1 @@
+{'code': 1, 'trackingEventList': [{'date': '2021-10-25 12:00:00', 'place': 'S\xe3o Paulo', 'details': 'Objeto em trânsito — por favor aguarde\nLinha 2\t"citado"'}], 'cMess': 'It\'s ok', 'trackingNo': 'OA016913717BR'}
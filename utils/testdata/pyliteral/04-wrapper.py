@@ -0,0 +1 @@
+{'code': '200', 'message': 'success', 'items': [{'code': 200, 'codeMg': '', 'returnValue': True, 'trackingEventList': [], 'cMess': False, 'trackingNo': '9400111899223100012345'}]}
@@ -0,0 +1 @@
+{'code': 1, 'trackingEventList': [{'date': '2021-10-24 08:30:00', 'place': '深圳', 'details': u'快件已从【深圳转运中心】发出，下一站"广州"'}], 'cMess': None, 'trackingNo': 'SF1234567890123'}
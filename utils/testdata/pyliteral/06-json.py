@@ -0,0 +1 @@
+{"code": 205, "codeMg": "", "trackingEventList": [], "cMess": "Tracking number not found, please check 'number'", "trackingNo": "1Z999AA10123456784"}
@@ -0,0 +1 @@
+{'code': 1, 'trackingEventList': ({'date': '2021-10-23 20:00:00', 'place': 'PARIS', 'details': 'Colis en cours d\'acheminement',},), 'cMess': None, 'trackingNo': 'CB123456789FR', 'weight': -0.5, 'pieces': 1_000,}